@@ -4,7 +4,6 @@ package common
 
 import (
 	"encoding/json"
-	"fmt"
 	"integration/app/config"
 	"integration/app/core"
 	"io"
@@ -25,8 +24,7 @@ type AccessResponse struct {
 // this is called when polling for status changes, after specific compare is finished or store is called
 func GetAccessToQueue(w http.ResponseWriter, r *http.Request) {
 	if !config.RedisReady(r.Context()) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - cache not ready"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
 		return
 	}
 	//process request
@@ -34,28 +32,24 @@ func GetAccessToQueue(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 	err = json.Unmarshal(b, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
 	res, err := checkAccess(req, r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
@@ -69,7 +63,7 @@ func checkAccess(req AccessRequest, r *http.Request) (AccessResponse, error) {
 		res.Message = "access endpoint not implemented"
 	} else {
 		username := core.GetUserFromHeader(r.Header)
-		email, err := core.Destination.GetUserEmail(r.Context(), req.DataverseKey, username)
+		email, err := core.DefaultDestination().GetUserEmail(r.Context(), req.DataverseKey, username)
 		if err != nil {
 			return res, err
 		}
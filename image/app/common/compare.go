@@ -18,6 +18,9 @@ type CompareRequest struct {
 	Data         []tree.Node `json:"data"`
 	PersistentId string      `json:"persistentId"`
 	DataverseKey string      `json:"dataverseKey"`
+	Destination  string      `json:"destination,omitempty"`
+	Directory    string      `json:"directory,omitempty"`
+	FullListing  bool        `json:"fullListing,omitempty"` // include the equal files, also when omitEqualNodes is set
 }
 
 type Key struct {
@@ -31,55 +34,52 @@ type CachedResponse struct {
 	ErrorMessage string               `json:"err"`
 }
 
-var cacheMaxDuration = 5 * time.Minute
-
 func CacheResponse(res CachedResponse) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 	b, _ := json.Marshal(res)
-	config.GetRedis().Set(ctx, res.Key, string(b), cacheMaxDuration)
+	config.GetRedis().Set(ctx, res.Key, string(b), 5*time.Minute)
 }
 
 // this is called after specific compare request (e.g. github compare)
 func GetCachedResponse(w http.ResponseWriter, r *http.Request) {
 	if !config.RedisReady(r.Context()) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - cache not ready"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
 		return
 	}
 	//process request
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
 	key := Key{}
 	err = json.Unmarshal(b, &key)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
 	res := CachedResponse{Key: key.Key}
-	cached := config.GetRedis().Get(r.Context(), res.Key)
-	if cached.Val() != "" {
-		json.Unmarshal([]byte(cached.Val()), &res)
+	cached, ok, err := config.GetFromCache(r.Context(), res.Key)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	if ok && cached != "" {
+		json.Unmarshal([]byte(cached), &res)
 		config.GetRedis().Del(r.Context(), res.Key)
 		res.Ready = true
 	}
 	if res.ErrorMessage != "" {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", res.ErrorMessage)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, res.ErrorMessage)
 		return
 	}
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
@@ -88,8 +88,7 @@ func GetCachedResponse(w http.ResponseWriter, r *http.Request) {
 // this is called when polling for status changes, after specific compare is finished or store is called
 func Compare(w http.ResponseWriter, r *http.Request) {
 	if !config.RedisReady(r.Context()) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - cache not ready"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
 		return
 	}
 	//process request
@@ -97,21 +96,23 @@ func Compare(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 	err = json.Unmarshal(b, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+
+	if _, err = core.GetDestination(req.Destination); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 
 	errMessage := config.GetRedis().Get(r.Context(), fmt.Sprintf("error %v", req.PersistentId))
 	if errMessage != nil && errMessage.Val() != "" {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("Job failed: %v", errMessage)))
+		WriteErrorWithDetails(w, http.StatusInternalServerError, ErrCodeJobFailed, "Job failed", errMessage.Val())
 		return
 	}
 
@@ -123,11 +124,10 @@ func Compare(w http.ResponseWriter, r *http.Request) {
 
 	//compare and write response
 	user := core.GetUserFromHeader(r.Header)
-	res := core.Compare(r.Context(), nm, req.PersistentId, req.DataverseKey, user, false)
+	res := core.OmitEqualNodes(core.Compare(core.ReadOnly(r.Context()), nm, req.Destination, req.PersistentId, req.DataverseKey, user, false), req.FullListing)
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
@@ -4,7 +4,6 @@ package common
 
 import (
 	"encoding/json"
-	"fmt"
 	"integration/app/config"
 	"integration/app/core"
 	"integration/app/tree"
@@ -17,8 +16,7 @@ import (
 // this is called when polling for status changes, after specific compare is finished or store is called
 func Compute(w http.ResponseWriter, r *http.Request) {
 	if !config.RedisReady(r.Context()) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - cache not ready"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
 		return
 	}
 	//process request
@@ -26,14 +24,12 @@ func Compute(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 	err = json.Unmarshal(b, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 	access, err := checkAccess(AccessRequest{
@@ -42,13 +38,15 @@ func Compute(w http.ResponseWriter, r *http.Request) {
 		Queue:        req.Queue,
 	}, r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - access check failed"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "access check failed")
 		return
 	}
 	if !access.Access {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - access denied"))
+		WriteError(w, http.StatusInternalServerError, ErrCodePermissionDenied, "access denied")
+		return
+	}
+	if err := core.CheckComputeScript(req.Executable); err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
@@ -72,14 +70,12 @@ func Compute(w http.ResponseWriter, r *http.Request) {
 		Queue:              req.Queue,
 	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - failed to add job: " + err.Error()))
+		WriteErrorWithDetails(w, http.StatusInternalServerError, ErrCodeInternal, "failed to add job", err.Error())
 		return
 	}
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
@@ -88,42 +84,41 @@ func Compute(w http.ResponseWriter, r *http.Request) {
 // this is called after specific compare request (e.g. github compare)
 func GetCachedComputeResponse(w http.ResponseWriter, r *http.Request) {
 	if !config.RedisReady(r.Context()) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - cache not ready"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
 		return
 	}
 	//process request
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
 	key := Key{}
 	err = json.Unmarshal(b, &key)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
 	res := core.CachedComputeResponse{Key: key.Key}
-	cached := config.GetRedis().Get(r.Context(), res.Key)
-	if cached.Val() != "" {
-		json.Unmarshal([]byte(cached.Val()), &res)
+	cached, ok, err := config.GetFromCache(r.Context(), res.Key)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	if ok && cached != "" {
+		json.Unmarshal([]byte(cached), &res)
 		config.GetRedis().Del(r.Context(), res.Key)
 	}
 	if res.ErrorMessage != "" {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", res.ErrorMessage)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, res.ErrorMessage)
 		return
 	}
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
@@ -4,17 +4,17 @@ package common
 
 import (
 	"encoding/json"
-	"fmt"
 	"integration/app/core"
 	"io"
 	"net/http"
 )
 
 type DvObjectsRequest struct {
-	Token      string `json:"token"`
-	Collection string `json:"collectionId"`
-	ObjectType string `json:"objectType"`
-	SearchTerm string `json:"searchTerm"`
+	Token       string `json:"token"`
+	Collection  string `json:"collectionId"`
+	ObjectType  string `json:"objectType"`
+	SearchTerm  string `json:"searchTerm"`
+	Destination string `json:"destination,omitempty"`
 }
 
 func DvObjects(w http.ResponseWriter, r *http.Request) {
@@ -23,28 +23,29 @@ func DvObjects(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
 	req := DvObjectsRequest{}
 	err = json.Unmarshal(b, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
-	res, err := core.Destination.Options(r.Context(), req.ObjectType, req.Collection, req.SearchTerm, req.Token, user)
+	destination, err := core.GetDestination(req.Destination)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	res, err := destination.Options(r.Context(), req.ObjectType, req.Collection, req.SearchTerm, req.Token, user)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
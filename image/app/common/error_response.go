@@ -0,0 +1,39 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// machine-readable error codes, so that the frontend can distinguish the error classes without parsing the messages
+const (
+	ErrCodeCacheNotReady    = "CACHE_NOT_READY"
+	ErrCodeBadRequest       = "BAD_REQUEST"
+	ErrCodeUnauthorized     = "UNAUTHORIZED"
+	ErrCodePermissionDenied = "PERMISSION_DENIED"
+	ErrCodeNotFound         = "NOT_FOUND"
+	ErrCodeConflict         = "CONFLICT"
+	ErrCodeGone             = "GONE"
+	ErrCodeJobFailed        = "JOB_FAILED"
+	ErrCodeInternal         = "INTERNAL_ERROR"
+)
+
+type ErrorResponse struct {
+	ErrorCode string `json:"errorCode"`
+	Message   string `json:"message"`
+	Details   string `json:"details,omitempty"`
+}
+
+// writes the error as JSON with the given HTTP status
+func WriteError(w http.ResponseWriter, status int, errorCode, message string) {
+	WriteErrorWithDetails(w, status, errorCode, message, "")
+}
+
+func WriteErrorWithDetails(w http.ResponseWriter, status int, errorCode, message, details string) {
+	b, _ := json.Marshal(ErrorResponse{ErrorCode: errorCode, Message: message, Details: details})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
@@ -4,7 +4,6 @@ package common
 
 import (
 	"encoding/json"
-	"fmt"
 	"integration/app/config"
 	"integration/app/core"
 	"integration/app/tree"
@@ -16,8 +15,7 @@ import (
 // this is called when polling for status changes, after specific compare is finished or store is called
 func GetExecutableFiles(w http.ResponseWriter, r *http.Request) {
 	if !config.RedisReady(r.Context()) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - cache not ready"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
 		return
 	}
 	//process request
@@ -25,14 +23,12 @@ func GetExecutableFiles(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 	err = json.Unmarshal(b, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
@@ -45,13 +41,18 @@ func GetExecutableFiles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//get files and write response
+	destination, err := core.GetDestination(req.Destination)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
 	user := core.GetUserFromHeader(r.Header)
-	nm, err := core.Destination.Query(r.Context(), req.PersistentId, req.DataverseKey, user)
+	nm, err := destination.Query(r.Context(), req.PersistentId, req.DataverseKey, user)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - getting files failed"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, "getting files failed")
 		return
 	}
+	nm = core.FilterByDirectory(nm, req.Directory)
 	data := []tree.Node{}
 	for _, node := range nm {
 		if node.Attributes.IsFile {
@@ -65,12 +66,11 @@ func GetExecutableFiles(w http.ResponseWriter, r *http.Request) {
 		Id:     req.PersistentId,
 		Status: core.Finished,
 		Data:   data,
-		Url:    core.Destination.GetRepoUrl(req.PersistentId, false),
+		Url:    destination.GetRepoUrl(req.PersistentId, false),
 	}
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
@@ -0,0 +1,118 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"integration/app/config"
+	"integration/app/core"
+	"integration/app/plugin/impl/globus"
+	"io"
+	"net/http"
+)
+
+type GlobusStatusRequest struct {
+	PersistentId string `json:"persistentId"`
+	PluginId     string `json:"pluginId"`
+	Token        string `json:"token"` // session id of the Globus login
+}
+
+// returns the status of the last Globus transfer started by the user for the dataset
+func GlobusTransferStatus(w http.ResponseWriter, r *http.Request) {
+	if !config.RedisReady(r.Context()) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
+		return
+	}
+	req := GlobusStatusRequest{}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+	err = json.Unmarshal(b, &req)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+	taskId, ok, err := globus.LastTaskId(r.Context(), req.PersistentId, core.GetUserFromHeader(r.Header))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	if !ok {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "no Globus transfer found for this dataset")
+		return
+	}
+	token, err := core.GetRequiredTokenFromCache(r.Context(), req.Token, req.Token, req.PluginId)
+	if err != nil {
+		WriteError(w, http.StatusUnauthorized, ErrCodeUnauthorized, err.Error())
+		return
+	}
+	res, err := globus.GlobusTaskStatus(r.Context(), token, taskId)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	b, err = json.Marshal(res)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	w.Write(b)
+}
+
+// cancels the last Globus transfer started by the user for the dataset, the files of the cancelled transfer are not added to the dataset;
+// responds with 409 when the transfer has already completed
+func CancelGlobusTransfer(w http.ResponseWriter, r *http.Request) {
+	if !config.RedisReady(r.Context()) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
+		return
+	}
+	req := GlobusStatusRequest{}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+	err = json.Unmarshal(b, &req)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+	taskId, ok, err := globus.LastTaskId(r.Context(), req.PersistentId, core.GetUserFromHeader(r.Header))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	if !ok {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, "no Globus transfer found for this dataset")
+		return
+	}
+	token, err := core.GetRequiredTokenFromCache(r.Context(), req.Token, req.Token, req.PluginId)
+	if err != nil {
+		WriteError(w, http.StatusUnauthorized, ErrCodeUnauthorized, err.Error())
+		return
+	}
+	status, err := globus.GlobusTaskStatus(r.Context(), token, taskId)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	if status.Status == globus.TaskSucceeded || status.Status == globus.TaskFailed {
+		WriteError(w, http.StatusConflict, ErrCodeConflict, "the Globus transfer has already ended")
+		return
+	}
+	err = globus.CancelGlobusTransfer(r.Context(), token, taskId)
+	if errors.Is(err, globus.ErrTaskComplete) {
+		WriteError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	w.Write([]byte("OK"))
+}
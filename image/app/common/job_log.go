@@ -0,0 +1,59 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package common
+
+import (
+	"encoding/json"
+	"integration/app/config"
+	"integration/app/core"
+	"io"
+	"net/http"
+)
+
+type JobLogRequest struct {
+	PersistentId string `json:"persistentId"`
+	DataverseKey string `json:"dataverseKey"`
+	Destination  string `json:"destination,omitempty"`
+}
+
+// returns the log of the (last) job of the dataset, e.g., why specific files failed
+func GetJobLog(w http.ResponseWriter, r *http.Request) {
+	if !config.RedisReady(r.Context()) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
+		return
+	}
+	req := JobLogRequest{}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+	err = json.Unmarshal(b, &req)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+	destination, err := core.GetDestination(req.Destination)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	user := core.GetUserFromHeader(r.Header)
+	err = destination.CheckPermission(r.Context(), req.DataverseKey, user, req.PersistentId)
+	if err != nil {
+		WriteError(w, http.StatusForbidden, ErrCodePermissionDenied, err.Error())
+		return
+	}
+	res, err := core.GetJobLog(r.Context(), req.PersistentId)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	b, err = json.Marshal(res)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	w.Write(b)
+}
@@ -0,0 +1,120 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"integration/app/config"
+	"integration/app/core"
+	"io"
+	"net/http"
+)
+
+// returns the state of the job with the given key (returned by the store and the compute requests), with its position in the queue while queued
+func JobStatus(w http.ResponseWriter, r *http.Request) {
+	key, ok := readJobKey(w, r)
+	if !ok {
+		return
+	}
+	res, err := core.GetJobStatus(r.Context(), key, core.GetUserFromHeader(r.Header))
+	if errors.Is(err, core.ErrJobNotFound) {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	w.Write(b)
+}
+
+// cancels the job with the given key: a queued job is not started, a running job is stopped and the remaining files are not written
+func CancelJob(w http.ResponseWriter, r *http.Request) {
+	key, ok := readJobKey(w, r)
+	if !ok {
+		return
+	}
+	err := core.CancelJob(r.Context(), key, core.GetUserFromHeader(r.Header))
+	if errors.Is(err, core.ErrJobNotFound) {
+		WriteError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, core.ErrJobEnded) {
+		WriteError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	w.Write([]byte("OK"))
+}
+
+func readJobKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !config.RedisReady(r.Context()) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
+		return "", false
+	}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return "", false
+	}
+	key := Key{}
+	err = json.Unmarshal(b, &key)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return "", false
+	}
+	return key.Key, true
+}
+
+type UserJobsRequest struct {
+	PersistentId string `json:"persistentId"`
+}
+
+// lists the jobs of the user from the last day with their state and the link to the dataset, optionally only those of the given dataset
+func UserJobs(w http.ResponseWriter, r *http.Request) {
+	if !config.RedisReady(r.Context()) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
+		return
+	}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+	req := UserJobsRequest{}
+	if len(b) > 0 {
+		err = json.Unmarshal(b, &req)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+			return
+		}
+	}
+	user := core.GetUserFromHeader(r.Header)
+	if user == "" {
+		// without a user, all anonymous jobs would share the same index
+		WriteError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "unknown user")
+		return
+	}
+	res, err := core.GetUserJobs(r.Context(), user, req.PersistentId)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	b, err = json.Marshal(res)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	w.Write(b)
+}
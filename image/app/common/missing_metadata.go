@@ -0,0 +1,60 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package common
+
+import (
+	"encoding/json"
+	"integration/app/core"
+	"integration/app/plugin/types"
+	"io"
+	"net/http"
+)
+
+type MissingMetadataRequest struct {
+	PersistentId string `json:"persistentId"`
+	Collection   string `json:"collection"`
+	DataverseKey string `json:"dataverseKey"`
+	Destination  string `json:"destination,omitempty"`
+}
+
+type MissingMetadataResponse struct {
+	Missing []types.SelectItem `json:"missing"`
+}
+
+// reports the required metadata fields that are still empty, e.g., after creating a new dataset or copying the metadata
+func MissingMetadata(w http.ResponseWriter, r *http.Request) {
+	req := MissingMetadataRequest{}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+	err = json.Unmarshal(b, &req)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
+		return
+	}
+
+	destination, err := core.GetDestination(req.Destination)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	res := MissingMetadataResponse{Missing: []types.SelectItem{}}
+	if destination.MissingMetadata != nil {
+		user := core.GetUserFromHeader(r.Header)
+		res.Missing, err = destination.MissingMetadata(r.Context(), req.DataverseKey, user, req.Collection, req.PersistentId)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+	}
+
+	b, err = json.Marshal(res)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	w.Write(b)
+}
@@ -4,7 +4,6 @@ package common
 
 import (
 	"encoding/json"
-	"fmt"
 	"integration/app/core"
 	"io"
 	"net/http"
@@ -13,6 +12,7 @@ import (
 type NewDatasetRequest struct {
 	Collection   string `json:"collection"`
 	DataverseKey string `json:"dataverseKey"`
+	Destination  string `json:"destination,omitempty"`
 }
 
 type NewDatasetResponse struct {
@@ -24,22 +24,24 @@ func NewDataset(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 	err = json.Unmarshal(b, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
+	destination, err := core.GetDestination(req.Destination)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
 	user := core.GetUserFromHeader(r.Header)
-	pid, err := core.Destination.CreateNewRepo(r.Context(), req.Collection, req.DataverseKey, user)
+	pid, err := destination.CreateNewRepo(r.Context(), req.Collection, req.DataverseKey, user)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 
@@ -49,8 +51,7 @@ func NewDataset(w http.ResponseWriter, r *http.Request) {
 
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
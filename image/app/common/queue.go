@@ -0,0 +1,37 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package common
+
+import (
+	"encoding/json"
+	"integration/app/config"
+	"integration/app/core"
+	"net/http"
+)
+
+// returns the number of waiting jobs and a rough estimate of the waiting time for the synchronization queue and each computation queue
+func GetQueueStatus(w http.ResponseWriter, r *http.Request) {
+	if !config.RedisReady(r.Context()) {
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
+		return
+	}
+	queues := []string{""}
+	for _, q := range config.GetComputationQueues() {
+		queues = append(queues, q.Value)
+	}
+	res := []core.QueueStatus{}
+	for _, q := range queues {
+		status, err := core.GetQueueStatus(r.Context(), q)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+		res = append(res, status)
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	w.Write(b)
+}
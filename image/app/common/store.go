@@ -4,18 +4,21 @@ package common
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"integration/app/config"
 	"integration/app/core"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"io"
 	"net/http"
+
+	"github.com/google/uuid"
 )
 
 type StoreResult struct {
 	Status     string `json:"status"`
 	DatasetUrl string `json:"datasetUrl"`
+	Key        string `json:"key"` // key of the job, for the job status and the cancellation of the job
 }
 
 type StoreRequest struct {
@@ -25,26 +28,27 @@ type StoreRequest struct {
 	DataverseKey       string             `json:"dataverseKey"`
 	SelectedNodes      []tree.Node        `json:"selectedNodes"`
 	SendEmailOnSuccess bool               `json:"sendEmailOnSuccess"`
+	Destination        string             `json:"destination,omitempty"`
+	CompareKey         string             `json:"compareKey,omitempty"`        // key returned by the compare, allows reusing the listing of the dataset
+	ConfirmationToken  string             `json:"confirmationToken,omitempty"` // token returned by the compare, required when requireStoreConfirmation is set
+	Thumbnail          string             `json:"thumbnail,omitempty"`         // id of the copied image to set as dataset thumbnail when setDatasetThumbnail is set
 }
 
 func Store(w http.ResponseWriter, r *http.Request) {
 	if !config.RedisReady(r.Context()) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - cache not ready"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeCacheNotReady, "cache not ready")
 		return
 	}
 	req := StoreRequest{}
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 	err = json.Unmarshal(b, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		WriteError(w, http.StatusInternalServerError, ErrCodeBadRequest, "bad request")
 		return
 	}
 
@@ -57,6 +61,39 @@ func Store(w http.ResponseWriter, r *http.Request) {
 	if req.StreamParams.User == "" {
 		req.StreamParams.User = user
 	}
+	if config.GetConfig().Options.RequireStoreConfirmation {
+		destination, err := core.GetDestination(req.Destination)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		err = core.CheckConfirmationToken(r.Context(), destination, req.ConfirmationToken, req.DataverseKey, user, req.PersistentId)
+		if errors.Is(err, core.ErrStaleCompare) {
+			WriteError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+			return
+		}
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+	}
+	if req.CompareKey != "" {
+		err = core.CheckCompare(r.Context(), req.CompareKey, req.Destination, req.PersistentId)
+		if errors.Is(err, core.ErrCompareExpired) {
+			WriteError(w, http.StatusGone, ErrCodeGone, err.Error())
+			return
+		}
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+	}
+	err = core.CheckJobTotalSize(selected)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	key := uuid.New().String()
 	err = core.AddJob(r.Context(), core.Job{
 		DataverseKey:       req.DataverseKey,
 		User:               user,
@@ -66,20 +103,24 @@ func Store(w http.ResponseWriter, r *http.Request) {
 		Plugin:             req.Plugin,
 		StreamParams:       req.StreamParams,
 		SendEmailOnSuccess: req.SendEmailOnSuccess,
+		Destination:        req.Destination,
+		CompareKey:         req.CompareKey,
+		Thumbnail:          req.Thumbnail,
+		Key:                key,
 	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
+	destination, _ := core.GetDestination(req.Destination) // validated when adding the job
 	res := StoreResult{
 		Status:     "OK",
-		DatasetUrl: core.Destination.GetRepoUrl(req.PersistentId, true),
+		DatasetUrl: destination.GetRepoUrl(req.PersistentId, true),
+		Key:        key,
 	}
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		WriteError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
@@ -0,0 +1,137 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"integration/app/config"
+	"integration/app/core"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const storedPersistentId = "doi:10.5072/FK2/STORE"
+
+// the dataset as listed by the "mock store" destination
+var storeListing = map[string]tree.Node{}
+
+func useStore(t *testing.T, options config.OptionalConfig) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previousRedis := config.GetRedis()
+	config.SetRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	previousOptions := config.GetConfig().Options
+	config.SetOptions(options)
+	storeListing = map[string]tree.Node{}
+	t.Cleanup(func() {
+		config.SetRedis(previousRedis)
+		config.SetOptions(previousOptions)
+	})
+	core.RegisterDestination("mock store", core.DestinationPlugin{
+		Query: func(ctx context.Context, persistentId, token, user string) (map[string]tree.Node, error) {
+			return storeListing, nil
+		},
+		GetRepoUrl: func(pid string, draft bool) string { return "https://repo.example.org/" + pid },
+	})
+	return mr
+}
+
+func store(t *testing.T, req StoreRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	req.Destination = "mock store"
+	req.PersistentId = storedPersistentId
+	b, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	Store(w, httptest.NewRequest(http.MethodPost, "/api/common/store", bytes.NewReader(b)))
+	return w
+}
+
+func expectError(t *testing.T, w *httptest.ResponseRecorder, status int, errorCode string) {
+	t.Helper()
+	res := ErrorResponse{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if w.Code != status || res.ErrorCode != errorCode {
+		t.Errorf("got %v %v (%v), expected %v %v", w.Code, res.ErrorCode, res.Message, status, errorCode)
+	}
+}
+
+func expectStored(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	res := StoreResult{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if w.Code != http.StatusOK || res.Status != "OK" || res.Key == "" {
+		t.Errorf("got %v %v, expected the job to be added", w.Code, w.Body.String())
+	}
+}
+
+// a file selected for copying, with the given size
+func selectedFile(id string, size int64) tree.Node {
+	return tree.Node{Id: id, Action: tree.Copy, Attributes: tree.Attributes{IsFile: true, RemoteFileSize: size}}
+}
+
+func listedFile(hash string) tree.Node {
+	return tree.Node{Id: "file.txt", Attributes: tree.Attributes{IsFile: true, DestinationFile: tree.DestinationFile{Id: 1, FileSize: 7, Hash: hash, HashType: types.Md5}}}
+}
+
+func TestStoreRefusesStaleConfirmationToken(t *testing.T) {
+	useStore(t, config.OptionalConfig{RequireStoreConfirmation: true})
+	storeListing["file.txt"] = listedFile("0cc175b9c0f1b6a831c399e269772661")
+	token := core.ConfirmationToken(storedPersistentId, storeListing)
+	expectStored(t, store(t, StoreRequest{ConfirmationToken: token, SelectedNodes: []tree.Node{selectedFile("new.txt", 3)}}))
+
+	storeListing["file.txt"] = listedFile("92eb5ffee6ae2fec3ad71c777531578f")
+	expectError(t, store(t, StoreRequest{ConfirmationToken: token}), http.StatusConflict, ErrCodeConflict)
+	expectError(t, store(t, StoreRequest{}), http.StatusConflict, ErrCodeConflict)
+}
+
+func TestStoreRefusesExpiredCompare(t *testing.T) {
+	tests := []struct {
+		name         string
+		persistentId string // of the recorded compare
+		destination  string
+		elapsed      time.Duration
+		expired      bool
+	}{
+		{"recent", storedPersistentId, "mock store", time.Minute, false},
+		{"expired", storedPersistentId, "mock store", core.CompareResultExpiry() + time.Second, true},
+		{"another dataset", "doi:10.5072/FK2/OTHER", "mock store", 0, true},
+		{"another destination", storedPersistentId, "other", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := useStore(t, config.OptionalConfig{})
+			core.RecordCompare(context.Background(), "compare key", tt.destination, tt.persistentId)
+			mr.FastForward(tt.elapsed)
+			w := store(t, StoreRequest{CompareKey: "compare key", SelectedNodes: []tree.Node{selectedFile("new.txt", 3)}})
+			if tt.expired {
+				expectError(t, w, http.StatusGone, ErrCodeGone)
+			} else {
+				expectStored(t, w)
+			}
+		})
+	}
+
+	t.Run("unknown key", func(t *testing.T) {
+		useStore(t, config.OptionalConfig{})
+		expectError(t, store(t, StoreRequest{CompareKey: "unknown"}), http.StatusGone, ErrCodeGone)
+	})
+}
+
+func TestStoreRefusesTooLargeJob(t *testing.T) {
+	useStore(t, config.OptionalConfig{MaxJobTotalBytes: 100})
+	selected := []tree.Node{selectedFile("a.txt", 60), selectedFile("b.txt", 41)}
+	expectError(t, store(t, StoreRequest{SelectedNodes: selected}), http.StatusBadRequest, ErrCodeBadRequest)
+
+	deleted := selectedFile("b.txt", 41)
+	deleted.Action = tree.Delete
+	expectStored(t, store(t, StoreRequest{SelectedNodes: []tree.Node{selectedFile("a.txt", 60), deleted}}))
+}
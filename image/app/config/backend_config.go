@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"integration/app/logging"
 	"integration/app/plugin/impl/dataverse"
@@ -26,33 +27,89 @@ type Config struct {
 }
 
 type OptionalConfig struct {
-	DataverseExternalUrl         string        `json:"dataverseExternalUrl,omitempty"` // set this if different from dataverseServer -> this is used to generate a link to the dataset based
-	RootDataverseId              string        `json:"rootDataverseId,omitempty"`      // root dataverse collection id, needed for creating new dataset when no collection was chosen in the UI (fallback to root collection)
-	DefaultHash                  string        `json:"defaultHash,omitempty"`          // preset to md5, the default hash for most Dataverse installations, change this only when using a different hash (e.g., SHA-1)
-	MyDataRoleIds                []int         `json:"myDataRoleIds"`                  // role ids that are sent with the "retrieve" my data api call
-	PathToApiKey                 string        `json:"pathToApiKey,omitempty"`         // api (admin) API key is needed for URL signing. Configure the path to api key in this field to enable the URL signing.
-	PathToUnblockKey             string        `json:"pathToUnblockKey,omitempty"`     // configure to enable checking permissions before requesting jobs
-	PathToRedisPassword          string        `json:"pathToRedisPassword,omitempty"`  // by default no password for Redis is set, if you need to authenticate, store here the path to the file containing the redis password
-	RedisDB                      int           `json:"redisDB,omitempty"`              // by default DB 0 is used, if you need to use other DB, specify it here
-	DefaultDriver                string        `json:"defaultDriver,omitempty"`        // default driver as used by the dataverse installation, only "file" and "s3" are supported, leave empty otherwise
-	StorageId                    string        `json:"storageId,omitempty"`            // storage identifier in Dataverse
-	PathToFilesDir               string        `json:"pathToFilesDir,omitempty"`       // path to the folder where dataverse files are stored (only needed when using "file" driver)
-	S3Config                     S3Config      `json:"s3Config,omitempty"`             // config if using "s3" driver -> see also settings for your s3 in Dataverse installation. Only needed when using S3 filesystem.
-	PathToOauthSecrets           string        `json:"pathToOauthSecrets,omitempty"`   // path to file containing the oath client ids and secrets
-	MaxFileSize                  int64         `json:"maxFileSize,omitempty"`          // if not set, the upload file size is unlimited
-	UserHeaderName               string        `json:"userHeaderName,omitempty"`       // URL signing needs the username in order to know for which user to sign, the user name should be passed in the header of the request. The default is "Ajp_uid", as send by the Shibboleth IDP.
-	SmtpConfig                   Smtp          `json:"smtpConfig,omitempty"`           // configure this when you wish to send notification emails to the users: on job error and on job completion
-	PathToSmtpPassword           string        `json:"pathToSmtpPassword,omitempty"`   // path to the file containing the password needed to authenticate with the SMTP server
-	MailConfig                   MailConfig    `json:"mailConfig,omitempty"`
-	MaxDvObjectPages             int           `json:"maxDvObjectPages"`
-	PathToDataversePluginsConfig string        `json:"pathToDataversePluginsConfig"`
-	ComputationQueues            []Queue       `json:"computationQueues"`
-	ComputationAccessEndpoint    string        `json:"computationAccessEndpoint"`
-	ComputationAccessConfig      []QueueAccess `json:"computationAccessConfig"`
+	DataverseExternalUrl         string            `json:"dataverseExternalUrl,omitempty"` // set this if different from dataverseServer -> this is used to generate a link to the dataset based
+	RootDataverseId              string            `json:"rootDataverseId,omitempty"`      // root dataverse collection id, needed for creating new dataset when no collection was chosen in the UI (fallback to root collection)
+	DefaultHash                  string            `json:"defaultHash,omitempty"`          // preset to md5, the default hash for most Dataverse installations, change this only when using a different hash (e.g., SHA-1)
+	MyDataRoleIds                []int             `json:"myDataRoleIds"`                  // role ids that are sent with the "retrieve" my data api call
+	PathToApiKey                 string            `json:"pathToApiKey,omitempty"`         // api (admin) API key is needed for URL signing. Configure the path to api key in this field to enable the URL signing.
+	PathToUnblockKey             string            `json:"pathToUnblockKey,omitempty"`     // configure to enable checking permissions before requesting jobs
+	PathToRedisPassword          string            `json:"pathToRedisPassword,omitempty"`  // by default no password for Redis is set, if you need to authenticate, store here the path to the file containing the redis password
+	RedisDB                      int               `json:"redisDB,omitempty"`              // by default DB 0 is used, if you need to use other DB, specify it here
+	DefaultDriver                string            `json:"defaultDriver,omitempty"`        // default driver as used by the dataverse installation, only "file" and "s3" are supported, leave empty otherwise
+	StorageId                    string            `json:"storageId,omitempty"`            // storage identifier in Dataverse
+	PathToFilesDir               string            `json:"pathToFilesDir,omitempty"`       // path to the folder where dataverse files are stored (only needed when using "file" driver)
+	S3Config                     S3Config          `json:"s3Config,omitempty"`             // config if using "s3" driver -> see also settings for your s3 in Dataverse installation. Only needed when using S3 filesystem.
+	PathToOauthSecrets           string            `json:"pathToOauthSecrets,omitempty"`   // path to file containing the oath client ids and secrets
+	MaxFileSize                  int64             `json:"maxFileSize,omitempty"`          // if not set, the upload file size is unlimited
+	UserHeaderName               string            `json:"userHeaderName,omitempty"`       // URL signing needs the username in order to know for which user to sign, the user name should be passed in the header of the request. The default is "Ajp_uid", as send by the Shibboleth IDP.
+	SmtpConfig                   Smtp              `json:"smtpConfig,omitempty"`           // configure this when you wish to send notification emails to the users: on job error and on job completion
+	PathToSmtpPassword           string            `json:"pathToSmtpPassword,omitempty"`   // path to the file containing the password needed to authenticate with the SMTP server
+	MailConfig                   MailConfig        `json:"mailConfig,omitempty"`
+	MaxDvObjectPages             int               `json:"maxDvObjectPages"`
+	PathToDataversePluginsConfig string            `json:"pathToDataversePluginsConfig"`
+	ComputationQueues            []Queue           `json:"computationQueues"`
+	ComputationAccessEndpoint    string            `json:"computationAccessEndpoint"`
+	ComputationAccessConfig      []QueueAccess     `json:"computationAccessConfig"`
+	InvenioServer                string            `json:"invenioServer,omitempty"`            // url of the InvenioRDM server, needed only when using the "invenio" destination
+	TempDir                      string            `json:"tempDir,omitempty"`                  // directory for temporary files and folders (e.g., computation mounts), defaults to the OS temp dir; set this when the OS temp dir is small (tmpfs)
+	FollowSymlinks               bool              `json:"followSymlinks,omitempty"`           // symlinks in source folders (local, sftp) are skipped by default; when set, symlinks to files are resolved (symlinks to folders are always skipped to avoid loops)
+	MaxJobAttempts               int               `json:"maxJobAttempts,omitempty"`           // number of times a failing job is attempted before giving up, defaults to 100
+	JobRetryBackoff              int               `json:"jobRetryBackoff,omitempty"`          // seconds to wait before retrying a failed job, doubled after each failed attempt (at most 5 minutes), defaults to 10
+	MaxFileAttempts              int               `json:"maxFileAttempts,omitempty"`          // number of times writing a file is attempted within a job before it is added to the dead letters and skipped, defaults to 3
+	MetadataOnlyUpdates          bool              `json:"metadataOnlyUpdates,omitempty"`      // when set, files with equal content but another directory label, file name or description are updated without uploading the content again
+	JobLogMaxEntries             int               `json:"jobLogMaxEntries,omitempty"`         // maximum number of entries kept in the job log of a dataset (the oldest are dropped), defaults to 1000
+	JobLogRetention              int               `json:"jobLogRetention,omitempty"`          // seconds the job log of a dataset is kept after its last entry, defaults to 7 days
+	MaxPathLength                int               `json:"maxPathLength,omitempty"`            // maximum number of characters of the directory label and file name joined by "/", longer files are rejected during compare (listed in the compare response), not limited by default
+	TruncateLongPaths            bool              `json:"truncateLongPaths,omitempty"`        // when set, the directory label and then the file name of files longer than maxPathLength are truncated instead of rejected
+	CaseCollisions               string            `json:"caseCollisions,omitempty"`           // policy for source files whose directory label and file name differ only by case from another file (case-insensitive storage): "allow" (default), "reject" (listed in the compare response) or "rename"
+	FlattenDepth                 int               `json:"flattenDepth,omitempty"`             // when set, only the top N directories of the source are kept as directory labels (-1 keeps none), the files in deeper directories are moved up, not flattened by default
+	CompareResultExpiry          int               `json:"compareResultExpiry,omitempty"`      // seconds after a compare during which a store referring to the compare is accepted, defaults to 1 hour
+	MaxConcurrentJobs            map[string]int    `json:"maxConcurrentJobs,omitempty"`        // maximum number of jobs running at the same time per plugin in one process (e.g., {"globus": 10, "github": 50}), not limited by default
+	MaxConcurrentOperations      int               `json:"maxConcurrentOperations,omitempty"`  // maximum number of heavy operations (file uploads, computations) running at the same time across all jobs of one process, independently from the number of workers, not limited by default
+	SendMailOnStart              bool              `json:"sendMailOnStart,omitempty"`          // when set, an email acknowledging the job (with its key) is sent when it starts, to the users that asked for an email on success
+	ReplaceConflicts             string            `json:"replaceConflicts,omitempty"`         // policy for files modified in the dataset between the compare and the store: "force" (default, replaced without checking), "skip" (listed in the email on success) or "fail"
+	SkipVanishedFiles            bool              `json:"skipVanishedFiles,omitempty"`        // when set, files removed from the source after the compare are skipped with a warning (listed in the email on success) instead of failing
+	CleanupDeletedDatasets       bool              `json:"cleanupDeletedDatasets,omitempty"`   // when set, the known hashes of a dataset are removed from the cache when the dataset is not found during compare
+	ComputeResultRetention       int               `json:"computeResultRetention,omitempty"`   // minutes the result (console output) of a computation is kept in the cache, defaults to 1440 (24 hours)
+	ComputeScriptExtensions      []string          `json:"computeScriptExtensions,omitempty"`  // extensions (without dot, e.g., ["py"]) of the files that can be run as compute script, defaults to ["py"] as scripts are run with python
+	ComputeScriptsDirectory      string            `json:"computeScriptsDirectory,omitempty"`  // when set, only the files in this directory of the dataset (or its subdirectories) can be run as compute script
+	FlushBatchSize               int               `json:"flushBatchSize,omitempty"`           // maximum number of files registered in one addFiles/replaceFiles call after direct upload, defaults to 500
+	FlushConcurrency             int               `json:"flushConcurrency,omitempty"`         // number of addFiles/replaceFiles calls running at the same time, defaults to 1 (Dataverse locks the dataset while adding files)
+	ListingCacheDuration         int               `json:"listingCacheDuration,omitempty"`     // seconds the dataset listing obtained during compare is reused by the following store, defaults to 300
+	RecordSourceVersion          bool              `json:"recordSourceVersion,omitempty"`      // when set, the source version (git commit, Dataverse dataset version) is recorded in the description of each copied file
+	MaxResponseSize              int64             `json:"maxResponseSize,omitempty"`          // maximum number of bytes read from a (metadata or API) response body, larger responses are rejected, defaults to 104857600 (100 MiB)
+	RequireStoreConfirmation     bool              `json:"requireStoreConfirmation,omitempty"` // when set, a store request must present the confirmation token of the compare and is refused (409) when the dataset changed since that compare
+	ParallelCompareQueries       bool              `json:"parallelCompareQueries,omitempty"`   // when set, the source and destination are queried at the same time during compare (only for plugins that do not use the destination listing, e.g., github, gitlab, osf, dataverse, globus)
+	GlobusRequestAttempts        int               `json:"globusRequestAttempts,omitempty"`    // number of attempts of the requests preparing a Globus transfer (endpoint, principal, upload paths) when they fail on a transient error, defaults to 3
+	SetDatasetThumbnail          bool              `json:"setDatasetThumbnail,omitempty"`      // when set, the image designated in the store request (or the first copied image) becomes the dataset thumbnail after copying
+	ZeroByteFiles                string            `json:"zeroByteFiles,omitempty"`            // policy for zero-byte files at the source during compare: "copy" (default), "skip" (listed in the compare response) or "fail"
+	MaxFileCount                 map[string]int    `json:"maxFileCount,omitempty"`             // maximum number of files in the query result of a plugin (by plugin name, e.g., {"irods": 100000}), compare fails when exceeded, no limit by default
+	HashCheckpointInterval       int64             `json:"hashCheckpointInterval,omitempty"`   // bytes after which the state of a hash computed when rehashing a stored file is saved, an interrupted rehash continues from the last checkpoint, disabled by default
+	ZipUpload                    string            `json:"zipUpload,omitempty"`                // when uploading over the wire (no direct upload driver), zip files are wrapped in a zip and uploaded with the "sword" (default) or the "native" API
+	MultipartFilenames           string            `json:"multipartFilenames,omitempty"`       // encoding of the file names in uploads over the wire: "utf8" (default, RFC 7578) or "rfc5987" (ASCII fallback and percent-encoded UTF-8 name in filename*) for non-ASCII names
+	OverWireUploadTimeout        int               `json:"overWireUploadTimeout,omitempty"`    // seconds after which the upload of a single file over the wire (no direct upload driver) is cancelled and retried, no timeout by default (only the job deadline)
+	FileAddApi                   string            `json:"fileAddApi,omitempty"`               // API registering the files after direct upload: "batch" (addFiles/replaceFiles) or "perFile" (add/replace), detected from the Dataverse version by default (batch from 5.13)
+	VerifyOverWireChecksum       bool              `json:"verifyOverWireChecksum,omitempty"`   // when set, the checksum of the streamed content is compared with the checksum stored by Dataverse for files uploaded over the wire with the native API
+	VerifyDirectUpload           bool              `json:"verifyDirectUpload,omitempty"`       // when set, the files written with direct upload (file and s3 drivers) are read again and their hash is verified before registering them in Dataverse, doubles the I/O
+	DetectDatasetHash            bool              `json:"detectDatasetHash,omitempty"`        // when set, the hash type used by most files in the dataset is preferred over defaultHash for new files and for the hashes calculated by the local and sftp plugins
+	OmitEqualNodes               bool              `json:"omitEqualNodes,omitempty"`           // when set, equal files are left out of the compare response (only counted), unless the full listing is requested
+	LoginRedirectUrl             string            `json:"loginRedirectUrl,omitempty"`         // when set, requests without user (header) are redirected to this URL (browser) or refused with 401 (API), the original URL is passed in the "target" query parameter
+	ReadinessCheckDataverse      bool              `json:"readinessCheckDataverse,omitempty"`  // when set, the readiness probe (/readyz) also checks that the Dataverse server responds to the version API
+	ReadinessTimeout             int               `json:"readinessTimeout,omitempty"`         // seconds after which the checks of the readiness probe fail, defaults to 2
+	PathToTokenEncryptionKey     string            `json:"pathToTokenEncryptionKey,omitempty"` // path to the file containing the key used to encrypt the OAuth tokens cached in Redis, by default the tokens are stored unencrypted
+	DownloadTimeout              map[string]int    `json:"downloadTimeout,omitempty"`          // seconds without receiving data after which a download from the source fails, per plugin (e.g., {"gitlab": 300}), not limited by default
+	CompareSummary               bool              `json:"compareSummary,omitempty"`           // when set, the compare response includes the number and the total size of the files to add, update and delete
+	StreamConcurrency            int               `json:"streamConcurrency,omitempty"`        // number of files of a job streamed and hashed at the same time with direct uploads, defaults to 1 (sequential)
+	EmptyHashPolicy              map[string]string `json:"emptyHashPolicy,omitempty"`          // policy for files listed without hash, per plugin: "keep" (default), "error", "size" (the file size is compared) or "skip"
+	MaxJobTotalBytes             int64             `json:"maxJobTotalBytes,omitempty"`         // maximum total size in bytes of the files copied by one job, not limited by default
+	MalformedFileEntries         string            `json:"malformedFileEntries,omitempty"`     // "fail" (default) or "skip": file entries without id, name or checksum in a Dataverse listing with the OK status
+	CoalesceCompares             string            `json:"coalesceCompares,omitempty"`         // "user": an identical compare of the same user that is already running is reused, disabled by default
+	FileNamePattern              string            `json:"fileNamePattern,omitempty"`          // regular expression the file names must match, files with other names are rejected during compare, defaults to names without the characters Dataverse refuses
+	FolderPathPattern            string            `json:"folderPathPattern,omitempty"`        // regular expression the folder paths (directory labels) must match, defaults to the characters Dataverse accepts
 }
 
 type QueueAccess struct {
-	UserEmail string   `json:"userEmail"`
+	UserEmail string   `json:"userEmail"` // exact email, "*@domain" for all users of a domain or "*" for all users; exact emails take precedence over domains, domains over "*"
 	Queue     []string `json:"queue"`
 }
 
@@ -61,29 +118,48 @@ type MailConfig struct {
 	ContentOnSuccess string `json:"contentOnSuccess,omitempty"`
 	SubjectOnError   string `json:"subjectOnError,omitempty"`
 	ContentOnError   string `json:"contentOnError,omitempty"`
+	SubjectOnStart   string `json:"subjectOnStart,omitempty"`
+	ContentOnStart   string `json:"contentOnStart,omitempty"`
+	SubjectOnPartial string `json:"subjectOnPartial,omitempty"` // the job ended, but some files kept failing and were skipped
+	ContentOnPartial string `json:"contentOnPartial,omitempty"`
 }
 
 type Smtp struct {
-	Host string `json:"host,omitempty"`
-	Port string `json:"port,omitempty"`
-	From string `json:"from,omitempty"`
+	Host         string `json:"host,omitempty"`
+	Port         string `json:"port,omitempty"`
+	From         string `json:"from,omitempty"`         // address in the From header
+	EnvelopeFrom string `json:"envelopeFrom,omitempty"` // envelope sender (MAIL FROM), defaults to from
+	Username     string `json:"username,omitempty"`     // username used for authentication, defaults to from
+	Security     string `json:"security,omitempty"`     // "none", "starttls" or "tls" (implicit TLS), when not set STARTTLS is used when offered by the server
+	Auth         string `json:"auth,omitempty"`         // "plain" or "none", when not set PLAIN authentication is used when a password is configured
 }
 
 // Environment variables used for credentials: set these variables when using "s3" driver on the system where this application is deployed
 // * Access Key ID:     AWS_ACCESS_KEY_ID or AWS_ACCESS_KEY
 // * Secret Access Key: AWS_SECRET_ACCESS_KEY or AWS_SECRET_KEY
+// The credentials are not needed when usePresignedUrls is set: the files are then uploaded to the URLs presigned by Dataverse.
 type S3Config struct {
-	AWSEndpoint  string `json:"awsEndpoint"`
-	AWSRegion    string `json:"awsRegion"`
-	AWSPathstyle bool   `json:"awsPathstyle"`
-	AWSBucket    string `json:"awsBucket"`
+	AWSEndpoint         string `json:"awsEndpoint"`
+	AWSRegion           string `json:"awsRegion"`
+	AWSPathstyle        bool   `json:"awsPathstyle"`
+	AWSBucket           string `json:"awsBucket"`
+	UsePresignedUrls    bool   `json:"usePresignedUrls,omitempty"`    // upload using presigned URLs requested from Dataverse (direct upload must be enabled for the storage in Dataverse)
+	MinPartSize         int64  `json:"minPartSize,omitempty"`         // smallest part size in bytes of multipart uploads, defaults to (and can not be less than) 5 MiB
+	MaxPartSize         int64  `json:"maxPartSize,omitempty"`         // largest part size in bytes of multipart uploads, defaults to 5 GiB
+	MountAttempts       int    `json:"mountAttempts,omitempty"`       // number of times mounting the bucket with s3fs for a computation is attempted, defaults to 3
+	MaxConcurrentMounts int    `json:"maxConcurrentMounts,omitempty"` // maximum number of s3fs mounts of computations at the same time in one process, computations wait for a free slot, not limited by default
+	MaxUploadParts      int32  `json:"maxUploadParts,omitempty"`      // maximum number of parts of multipart uploads, defaults to (and can not be more than) 10000
+	UploadConcurrency   int    `json:"uploadConcurrency,omitempty"`   // number of parts uploaded (and buffered in memory) at the same time, defaults to 2
 }
 
 type OauthSecret struct {
-	PostUrl      string `json:"postURL"`
-	ClientSecret string `json:"clientSecret"`
-	Resource     string `json:"resource"`
-	Exchange     string `json:"exchange"`
+	PostUrl        string `json:"postURL"`
+	ClientSecret   string `json:"clientSecret"`
+	Resource       string `json:"resource"`
+	Exchange       string `json:"exchange"`
+	RefreshMargin  int    `json:"refreshMargin,omitempty"`  // seconds before the expiry of the token when it is refreshed, defaults to 300 (5 minutes)
+	ResourceServer string `json:"resourceServer,omitempty"` // when the provider issues tokens for multiple resource servers, the token of this resource server is used (e.g., "transfer.api.globus.org")
+	OnExchangeFail string `json:"onExchangeFail,omitempty"` // "fail" (default): getting the token fails when the exchange fails, "fallback": the original (not exchanged) token is used and a warning is logged
 }
 
 var config Config
@@ -98,6 +174,7 @@ var redisPassword = "" // will be read from pathToRedisPassword
 var SmtpPassword = ""  // will be read from pathToSmtpPassword
 var AllowQuit = false
 var LockMaxDuration = 168 * time.Hour
+var TokenEncryptionKey = "" // will be read from pathToTokenEncryptionKey
 
 func init() {
 	// read configuration
@@ -147,6 +224,12 @@ func init() {
 		SmtpPassword = strings.TrimSpace(string(b))
 	}
 
+	b, err = os.ReadFile(config.Options.PathToTokenEncryptionKey)
+	if err == nil {
+		logging.Logger.Println("token encryption key is read from file " + config.Options.PathToTokenEncryptionKey)
+		TokenEncryptionKey = strings.TrimSpace(string(b))
+	}
+
 	rdb = redis.NewClient(&redis.Options{
 		Addr:     config.RedisHost,
 		Password: redisPassword,
@@ -169,14 +252,25 @@ func init() {
 			logging.Logger.Println("dataverse plugins config read from file " + config.Options.PathToDataversePluginsConfig)
 		}
 	}
+	initNameRules()
+
 	dataverse.Config = dvPluginsConfig
+	dataverse.RecordSourceVersion = config.Options.RecordSourceVersion
+	dataverse.SkipMalformedEntries = MalformedFileEntriesPolicy() == MalformedEntriesSkip
+	if config.Options.MaxResponseSize > 0 {
+		types.MaxResponseSize = config.Options.MaxResponseSize
+	}
 
 	for _, qa := range config.Options.ComputationAccessConfig {
-		if queueAccess[qa.UserEmail] == nil {
-			queueAccess[qa.UserEmail] = map[string]bool{}
+		key := qa.UserEmail
+		if strings.HasPrefix(key, "*") {
+			key = strings.ToLower(key)
+		}
+		if queueAccess[key] == nil {
+			queueAccess[key] = map[string]bool{}
 		}
 		for _, q := range qa.Queue {
-			queueAccess[qa.UserEmail][q] = true
+			queueAccess[key][q] = true
 		}
 	}
 }
@@ -189,12 +283,30 @@ type RedisClient interface {
 	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
 	LPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
 	RPop(ctx context.Context, key string) *redis.StringCmd
+	LLen(ctx context.Context, key string) *redis.IntCmd
+	LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	LRem(ctx context.Context, key string, count int64, value interface{}) *redis.IntCmd
+	LTrim(ctx context.Context, key string, start, stop int64) *redis.StatusCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
 }
 
 func GetRedis() RedisClient {
 	return rdb
 }
 
+// ok is false when the key is not in the cache (never set, expired or evicted), an empty value is a value like any other;
+// the error is only set when the cache could not be reached
+func GetFromCache(ctx context.Context, key string) (value string, ok bool, err error) {
+	value, err = rdb.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("reading %q from the cache failed: %v", key, err)
+	}
+	return value, true, nil
+}
+
 func SetRedis(r RedisClient) {
 	rdb = r
 }
@@ -210,6 +322,11 @@ func SetConfig(dataverseServer, rootDataverseId, defaultHash string, roleIDs []i
 	config.Options.MaxFileSize = maxFileSize
 }
 
+// replaces the customizations, e.g., when the configuration file is not used (tests)
+func SetOptions(options OptionalConfig) {
+	config.Options = options
+}
+
 func RedisReady(ctx context.Context) bool {
 	res, err := GetRedis().Ping(ctx).Result()
 	if err != nil {
@@ -227,6 +344,43 @@ func ClientSecret(clientId string) (clientSecret, resource, url, exchange string
 	return s.ClientSecret, s.Resource, s.PostUrl, s.Exchange, nil
 }
 
+const defaultTokenRefreshMargin = 5 * time.Minute
+
+func TokenRefreshMargin(clientId string) time.Duration {
+	if s := oauthSecrets[clientId].RefreshMargin; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultTokenRefreshMargin
+}
+
+func TokenResourceServer(clientId string) string {
+	return oauthSecrets[clientId].ResourceServer
+}
+
+const (
+	ExchangeFail     = "fail"
+	ExchangeFallback = "fallback"
+)
+
+func ExchangeFailurePolicy(clientId string) string {
+	if oauthSecrets[clientId].OnExchangeFail == ExchangeFallback {
+		return ExchangeFallback
+	}
+	return ExchangeFail
+}
+
+const (
+	MalformedEntriesFail = "fail"
+	MalformedEntriesSkip = "skip"
+)
+
+func MalformedFileEntriesPolicy() string {
+	if config.Options.MalformedFileEntries == MalformedEntriesSkip {
+		return MalformedEntriesSkip
+	}
+	return MalformedEntriesFail
+}
+
 func GetMaxFileSize() int64 {
 	return config.Options.MaxFileSize
 }
@@ -246,13 +400,43 @@ func GetExternalDestinationURL() string {
 	return config.DataverseServer
 }
 
+func GetTempDir() string {
+	if config.Options.TempDir != "" {
+		return config.Options.TempDir
+	}
+	return os.TempDir()
+}
+
 func GetComputationQueues() []Queue {
 	return config.Options.ComputationQueues
 }
 
+func GetComputationQueue(value string) (Queue, bool) {
+	for _, q := range config.Options.ComputationQueues {
+		if q.Value == value {
+			return q, true
+		}
+	}
+	return Queue{}, false
+}
+
 func HasAccessToQueue(userEmail, queue string) bool {
+	access := queueAccessRules(userEmail)
 	if queue == "" {
-		return len(queueAccess[userEmail]) > 0
+		return len(access) > 0
+	}
+	return access[queue]
+}
+
+// the most specific rule wins: exact email, then "*@domain", then "*"
+func queueAccessRules(userEmail string) map[string]bool {
+	if access, ok := queueAccess[userEmail]; ok {
+		return access
+	}
+	if at := strings.LastIndex(userEmail, "@"); at >= 0 {
+		if access, ok := queueAccess["*"+strings.ToLower(userEmail[at:])]; ok {
+			return access
+		}
 	}
-	return queueAccess[userEmail][queue]
+	return queueAccess["*"]
 }
@@ -0,0 +1,48 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previous := GetRedis()
+	SetRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	t.Cleanup(func() { SetRedis(previous) })
+	return mr
+}
+
+func TestGetFromCache(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+	mr.Set("empty", "")
+	mr.Set("value", "cached")
+
+	tests := []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"missing", "", false},
+		{"empty", "", true},
+		{"value", "cached", true},
+	}
+	for _, tt := range tests {
+		value, ok, err := GetFromCache(ctx, tt.key)
+		if err != nil || ok != tt.ok || value != tt.value {
+			t.Errorf("%v: got %q, %v, %v, expected %q, %v, no error", tt.key, value, ok, err, tt.value, tt.ok)
+		}
+	}
+
+	mr.SetError("cache unavailable")
+	if _, ok, err := GetFromCache(ctx, "value"); err == nil || ok {
+		t.Errorf("a failing cache must return an error, got ok %v, error %v", ok, err)
+	}
+}
@@ -53,4 +53,7 @@ type Queue struct {
 	Label          string   `json:"label"`
 	Value          string   `json:"value"`
 	FileExtensions []string `json:"fileExtensions"`
+	Timeout        int      `json:"timeout,omitempty"`       // seconds after which a computation in this queue is killed, only the job deadline by default
+	MemoryLimitMB  int      `json:"memoryLimitMB,omitempty"` // maximum virtual memory of a computation in this queue in MiB, not limited by default
+	CPULimit       int      `json:"cpuLimit,omitempty"`      // maximum CPU time of a computation in this queue in seconds, not limited by default
 }
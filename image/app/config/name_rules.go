@@ -0,0 +1,50 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// the characters Dataverse does not accept in file names and directory labels
+const defaultFileNamePattern = `^[^:<>;#"\/\*\|\?\\]*$`
+const defaultFolderPathPattern = `^[a-zA-Z0-9_\.\/\- \\]*$`
+
+const defaultFileNameRule = `file names can not contain any of the characters : < > ; # " / * | ? \`
+const defaultFolderPathRule = `folder names can only contain letters, digits, spaces and the characters _ - . / \`
+
+var fileNameRegexp = regexp.MustCompile(defaultFileNamePattern)
+var folderPathRegexp = regexp.MustCompile(defaultFolderPathPattern)
+var fileNameRule = defaultFileNameRule
+var folderPathRule = defaultFolderPathRule
+
+// compiles the configured patterns, an invalid pattern stops the application at startup
+func initNameRules() {
+	if p := config.Options.FileNamePattern; p != "" {
+		r, err := regexp.Compile(p)
+		if err != nil {
+			panic(fmt.Errorf("invalid fileNamePattern %q: %v", p, err))
+		}
+		fileNameRegexp = r
+		fileNameRule = fmt.Sprintf("file names must match the pattern %v", p)
+	}
+	if p := config.Options.FolderPathPattern; p != "" {
+		r, err := regexp.Compile(p)
+		if err != nil {
+			panic(fmt.Errorf("invalid folderPathPattern %q: %v", p, err))
+		}
+		folderPathRegexp = r
+		folderPathRule = fmt.Sprintf("folder names must match the pattern %v", p)
+	}
+}
+
+// true when Dataverse accepts the file name and the folder path (directory label), as configured with fileNamePattern and folderPathPattern
+func ValidName(name, path string) bool {
+	return fileNameRegexp.MatchString(name) && folderPathRegexp.MatchString(path)
+}
+
+// human readable description of the active rules, shown with the rejected files
+func NameRules() string {
+	return fileNameRule + "; " + folderPathRule
+}
@@ -0,0 +1,94 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"fmt"
+	"integration/app/config"
+	"integration/app/tree"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	caseCollisionsAllow  = "allow"
+	caseCollisionsReject = "reject"
+	caseCollisionsRename = "rename"
+)
+
+func caseCollisionsPolicy() string {
+	switch p := strings.ToLower(config.GetConfig().Options.CaseCollisions); p {
+	case caseCollisionsReject, caseCollisionsRename:
+		return p
+	}
+	return caseCollisionsAllow
+}
+
+// on case-insensitive storage, files whose directory label and file name differ only by case overwrite each other (e.g., File.csv and file.csv);
+// depending on the caseCollisions policy, the source nodes colliding with another source node or an existing destination file are removed (reject)
+// or get a number appended to their name (rename), the ids of these nodes are returned, the existing file or the first source node keeps its name
+func CaseCollisions(destinationNm, sourceNm map[string]tree.Node) (map[string]tree.Node, []string) {
+	policy := caseCollisionsPolicy()
+	if policy == caseCollisionsAllow {
+		return sourceNm, nil
+	}
+	owners := map[string]string{}
+	taken := map[string]bool{}
+	for k, v := range destinationNm {
+		if v.Attributes.IsFile {
+			owners[strings.ToLower(destinationName(v))] = k
+			taken[strings.ToLower(destinationName(v))] = true
+		}
+	}
+	keys := []string{}
+	for k, v := range sourceNm {
+		if v.Attributes.IsFile {
+			keys = append(keys, k)
+			taken[strings.ToLower(destinationName(v))] = true
+		}
+	}
+	sort.Strings(keys)
+	res := map[string]tree.Node{}
+	for k, v := range sourceNm {
+		res[k] = v
+	}
+	collisions := []string{}
+	for _, k := range keys {
+		v := sourceNm[k]
+		lower := strings.ToLower(destinationName(v))
+		owner, ok := owners[lower]
+		// a source node with the same id as a destination file replaces that file
+		if !ok || owner == k {
+			owners[lower] = k
+			continue
+		}
+		collisions = append(collisions, v.Id)
+		delete(res, k)
+		if policy == caseCollisionsReject {
+			continue
+		}
+		id := uniqueCaseInsensitiveId(taken, v.Path, v.Name)
+		taken[strings.ToLower(id)] = true
+		v.Attributes.SourcePath = v.SourceId()
+		v.Id = id
+		v.Name = path.Base(id)
+		res[id] = v
+		owners[strings.ToLower(destinationName(v))] = id
+	}
+	return res, collisions
+}
+
+func uniqueCaseInsensitiveId(taken map[string]bool, dir, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		id := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if dir != "" {
+			id = dir + "/" + id
+		}
+		if !taken[strings.ToLower(id)] {
+			return id
+		}
+	}
+}
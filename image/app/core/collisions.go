@@ -0,0 +1,51 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"integration/app/tree"
+	"sort"
+	"strings"
+)
+
+// the file name and directory label as stored by Dataverse: surrounding whitespace and empty path segments are removed
+func destinationName(node tree.Node) string {
+	parts := []string{}
+	for _, p := range strings.Split(node.Path, "/") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(append(parts, strings.TrimSpace(node.Name)), "/")
+}
+
+// returns the ids of the source nodes that would end up with the same directory label and file name as another source node
+// or an existing destination file (a source node with the same id as the destination file replaces that file and does not collide)
+func DestinationCollisions(destinationNm, sourceNm map[string]tree.Node) []string {
+	names := map[string][]string{}
+	for k, v := range destinationNm {
+		if v.Attributes.IsFile {
+			names[destinationName(v)] = append(names[destinationName(v)], k)
+		}
+	}
+	for k, v := range sourceNm {
+		if _, ok := destinationNm[k]; ok || !v.Attributes.IsFile {
+			continue
+		}
+		names[destinationName(v)] = append(names[destinationName(v)], k)
+	}
+	res := []string{}
+	for name, keys := range names {
+		if len(keys) < 2 {
+			continue
+		}
+		for _, k := range keys {
+			// the node that already has the name as stored by Dataverse keeps it
+			if _, ok := sourceNm[k]; ok && k != name {
+				res = append(res, k)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
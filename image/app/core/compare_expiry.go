@@ -0,0 +1,46 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"integration/app/config"
+	"time"
+)
+
+const defaultCompareResultExpiry = 1 * time.Hour
+
+var ErrCompareExpired = errors.New("the compare has expired, please re-run the compare")
+
+// the compare that a store refers to, the store can only follow the compare within the expiry
+type compareRecord struct {
+	PersistentId string `json:"persistentId"`
+	Destination  string `json:"destination"`
+}
+
+func CompareResultExpiry() time.Duration {
+	if s := config.GetConfig().Options.CompareResultExpiry; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultCompareResultExpiry
+}
+
+func RecordCompare(ctx context.Context, compareKey, destination, persistentId string) {
+	b, _ := json.Marshal(compareRecord{PersistentId: persistentId, Destination: destination})
+	config.GetRedis().Set(ctx, "compare: "+compareKey, string(b), CompareResultExpiry())
+}
+
+// returns ErrCompareExpired when the compare is not known (anymore) or was made for another dataset
+func CheckCompare(ctx context.Context, compareKey, destination, persistentId string) error {
+	cached, ok, err := config.GetFromCache(ctx, "compare: "+compareKey)
+	if err != nil {
+		return err
+	}
+	res := compareRecord{}
+	if !ok || json.Unmarshal([]byte(cached), &res) != nil || res.PersistentId != persistentId || res.Destination != destination {
+		return ErrCompareExpired
+	}
+	return nil
+}
@@ -5,12 +5,22 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"integration/app/config"
+	"integration/app/logging"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 )
 
+const (
+	defaultS3MountAttempts = 3
+	s3MountRetryDelay      = 5 * time.Second
+)
+
 type ComputeRequest struct {
 	PersistentId          string `json:"persistentId"`
 	DataverseKey          string `json:"dataverseKey"`
@@ -26,13 +36,52 @@ type CachedComputeResponse struct {
 	ErrorMessage string `json:"err"`
 }
 
+var ErrScriptNotAllowed = errors.New("file can not be run as compute script")
+
+var defaultComputeScriptExtensions = []string{"py"}
+
 var computeCacheMaxDuration = 5 * time.Minute
+var defaultComputeResultRetention = 24 * time.Hour
 
+// the progress is refreshed while polling, the result is kept longer: users can navigate away during long computations
 func CacheComputeResponse(res CachedComputeResponse) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 	b, _ := json.Marshal(res)
-	config.GetRedis().Set(ctx, res.Key, string(b), computeCacheMaxDuration)
+	duration := computeCacheMaxDuration
+	if res.Ready {
+		duration = computeResultRetention()
+	}
+	config.GetRedis().Set(ctx, res.Key, string(b), duration)
+}
+
+func computeResultRetention() time.Duration {
+	if m := config.GetConfig().Options.ComputeResultRetention; m > 0 {
+		return time.Duration(m) * time.Minute
+	}
+	return defaultComputeResultRetention
+}
+
+// the compute script is run with python in the mounted dataset: only files with an allowed extension,
+// within the scripts directory (when configured) and without leaving the dataset (absolute paths, "..") can be run
+func CheckComputeScript(fileName string) error {
+	if fileName == "" || filepath.IsAbs(fileName) || filepath.Clean(fileName) != fileName || strings.HasPrefix(fileName, "..") || strings.HasPrefix(fileName, "-") {
+		return fmt.Errorf("%w: %v: invalid path", ErrScriptNotAllowed, fileName)
+	}
+	if dir := strings.Trim(config.GetConfig().Options.ComputeScriptsDirectory, "/"); dir != "" && !strings.HasPrefix(fileName, dir+"/") {
+		return fmt.Errorf("%w: %v: not in the scripts directory %v", ErrScriptNotAllowed, fileName, dir)
+	}
+	extensions := config.GetConfig().Options.ComputeScriptExtensions
+	if len(extensions) == 0 {
+		extensions = defaultComputeScriptExtensions
+	}
+	ext := strings.TrimPrefix(filepath.Ext(fileName), ".")
+	for _, allowed := range extensions {
+		if strings.EqualFold(ext, strings.TrimPrefix(allowed, ".")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v: extension not allowed", ErrScriptNotAllowed, fileName)
 }
 
 func compute(job Job) (Job, error) {
@@ -70,15 +119,29 @@ func doCompute(fileName string, job Job) (string, error) {
 	ctx, cancel := context.WithDeadline(context.Background(), job.Deadline)
 	defer cancel()
 	out := ""
+	if err := CheckComputeScript(fileName); err != nil {
+		return out, err
+	}
+	release, err := acquireOperationSlot(ctx)
+	if err != nil {
+		return out, err
+	}
+	defer release()
 	dir, err := mountDataset(ctx, job)
 	if err != nil {
 		out = dir
 	} else {
-		cmd := exec.CommandContext(ctx, "bash", "-c", "python "+fileName)
+		queue, _ := config.GetComputationQueue(job.Queue)
+		cmdCtx, cmdCancel := ctx, context.CancelFunc(func() {})
+		if queue.Timeout > 0 {
+			cmdCtx, cmdCancel = context.WithTimeout(ctx, time.Duration(queue.Timeout)*time.Second)
+		}
+		defer cmdCancel()
+		cmd := computeCommand(cmdCtx, fileName, queue)
 		cmd.Dir = dir
 		o, err := cmd.CombinedOutput()
 		out = string(o)
-		if err != nil {
+		if err = computeError(ctx, cmdCtx, err, out, queue); err != nil {
 			out = out + "\n\n" + err.Error()
 		}
 	}
@@ -86,23 +149,63 @@ func doCompute(fileName string, job Job) (string, error) {
 	return out, err
 }
 
-func mountDataset(ctx context.Context, job Job) (string, error) {
-	s3Dir := job.Key + "/s3"
-	linkedDir := job.Key + "/linked"
-	b, err := exec.Command("mkdir", job.Key).CombinedOutput()
-	if err != nil {
-		return string(b), err
+// the limits of the queue are set with ulimit in a bash wrapper, the script name is passed as argument and is not interpreted by bash
+func computeCommand(ctx context.Context, fileName string, queue config.Queue) *exec.Cmd {
+	limits := []string{}
+	if queue.MemoryLimitMB > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", queue.MemoryLimitMB*1024))
 	}
-	b, err = exec.Command("mkdir", s3Dir).CombinedOutput()
-	if err != nil {
-		return string(b), err
+	if queue.CPULimit > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", queue.CPULimit))
 	}
-	use_path_request_style := "use_path_request_style,"
-	if !config.GetConfig().Options.S3Config.AWSPathstyle {
-		use_path_request_style = ""
+	if len(limits) == 0 {
+		return exec.CommandContext(ctx, "python", fileName)
 	}
-	command := fmt.Sprintf("s3fs -o %vbucket=%v,host=\"%v\",ro %v", use_path_request_style, config.GetConfig().Options.S3Config.AWSBucket, config.GetConfig().Options.S3Config.AWSEndpoint, s3Dir)
-	b, err = exec.Command("bash", "-c", command).CombinedOutput()
+	return exec.CommandContext(ctx, "bash", "-c", strings.Join(limits, " && ")+` && exec python "$1"`, "bash", fileName)
+}
+
+// tells the user why the process was killed instead of the generic exit error
+func computeError(jobCtx, cmdCtx context.Context, err error, out string, queue config.Queue) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(jobCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("killed: job deadline exceeded")
+	}
+	if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("killed: timeout of %d seconds exceeded", queue.Timeout)
+	}
+	exitErr := &exec.ExitError{}
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	signaled := ok && status.Signaled()
+	switch {
+	case queue.CPULimit > 0 && signaled && status.Signal() == syscall.SIGXCPU:
+		return fmt.Errorf("killed: CPU time limit exceeded")
+	case queue.MemoryLimitMB > 0 && (signaled && status.Signal() == syscall.SIGKILL || strings.Contains(out, "MemoryError")):
+		// python raises a MemoryError when an allocation fails under ulimit, the OOM killer (cgroup limit) sends SIGKILL
+		return fmt.Errorf("killed: memory limit exceeded")
+	}
+	return err
+}
+
+// the symlinks are created with absolute targets, such that they resolve from within the computation whatever its working directory;
+// the temp dir can be configured with a relative path
+func computeWorkDir(job Job) string {
+	workDir, err := filepath.Abs(filepath.Join(config.GetTempDir(), job.Key))
+	if err != nil {
+		return filepath.Join(config.GetTempDir(), job.Key)
+	}
+	return workDir
+}
+
+func mountDataset(ctx context.Context, job Job) (string, error) {
+	workDir := computeWorkDir(job)
+	s3Dir := workDir + "/s3"
+	linkedDir := workDir + "/linked"
+	b, err := exec.Command("mkdir", "-p", workDir).CombinedOutput()
 	if err != nil {
 		return string(b), err
 	}
@@ -110,17 +213,37 @@ func mountDataset(ctx context.Context, job Job) (string, error) {
 	if err != nil {
 		return string(b), err
 	}
-	nm, err := Destination.Query(ctx, job.PersistentId, job.DataverseKey, job.User)
+	nm, err := job.destination().Query(ctx, job.PersistentId, job.DataverseKey, job.User)
+	if err != nil {
+		return err.Error(), err
+	}
+	identifier, err := trimProtocol(job.PersistentId)
 	if err != nil {
 		return err.Error(), err
 	}
+	mounted := false
 	for _, n := range nm {
-		identifier, err := trimProtocol(job.PersistentId)
+		s := getStorage(n.Attributes.DestinationFile.StorageIdentifier)
+		// files stored with the file driver are linked directly, s3fs is only needed for the files in s3
+		target, err := filepath.Abs(config.GetConfig().Options.PathToFilesDir + identifier + "/" + s.filename)
 		if err != nil {
 			return err.Error(), err
 		}
-		filename := identifier + "/" + getStorage(n.Attributes.DestinationFile.StorageIdentifier).filename
-		command = fmt.Sprintf("ln -s $(pwd)/%v $(pwd)/%v", s3Dir+"/"+filename, linkedDir+"/"+n.Id)
+		if s.driver == "s3" {
+			if !mounted {
+				// the slot is released when unmounting, also when mounting fails
+				if err := acquireMountSlot(ctx, job.Key); err != nil {
+					return err.Error(), err
+				}
+				out, err := mountS3Bucket(ctx, s3Dir)
+				if err != nil {
+					return out, err
+				}
+				mounted = true
+			}
+			target = s3Dir + "/" + identifier + "/" + s.filename
+		}
+		command := fmt.Sprintf("ln -s %v %v", target, linkedDir+"/"+n.Id)
 		b, err = exec.Command("bash", "-c", command).CombinedOutput()
 		if err != nil {
 			return string(b), err
@@ -129,11 +252,46 @@ func mountDataset(ctx context.Context, job Job) (string, error) {
 	return linkedDir, err
 }
 
+// mounting is retried with an increasing delay, the mount fails transiently when s3 is briefly unavailable
+func mountS3Bucket(ctx context.Context, s3Dir string) (string, error) {
+	b, err := exec.Command("mkdir", "-p", s3Dir).CombinedOutput()
+	if err != nil {
+		return string(b), err
+	}
+	use_path_request_style := "use_path_request_style,"
+	if !config.GetConfig().Options.S3Config.AWSPathstyle {
+		use_path_request_style = ""
+	}
+	command := fmt.Sprintf("s3fs -o %vbucket=%v,host=\"%v\",ro %v", use_path_request_style, config.GetConfig().Options.S3Config.AWSBucket, config.GetConfig().Options.S3Config.AWSEndpoint, s3Dir)
+	attempts := s3MountAttempts()
+	for i := 1; ; i++ {
+		b, err = exec.Command("bash", "-c", command).CombinedOutput()
+		if err == nil || i >= attempts {
+			return string(b), err
+		}
+		logging.Logger.Printf("mounting s3 failed (attempt %v/%v): %v: %v\n", i, attempts, err, string(b))
+		select {
+		case <-ctx.Done():
+			return string(b), ctx.Err()
+		case <-time.After(time.Duration(i) * s3MountRetryDelay):
+		}
+	}
+}
+
+func s3MountAttempts() int {
+	if n := config.GetConfig().Options.S3Config.MountAttempts; n > 0 {
+		return n
+	}
+	return defaultS3MountAttempts
+}
+
 func unmount(job Job) {
-	s3Dir := job.Key + "/s3"
-	linkedDir := job.Key + "/linked"
+	workDir := computeWorkDir(job)
+	s3Dir := workDir + "/s3"
+	linkedDir := workDir + "/linked"
 	exec.Command("rm", "-rf", linkedDir).Output()
 	exec.Command("fusermount", "-uz", s3Dir).CombinedOutput()
+	releaseMountSlot(job.Key)
 	exec.Command("rmdir", s3Dir).Output()
-	exec.Command("rmdir", job.Key).Output()
+	exec.Command("rmdir", workDir).Output()
 }
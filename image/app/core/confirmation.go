@@ -0,0 +1,47 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"integration/app/tree"
+	"sort"
+)
+
+var ErrStaleCompare = errors.New("the dataset changed since the compare, compare again before storing")
+
+// binds a store request to the state of the dataset shown in the compare result:
+// the token is the hash of the listing of the dataset at the time of the compare
+func ConfirmationToken(persistentId string, nm map[string]tree.Node) string {
+	keys := make([]string, 0, len(nm))
+	for k := range nm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", persistentId)
+	for _, k := range keys {
+		f := nm[k].Attributes.DestinationFile
+		fmt.Fprintf(h, "%s\t%d\t%d\t%s:%s\n", k, f.Id, f.FileSize, f.HashType, f.Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lists the dataset again and verifies that it did not change since the compare that issued the token
+func CheckConfirmationToken(ctx context.Context, destination DestinationPlugin, token, dataverseKey, user, persistentId string) error {
+	if token == "" {
+		return fmt.Errorf("%w: confirmation token is missing", ErrStaleCompare)
+	}
+	nm, err := destination.Query(ctx, persistentId, dataverseKey, user)
+	if err != nil {
+		return err
+	}
+	if ConfirmationToken(persistentId, nm) != token {
+		return ErrStaleCompare
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"errors"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"testing"
+)
+
+func TestCheckConfirmationToken(t *testing.T) {
+	listed := func(hash string) tree.Node {
+		return tree.Node{Id: "file.txt", Attributes: tree.Attributes{IsFile: true, DestinationFile: tree.DestinationFile{Id: 1, FileSize: 7, Hash: hash, HashType: types.Md5}}}
+	}
+	tests := []struct {
+		name     string
+		changed  map[string]tree.Node // listing of the dataset at the store, nil when unchanged
+		token    func(string) string
+		expected error
+	}{
+		{"unchanged", nil, func(token string) string { return token }, nil},
+		{"file replaced", map[string]tree.Node{"file.txt": listed(md5Hex("other"))}, func(token string) string { return token }, ErrStaleCompare},
+		{"file added", map[string]tree.Node{"file.txt": listed(md5Hex("content")), "new.txt": listed(md5Hex("new"))}, func(token string) string { return token }, ErrStaleCompare},
+		{"file deleted", map[string]tree.Node{}, func(token string) string { return token }, ErrStaleCompare},
+		{"token missing", nil, func(string) string { return "" }, ErrStaleCompare},
+		{"token of another dataset", nil, func(string) string {
+			return ConfirmationToken("doi:10.5072/FK2/OTHER", map[string]tree.Node{"file.txt": listed(md5Hex("content"))})
+		}, ErrStaleCompare},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockDestination()
+			m.listing = map[string]tree.Node{"file.txt": listed(md5Hex("content"))}
+			dest := m.plugin()
+			nm, _ := dest.Query(context.Background(), testPersistentId, "token", "user")
+			token := ConfirmationToken(testPersistentId, nm)
+
+			if tt.changed != nil {
+				m.listing = tt.changed
+			}
+			err := CheckConfirmationToken(context.Background(), dest, tt.token(token), "token", "user", testPersistentId)
+			if !errors.Is(err, tt.expected) || (tt.expected == nil) != (err == nil) {
+				t.Errorf("got %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+}
@@ -4,20 +4,31 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"io"
 	"sync"
 )
 
-var Destination DestinationPlugin
+// default destination, used when no destination is specified in the request
+var defaultDestination DestinationPlugin
+
+var destinations = map[string]DestinationPlugin{}
+
+// the destinations are registered at startup and read by the request handlers and the workers
+var destinationsMutex sync.RWMutex
+
+// wrapped in the error returned by CheckPermission or Query when the dataset does not exist (anymore)
+var ErrDatasetNotFound = errors.New("dataset not found")
 
 type DestinationPlugin struct {
 	IsDirectUpload        func() bool
 	CheckPermission       func(ctx context.Context, token, user, persistentId string) error
 	CreateNewRepo         func(ctx context.Context, collection, token, userName string) (string, error)
 	GetRepoUrl            func(pid string, draft bool) string
-	WriteOverWire         func(ctx context.Context, dbId int64, nodeMapId, token, user, persistentId string, wg *sync.WaitGroup, async_err *ErrorHolder) (io.WriteCloser, error)
+	WriteOverWire         func(ctx context.Context, dbId int64, nodeMapId string, categories []string, description string, token, user, persistentId string, wg *sync.WaitGroup, async_err *ErrorHolder) (io.WriteCloser, error)
 	SaveAfterDirectUpload func(ctx context.Context, replace bool, token, user, persistentId string, storageIdentifiers []string, nodes []tree.Node) error
 	CleanupLeftOverFiles  func(ctx context.Context, persistentId, token, user string) error
 	DeleteFile            func(ctx context.Context, token, user string, id int64) error
@@ -25,4 +36,55 @@ type DestinationPlugin struct {
 	GetStream             func(ctx context.Context, token, user string, id int64) (io.ReadCloser, error)
 	Query                 func(ctx context.Context, persistentId, token, user string) (map[string]tree.Node, error)
 	GetUserEmail          func(ctx context.Context, token, user string) (string, error)
+	MissingMetadata       func(ctx context.Context, token, user, collection, persistentId string) ([]types.SelectItem, error)       // optional
+	IsDraft               func(ctx context.Context, token, user, persistentId string) (bool, error)                                 // optional, links point to the draft version when not set
+	UploadToPresignedUrl  func(ctx context.Context, token, user, persistentId string, reader io.Reader, size int64) (string, error) // optional, needed for s3 direct upload without credentials
+	SetThumbnail          func(ctx context.Context, token, user, persistentId string, fileId int64) error                           // optional, needed for setting the dataset thumbnail after copying
+	UpdateFileMetadata    func(ctx context.Context, token, user, persistentId string, node tree.Node) error                         // optional, needed for metadata-only updates (see metadataOnlyUpdates)
+}
+
+func RegisterDestination(name string, destination DestinationPlugin) {
+	destinationsMutex.Lock()
+	defer destinationsMutex.Unlock()
+	destinations[name] = guardWrites(destination)
+}
+
+// returns the destination registered under the given name, or the default destination when the name is empty
+func GetDestination(name string) (DestinationPlugin, error) {
+	destinationsMutex.RLock()
+	defer destinationsMutex.RUnlock()
+	if name == "" {
+		return defaultDestination, nil
+	}
+	d, ok := destinations[name]
+	if !ok {
+		return DestinationPlugin{}, fmt.Errorf("unknown destination: %v", name)
+	}
+	return d, nil
+}
+
+// the destination name is validated when adding the job or handling the request, fall back to the default destination otherwise
+func destinationOrDefault(name string) DestinationPlugin {
+	d, err := GetDestination(name)
+	if err != nil {
+		return DefaultDestination()
+	}
+	return d
+}
+
+func DefaultDestination() DestinationPlugin {
+	destinationsMutex.RLock()
+	defer destinationsMutex.RUnlock()
+	return defaultDestination
+}
+
+func SetDefaultDestination(name string) error {
+	d, err := GetDestination(name)
+	if err != nil {
+		return err
+	}
+	destinationsMutex.Lock()
+	defer destinationsMutex.Unlock()
+	defaultDestination = d
+	return nil
 }
@@ -0,0 +1,166 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testPersistentId = "doi:10.5072/FK2/ABC"
+
+func testJob(nodes ...tree.Node) Job {
+	job := Job{
+		DataverseKey:  "token",
+		User:          "user",
+		PersistentId:  testPersistentId,
+		Plugin:        "local",
+		HashType:      types.Md5,
+		WritableNodes: map[string]tree.Node{},
+	}
+	for _, n := range nodes {
+		job.WritableNodes[n.Id] = n
+	}
+	return job
+}
+
+func registerMock(t *testing.T, name string, m *mockDestination) DestinationPlugin {
+	t.Helper()
+	RegisterDestination(name, m.plugin())
+	dest, err := GetDestination(name)
+	if err != nil {
+		t.Fatalf("registered destination not found: %v", err)
+	}
+	return dest
+}
+
+func TestRegisteredDestinationOverTheWire(t *testing.T) {
+	mr := useMiniredis(t)
+	useOptions(t, config.OptionalConfig{})
+	m := newMockDestination()
+	dest := registerMock(t, "mock over the wire", m)
+
+	contents := map[string]string{"new.txt": "new content", "updated.txt": "updated content"}
+	job := testJob(
+		sourceNode("new.txt", contents["new.txt"], tree.Copy, 0),
+		sourceNode("updated.txt", contents["updated.txt"], tree.Update, 2),
+		tree.Node{Id: "deleted.txt", Action: tree.Delete, Attributes: tree.Attributes{IsFile: true, DestinationFile: tree.DestinationFile{Id: 3}}},
+	)
+	out, err := doPersistNodeMap(context.Background(), dest, stringStreams(contents), job, map[string]calculatedHashes{})
+	if err != nil {
+		t.Fatalf("persisting failed: %v", err)
+	}
+
+	for k, c := range contents {
+		if m.written[k] != c {
+			t.Errorf("written %v: got %q, expected %q", k, m.written[k], c)
+		}
+	}
+	if m.writtenDbIds["new.txt"] != 0 || m.writtenDbIds["updated.txt"] != 2 {
+		t.Errorf("the updated file must replace file 2 and the new file must be added, got %v", m.writtenDbIds)
+	}
+	if !slices.Equal(m.deleted, []int64{3}) {
+		t.Errorf("deleted: got %v, expected [3]", m.deleted)
+	}
+	if len(m.added) != 0 || len(m.replaced) != 0 {
+		t.Errorf("files written over the wire must not be flushed, got added %v, replaced %v", m.added, m.replaced)
+	}
+	if len(out.WritableNodes) != 0 || len(out.Failed) != 0 {
+		t.Errorf("all nodes must be written, left: %v, failed: %v", out.WritableNodes, out.Failed)
+	}
+	for k, expected := range map[string]string{"new.txt": types.Written, "updated.txt": types.Written, "deleted.txt": types.Deleted} {
+		if got, _ := mr.Get(testPersistentId + " -> " + k); got != expected {
+			t.Errorf("status of %v: got %q, expected %q", k, got, expected)
+		}
+	}
+}
+
+func TestRegisteredDestinationFlushesDirectUploads(t *testing.T) {
+	useMiniredis(t)
+	filesDir := t.TempDir() + "/"
+	useOptions(t, config.OptionalConfig{DefaultDriver: "file", PathToFilesDir: filesDir})
+	m := newMockDestination()
+	m.directUpload = true
+	dest := registerMock(t, "mock direct upload", m)
+
+	contents := map[string]string{"new.txt": "new content", "updated.txt": "updated content"}
+	job := testJob(
+		sourceNode("new.txt", contents["new.txt"], tree.Copy, 0),
+		sourceNode("updated.txt", contents["updated.txt"], tree.Update, 2),
+	)
+	out, err := doPersistNodeMap(context.Background(), dest, stringStreams(contents), job, map[string]calculatedHashes{})
+	if err != nil {
+		t.Fatalf("persisting failed: %v", err)
+	}
+
+	if len(m.written) != 0 {
+		t.Errorf("direct uploads must not be written over the wire, got %v", m.written)
+	}
+	if !slices.Equal(m.added, []string{"new.txt"}) || !slices.Equal(m.replaced, []string{"updated.txt"}) {
+		t.Errorf("flushed: got added %v, replaced %v", m.added, m.replaced)
+	}
+	if len(m.deleted) != 0 {
+		t.Errorf("nothing must be deleted, got %v", m.deleted)
+	}
+	if len(out.WritableNodes) != 0 {
+		t.Errorf("all nodes must be flushed, left: %v", out.WritableNodes)
+	}
+	stored := []string{}
+	for _, id := range m.identifiers {
+		if !strings.HasPrefix(id, "file://") {
+			t.Fatalf("unexpected storage identifier %v", id)
+		}
+		b, err := os.ReadFile(filesDir + "10.5072/FK2/ABC/" + strings.TrimPrefix(id, "file://"))
+		if err != nil {
+			t.Fatalf("flushed file not stored: %v", err)
+		}
+		stored = append(stored, string(b))
+	}
+	slices.Sort(stored)
+	if !slices.Equal(stored, []string{"new content", "updated content"}) {
+		t.Errorf("stored: got %v", stored)
+	}
+}
+
+// run with -race: the destinations are registered while the request handlers and the workers read them
+func TestDestinationRegistryConcurrentAccess(t *testing.T) {
+	previous := DefaultDestination()
+	t.Cleanup(func() {
+		destinationsMutex.Lock()
+		defer destinationsMutex.Unlock()
+		defaultDestination = previous
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("mock %v", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterDestination(name, newMockDestination().plugin())
+			if err := SetDefaultDestination(name); err != nil {
+				t.Errorf("setting the default destination failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			GetDestination(name)
+			destinationOrDefault(name)
+			DefaultDestination()
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 20; i++ {
+		if _, err := GetDestination(fmt.Sprintf("mock %v", i)); err != nil {
+			t.Errorf("registered destination lost: %v", err)
+		}
+	}
+}
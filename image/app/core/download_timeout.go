@@ -0,0 +1,105 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// returned when a download did not receive any data within the download timeout of the plugin,
+// the file is then retried and eventually skipped (dead letters) without holding the job until its deadline
+var ErrDownloadStalled = errors.New("download stalled")
+
+func downloadTimeout(plugin string) time.Duration {
+	if s := config.GetConfig().Options.DownloadTimeout[plugin]; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return 0
+}
+
+// wraps the streams of the plugin such that opening a stream and each read fail when no data arrives within the timeout
+func withDownloadTimeout(streams map[string]types.Stream, plugin string) map[string]types.Stream {
+	timeout := downloadTimeout(plugin)
+	if timeout == 0 {
+		return streams
+	}
+	res := make(map[string]types.Stream, len(streams))
+	for k, s := range streams {
+		res[k] = timeoutStream(s, timeout)
+	}
+	return res
+}
+
+func timeoutStream(s types.Stream, timeout time.Duration) types.Stream {
+	return types.Stream{
+		Open: func() (io.Reader, error) {
+			type opened struct {
+				reader io.Reader
+				err    error
+			}
+			done := make(chan opened, 1)
+			go func() {
+				r, err := s.Open()
+				done <- opened{r, err}
+			}()
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			select {
+			case o := <-done:
+				if o.err != nil {
+					return nil, o.err
+				}
+				return &timeoutReader{reader: o.reader, timeout: timeout, close: closerOf(o.reader, s)}, nil
+			case <-timer.C:
+				// the stream can not be closed before it is opened: the late stream is closed when it arrives
+				go func() {
+					if o := <-done; o.err == nil {
+						closerOf(o.reader, s)()
+					}
+				}()
+				return nil, fmt.Errorf("%w: no response within %v", ErrDownloadStalled, timeout)
+			}
+		},
+		Close: s.Close,
+	}
+}
+
+// closing the opened reader unblocks a pending read, the close function of the stream is used when the reader can not be closed
+func closerOf(reader io.Reader, s types.Stream) func() error {
+	if c, ok := reader.(io.Closer); ok {
+		return c.Close
+	}
+	return s.Close
+}
+
+// the timer only runs while waiting for the source: a slow destination does not count as a stalled download
+type timeoutReader struct {
+	reader  io.Reader
+	timeout time.Duration
+	close   func() error
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+func (t *timeoutReader) Read(p []byte) (int, error) {
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.timeout, func() {
+			t.stalled.Store(true)
+			t.close()
+		})
+	} else {
+		t.timer.Reset(t.timeout)
+	}
+	n, err := t.reader.Read(p)
+	t.timer.Stop()
+	if t.stalled.Load() {
+		return n, fmt.Errorf("%w: no data received within %v", ErrDownloadStalled, t.timeout)
+	}
+	return n, err
+}
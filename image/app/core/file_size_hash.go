@@ -2,7 +2,10 @@
 
 package core
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"fmt"
+)
 
 type FileSizeHash struct {
 	FileSize int64
@@ -30,3 +33,9 @@ func (h *FileSizeHash) Size() int {
 func (h *FileSizeHash) BlockSize() int {
 	return 64
 }
+
+// the value of the file size "hash" of a file with the given size, as calculated when writing the file
+func FileSizeHashValue(size int64) string {
+	h := FileSizeHash{FileSize: size}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
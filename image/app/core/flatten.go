@@ -0,0 +1,78 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"fmt"
+	"integration/app/config"
+	"integration/app/tree"
+	"path"
+	"sort"
+	"strings"
+)
+
+const flattenAll = -1
+
+// maps the directory structure of the source to fewer levels of directory labels, as configured with flattenDepth:
+// only the top N directories are kept (-1 keeps none), the deeper directories are dropped;
+// a file that would get the same id as another one gets the dropped directories as prefix of its name (and a number when still not unique),
+// the original id is kept in SourcePath for streaming the file from the source
+func FlattenDirectories(nm map[string]tree.Node) map[string]tree.Node {
+	depth := config.GetConfig().Options.FlattenDepth
+	if depth == 0 || depth < flattenAll {
+		return nm
+	}
+	keys := []string{}
+	for k := range nm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := map[string]tree.Node{}
+	for _, k := range keys {
+		v := nm[k]
+		if !v.Attributes.IsFile {
+			continue
+		}
+		segments := []string{}
+		if v.Path != "" {
+			segments = strings.Split(v.Path, "/")
+		}
+		keep := max(depth, 0)
+		if len(segments) <= keep {
+			res[uniqueId(res, v.Path, v.Name, nil)] = v
+			continue
+		}
+		dir, dropped := strings.Join(segments[:keep], "/"), segments[keep:]
+		id := uniqueId(res, dir, v.Name, dropped)
+		v.Attributes.SourcePath = v.SourceId()
+		v.Id = id
+		v.Path = dir
+		v.Name = path.Base(id)
+		res[id] = v
+	}
+	return res
+}
+
+func uniqueId(taken map[string]tree.Node, dir, name string, dropped []string) string {
+	join := func(name string) string {
+		if dir == "" {
+			return name
+		}
+		return dir + "/" + name
+	}
+	if _, ok := taken[join(name)]; !ok {
+		return join(name)
+	}
+	if len(dropped) > 0 {
+		name = strings.Join(dropped, "_") + "_" + name
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; ; i++ {
+		if _, ok := taken[join(candidate)]; !ok {
+			return join(candidate)
+		}
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+}
@@ -0,0 +1,106 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"integration/app/config"
+	"integration/app/logging"
+	"io"
+	"time"
+)
+
+const hashCheckpointRetention = 24 * time.Hour
+
+// state of a partially computed hash: hashing of a very large file can continue from the offset after an interrupted job;
+// supported by the hash functions implementing encoding.BinaryMarshaler (MD5, SHA-1, SHA-256, SHA-512 and the git hash)
+type hashCheckpoint struct {
+	FileSize int64  `json:"fileSize"`
+	Offset   int64  `json:"offset"`
+	State    []byte `json:"state"`
+}
+
+type hashCheckpointer struct {
+	key      string
+	interval int64
+	fileSize int64
+	hasher   hash.Hash
+}
+
+// returns nil when checkpointing is disabled or not supported by the hash function
+func newHashCheckpointer(storageIdentifier, hashType string, fileSize int64, hasher hash.Hash) *hashCheckpointer {
+	interval := config.GetConfig().Options.HashCheckpointInterval
+	if interval <= 0 || fileSize <= interval {
+		return nil
+	}
+	if _, ok := hasher.(encoding.BinaryMarshaler); !ok {
+		return nil
+	}
+	if _, ok := hasher.(encoding.BinaryUnmarshaler); !ok {
+		return nil
+	}
+	return &hashCheckpointer{
+		key:      fmt.Sprintf("hash checkpoint: %v: %v", storageIdentifier, hashType),
+		interval: interval,
+		fileSize: fileSize,
+		hasher:   hasher,
+	}
+}
+
+// restores the hash state of the last checkpoint and returns the offset from where hashing continues, 0 when there is no usable checkpoint
+func (c *hashCheckpointer) restore(ctx context.Context) int64 {
+	cached, ok, err := config.GetFromCache(ctx, c.key)
+	if err != nil || !ok {
+		return 0
+	}
+	checkpoint := hashCheckpoint{}
+	err = json.Unmarshal([]byte(cached), &checkpoint)
+	if err != nil || checkpoint.FileSize != c.fileSize || checkpoint.Offset <= 0 || checkpoint.Offset >= c.fileSize {
+		return 0
+	}
+	// Reset would drop what was already written to the hasher (e.g., the header of the git hash), the initial state is restored instead
+	initial, err := c.hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return 0
+	}
+	err = c.hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(checkpoint.State)
+	if err != nil {
+		c.hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(initial)
+		return 0
+	}
+	return checkpoint.Offset
+}
+
+func (c *hashCheckpointer) save(ctx context.Context, offset int64) {
+	state, err := c.hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		logging.Logger.Printf("%v: saving hash checkpoint failed: %v\n", c.key, err)
+		return
+	}
+	b, _ := json.Marshal(hashCheckpoint{FileSize: c.fileSize, Offset: offset, State: state})
+	config.GetRedis().Set(ctx, c.key, string(b), hashCheckpointRetention)
+}
+
+func (c *hashCheckpointer) clear(ctx context.Context) {
+	config.GetRedis().Del(ctx, c.key)
+}
+
+// hashes the reader (positioned at the offset) in chunks of the checkpoint interval, saving a checkpoint after each chunk
+func (c *hashCheckpointer) hash(ctx context.Context, reader io.Reader, offset int64) error {
+	for {
+		n, err := io.CopyN(c.hasher, reader, c.interval-offset%c.interval)
+		offset += n
+		if err == io.EOF {
+			c.clear(ctx)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c.save(ctx, offset)
+	}
+}
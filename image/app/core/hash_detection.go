@@ -0,0 +1,63 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"integration/app/config"
+	"integration/app/logging"
+	"integration/app/tree"
+	"sort"
+)
+
+// the hash type of most files in the dataset (the configured default wins a tie and is used for an empty dataset), mixed is set when the files use different hash types
+func DatasetHashType(nm map[string]tree.Node) (hashType string, mixed bool) {
+	counts := map[string]int{}
+	for _, v := range nm {
+		if t := v.Attributes.DestinationFile.HashType; t != "" {
+			counts[t]++
+		}
+	}
+	hashType = config.GetConfig().Options.DefaultHash
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		if counts[t] > counts[hashType] {
+			hashType = t
+		}
+	}
+	return hashType, len(counts) > 1
+}
+
+// the hash type for new files and for the hashes calculated by the sources: the configured default,
+// or the hash type used by the dataset when detectDatasetHash is set
+func PreferredHashType(persistentId string, nm map[string]tree.Node) (hashType string, mixed bool) {
+	if !config.GetConfig().Options.DetectDatasetHash {
+		return config.GetConfig().Options.DefaultHash, false
+	}
+	hashType, mixed = DatasetHashType(nm)
+	if mixed {
+		logging.Logger.Printf("WARNING: %v: the files in the dataset use different hash types (mixed fixity), using %v\n", persistentId, hashType)
+	}
+	return hashType, mixed
+}
+
+// the hash type of the files written by the job, the listing of the dataset is only needed when detectDatasetHash is set
+func jobHashType(ctx context.Context, dest DestinationPlugin, job Job) (string, error) {
+	if !config.GetConfig().Options.DetectDatasetHash {
+		return config.GetConfig().Options.DefaultHash, nil
+	}
+	nm, ok := getCachedListing(ctx, job)
+	if !ok {
+		var err error
+		nm, err = dest.Query(ctx, job.PersistentId, job.DataverseKey, job.User)
+		if err != nil {
+			return "", err
+		}
+	}
+	hashType, _ := PreferredHashType(job.PersistentId, nm)
+	return hashType, nil
+}
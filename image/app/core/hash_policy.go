@@ -0,0 +1,41 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"strings"
+)
+
+// not every hash reported by a source can be compared with the content: these are the "soft" hash types and values,
+// new ones are added here and are then handled consistently by the compare, the rehashing and the verification after writing
+type remoteHashCheck int
+
+const (
+	verifyRemoteHash       remoteHashCheck = iota // the hash calculated while writing must equal the hash reported by the source
+	keepRemoteHash                                // the hash can not be calculated from the content (last modified, git hash when the size is not known): the reported hash is kept
+	keepCalculatedHash                            // the source did not calculate the hash (types.NotNeeded, the file is not at the destination): the calculated hash is kept
+	warnRemoteHashMismatch                        // the hashes of the source are not always correct (quickXorHash of some SharePoint files): a mismatch is only logged
+)
+
+// the hash of the written content (size is the number of bytes written) is checked against the hash of the source as returned here
+func remoteHashCheckFor(node tree.Node, size int64) remoteHashCheck {
+	hashType := node.Attributes.RemoteHashType
+	switch {
+	case !isContentHash(hashType), hashType == types.GitHash && size != node.Attributes.RemoteFileSize:
+		// if we do not know the filesize before calculating the hash (not provided by the source and not probed), we can't calculate the git hash
+		return keepRemoteHash
+	case node.Attributes.RemoteHash == types.NotNeeded:
+		return keepCalculatedHash
+	case hashType == types.QuickXorHash:
+		return warnRemoteHashMismatch
+	}
+	return verifyRemoteHash
+}
+
+// the last modified time in the file system of the source, the ETag of a WebDAV server and the commit hash of a Bitbucket file
+// can not be calculated from the content at the destination
+func isContentHash(hashType string) bool {
+	return !strings.EqualFold(hashType, types.LastModified) && !strings.EqualFold(hashType, types.ETag) && !strings.EqualFold(hashType, types.GitCommit)
+}
@@ -0,0 +1,107 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"testing"
+)
+
+func TestRemoteHashCheckFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		hashType string
+		hash     string
+		size     int64
+		expected remoteHashCheck
+	}{
+		{"md5", types.Md5, md5Hex("content"), 7, verifyRemoteHash},
+		{"not needed", types.Md5, types.NotNeeded, 7, keepCalculatedHash},
+		{"last modified", types.LastModified, "2024-01-01T00:00:00Z", 7, keepRemoteHash},
+		{"etag", types.ETag, "\"5d41402a\"", 7, keepRemoteHash},
+		{"etag lower case", "etag", "\"5d41402a\"", 7, keepRemoteHash},
+		{"git commit", types.GitCommit, "9fceb02d0ae598e95dc970b74767f19372d61af8", 7, keepRemoteHash},
+		{"file size", types.FileSize, "0700000000000000", 7, verifyRemoteHash},
+		{"git hash of known size", types.GitHash, "08cf6101416f0ce0dda3c80e627f333854c4085c", 7, verifyRemoteHash},
+		{"git hash of unknown size", types.GitHash, "08cf6101416f0ce0dda3c80e627f333854c4085c", 8, keepRemoteHash},
+		{"quickXorHash", types.QuickXorHash, "AAAAAAAAAAAAAAAAAAAAAAAAAAA=", 7, warnRemoteHashMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := tree.Node{Attributes: tree.Attributes{RemoteHash: tt.hash, RemoteHashType: tt.hashType, RemoteFileSize: 7}}
+			if got := remoteHashCheckFor(node, tt.size); got != tt.expected {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompareSoftHashes(t *testing.T) {
+	useMiniredis(t)
+	useOptions(t, config.OptionalConfig{})
+	registerMock(t, "mock compare", newMockDestination())
+	const pid = "doi:10.5072/FK2/COMPARE"
+	stored := md5Hex("stored content")
+	sizeHash := fmt.Sprintf("%x", (&FileSizeHash{FileSize: 14}).Sum(nil))
+
+	tests := []struct {
+		name           string
+		hashType       string
+		hash           string
+		known          string // hash of the stored file of the same type as at the source, known after rehashing
+		expected       int
+		expectedReason string
+	}{
+		{"not needed", types.Md5, types.NotNeeded, "", tree.Updated, tree.ContentDiffers},
+		{"last modified equal", types.LastModified, "2024-01-01T00:00:00Z", "2024-01-01T00:00:00Z", tree.Equal, ""},
+		{"last modified differs", types.LastModified, "2024-02-01T00:00:00Z", "2024-01-01T00:00:00Z", tree.Updated, tree.ContentDiffers},
+		{"etag equal", types.ETag, "\"v1\"", "\"v1\"", tree.Equal, ""},
+		{"etag differs", types.ETag, "\"v2\"", "\"v1\"", tree.Updated, tree.ContentDiffers},
+		{"git commit equal", types.GitCommit, "9fceb02d", "9fceb02d", tree.Equal, ""},
+		{"git commit differs", types.GitCommit, "a1b2c3d4", "9fceb02d", tree.Updated, tree.ContentDiffers},
+		{"file size equal", types.FileSize, sizeHash, sizeHash, tree.Equal, ""},
+		{"file size differs", types.FileSize, fmt.Sprintf("%x", (&FileSizeHash{FileSize: 15}).Sum(nil)), sizeHash, tree.Updated, tree.ContentDiffers},
+		{"not rehashed yet", types.LastModified, "2024-01-01T00:00:00Z", "", tree.Unknown, tree.PendingRehash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			known := map[string]calculatedHashes{}
+			if tt.known != "" {
+				known["file.txt"] = calculatedHashes{LocalHashType: types.Md5, LocalHashValue: stored, RemoteHashes: map[string]string{tt.hashType: tt.known}}
+			}
+			storeKnownHashes(context.Background(), pid, known)
+			in := map[string]tree.Node{"file.txt": {
+				Id:   "file.txt",
+				Name: "file.txt",
+				Attributes: tree.Attributes{
+					IsFile:          true,
+					RemoteHash:      tt.hash,
+					RemoteHashType:  tt.hashType,
+					DestinationFile: tree.DestinationFile{Id: 1, Hash: stored, HashType: types.Md5, FileSize: 14},
+				},
+			}}
+			res := Compare(context.Background(), in, "mock compare", pid, "token", "user", false)
+			if len(res.Data) != 1 {
+				t.Fatalf("expected 1 node, got %v", res.Data)
+			}
+			if got := res.Data[0]; got.Status != tt.expected || got.StatusReason != tt.expectedReason {
+				t.Errorf("got status %v (%q), expected %v (%q)", got.Status, got.StatusReason, tt.expected, tt.expectedReason)
+			}
+		})
+	}
+
+	t.Run("not needed new file", func(t *testing.T) {
+		in := map[string]tree.Node{"new.txt": {
+			Id:         "new.txt",
+			Attributes: tree.Attributes{IsFile: true, RemoteHash: types.NotNeeded, RemoteHashType: types.Md5},
+		}}
+		res := Compare(context.Background(), in, "mock compare", pid, "token", "user", false)
+		if len(res.Data) != 1 || res.Data[0].Status != tree.New {
+			t.Errorf("expected a new file, got %v", res.Data)
+		}
+	})
+}
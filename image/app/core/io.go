@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"hash"
 	"integration/app/config"
+	"integration/app/logging"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"io"
@@ -25,6 +26,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
 )
 
 func (r hashingReader) Read(buf []byte) (n int, err error) {
@@ -77,6 +80,10 @@ func getHash(hashType string, fileSize int64) (hasher hash.Hash, err error) {
 		hasher = sha256.New()
 	} else if lowerHashType == strings.ToLower(types.SHA512) {
 		hasher = sha512.New()
+	} else if lowerHashType == strings.ToLower(types.Blake2b) {
+		hasher, err = blake2b.New512(nil)
+	} else if lowerHashType == strings.ToLower(types.SHA3_256) {
+		hasher = sha3.New256()
 	} else if lowerHashType == strings.ToLower(types.GitHash) {
 		hasher = sha1.New()
 		hasher.Write([]byte(fmt.Sprintf("blob %d\x00", fileSize)))
@@ -103,70 +110,148 @@ func newS3Client(ctx context.Context) (*s3.Client, error) {
 	}), nil
 }
 
-func write(ctx context.Context, dbId int64, dataverseKey, user string, fileStream types.Stream, storageIdentifier, persistentId, hashType, remoteHashType, id string, fileSize int64) (hash []byte, remoteHash []byte, size int64, retErr error) {
+const (
+	defaultMaxPartSize      = 5 * 1024 * 1024 * 1024 // S3 limit
+	unknownFileSizePartSize = 1024 * 1024 * 1024
+	defaultS3Concurrency    = 2
+)
+
+func maxUploadParts() int32 {
+	if n := config.GetConfig().Options.S3Config.MaxUploadParts; n > 0 && n < manager.MaxUploadParts {
+		return n
+	}
+	return manager.MaxUploadParts
+}
+
+func uploadConcurrency() int {
+	if n := config.GetConfig().Options.S3Config.UploadConcurrency; n > 0 {
+		return n
+	}
+	return defaultS3Concurrency
+}
+
+// the parts are buffered in memory (part size times concurrency): the part size is kept as small as possible
+// while staying within the maximum number of parts; when the file size is not known, 1 GiB parts are used
+func uploadPartSize(fileSize int64) int64 {
+	minSize := max(config.GetConfig().Options.S3Config.MinPartSize, manager.MinUploadPartSize)
+	maxSize := int64(defaultMaxPartSize)
+	if s := config.GetConfig().Options.S3Config.MaxPartSize; s > 0 {
+		maxSize = max(s, minSize)
+	}
+	partSize := int64(unknownFileSizePartSize)
+	if fileSize > 0 {
+		parts := int64(maxUploadParts())
+		partSize = (fileSize + parts - 1) / parts
+	}
+	return min(max(partSize, minSize), maxSize)
+}
+
+func usePresignedUrls(dest DestinationPlugin) bool {
+	return config.GetConfig().Options.S3Config.UsePresignedUrls && dest.UploadToPresignedUrl != nil
+}
+
+// returns the storage identifier of the written file, this differs from the requested one when the storage identifier is issued by the destination (presigned URLs)
+func write(ctx context.Context, dest DestinationPlugin, dbId int64, dataverseKey, user string, fileStream types.Stream, storageIdentifier, persistentId, hashType, remoteHashType, id string, categories []string, description string, fileSize int64) (contentHash []byte, remoteHash []byte, size int64, storageId string, retErr error) {
 	pid, err := trimProtocol(persistentId)
-	if err != nil {
-		return nil, nil, 0, err
+	if err != nil && dest.IsDirectUpload() { // the protocol is only needed for the storage path
+		return nil, nil, 0, "", err
 	}
 	s := getStorage(storageIdentifier)
 	hasher, err := getHash(hashType, fileSize)
 	if err != nil {
-		return nil, nil, 0, err
+		return nil, nil, 0, "", err
 	}
 	sizeHasher := &FileSizeHash{}
 	remoteHasher, err := getHash(remoteHashType, fileSize)
 	if err != nil {
-		return nil, nil, 0, err
+		return nil, nil, 0, "", err
 	}
+	// a failed upload is not retried here: the whole write is retried (see maxFileAttempts), streaming the file again with new hashers
 	readStream, err := fileStream.Open()
 	if err != nil {
-		return nil, nil, 0, err
+		return nil, nil, 0, "", err
 	}
 	defer fileStream.Close()
 	reader := hashingReader{readStream, hasher}
 	reader = hashingReader{reader, sizeHasher}
 	reader = hashingReader{reader, remoteHasher}
 
-	if s.driver == "file" || !Destination.IsDirectUpload() {
+	if s.driver == "file" || !dest.IsDirectUpload() {
 		wg := &sync.WaitGroup{}
 		async_err := &ErrorHolder{}
-		f, err := getFile(ctx, dbId, wg, dataverseKey, user, persistentId, pid, s, id, async_err)
+		f, err := getFile(ctx, dest, dbId, wg, dataverseKey, user, persistentId, pid, s, id, categories, description, async_err)
 		if err != nil {
-			return nil, nil, 0, err
+			return nil, nil, 0, "", err
 		}
 		_, err_copy := io.Copy(f, reader)
 		err_close := f.Close()
 		wg.Wait()
-		if err_copy != nil || err_close != nil || async_err.Err != nil {
-			return nil, nil, 0, fmt.Errorf("writing failed: %v: %v: %v", err_close, err_copy, async_err.Err)
+		if pf, ok := f.(partFile); ok {
+			err_finish := pf.finish(err_copy != nil || err_close != nil)
+			if err_finish != nil && err_copy == nil && err_close == nil {
+				return nil, nil, 0, "", fmt.Errorf("writing failed: %v", err_finish)
+			}
+		}
+		if async_err.Err != nil {
+			return nil, nil, 0, "", fmt.Errorf("writing failed: %v: %v: %w", err_close, err_copy, async_err.Err)
+		}
+		if err_copy != nil || err_close != nil {
+			return nil, nil, 0, "", fmt.Errorf("writing failed: %v: %v: %v", err_close, err_copy, async_err.Err)
+		}
+	} else if s.driver == "s3" && usePresignedUrls(dest) {
+		storageIdentifier, err = uploadToPresignedUrl(ctx, dest, dataverseKey, user, persistentId, reader, fileSize)
+		if err != nil {
+			return nil, nil, 0, "", err
 		}
 	} else if s.driver == "s3" {
 		client, err := newS3Client(ctx)
 		if err != nil {
-			return nil, nil, 0, err
+			return nil, nil, 0, "", err
 		}
 		uploader := manager.NewUploader(client)
-		uploader.PartSize = 1024 * 1024 * 1024
-		uploader.MaxUploadParts = 1000
-		uploader.Concurrency = 2
+		uploader.PartSize = uploadPartSize(fileSize)
+		uploader.MaxUploadParts = maxUploadParts()
+		uploader.Concurrency = uploadConcurrency()
 		_, err = uploader.Upload(ctx, &s3.PutObjectInput{
 			Bucket: aws.String(s.bucket),
 			Key:    aws.String(pid + "/" + s.filename),
 			Body:   reader,
 		})
 		if err != nil {
-			return nil, nil, 0, err
+			return nil, nil, 0, "", err
 		}
 	} else {
-		return nil, nil, 0, fmt.Errorf("unsupported driver: %s", s.driver)
+		return nil, nil, 0, "", fmt.Errorf("unsupported driver: %s", s.driver)
 	}
 
-	return hasher.Sum(nil), remoteHasher.Sum(nil), sizeHasher.FileSize, nil
+	return hasher.Sum(nil), remoteHasher.Sum(nil), sizeHasher.FileSize, storageIdentifier, nil
 }
 
-func getFile(ctx context.Context, dbId int64, wg *sync.WaitGroup, dataverseKey, user, persistentId, pid string, s storage, id string, async_err *ErrorHolder) (io.WriteCloser, error) {
-	if !Destination.IsDirectUpload() {
-		return Destination.WriteOverWire(ctx, dbId, id, dataverseKey, user, persistentId, wg, async_err)
+// presigned URLs are requested for a given size: when the size is not known, the file is first spooled to the temp dir
+func uploadToPresignedUrl(ctx context.Context, dest DestinationPlugin, dataverseKey, user, persistentId string, reader io.Reader, fileSize int64) (string, error) {
+	if fileSize > 0 {
+		return dest.UploadToPresignedUrl(ctx, dataverseKey, user, persistentId, reader, fileSize)
+	}
+	f, err := os.CreateTemp(config.GetTempDir(), "upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, reader)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	return dest.UploadToPresignedUrl(ctx, dataverseKey, user, persistentId, f, size)
+}
+
+func getFile(ctx context.Context, dest DestinationPlugin, dbId int64, wg *sync.WaitGroup, dataverseKey, user, persistentId, pid string, s storage, id string, categories []string, description string, async_err *ErrorHolder) (io.WriteCloser, error) {
+	if !dest.IsDirectUpload() {
+		return dest.WriteOverWire(ctx, dbId, id, categories, description, dataverseKey, user, persistentId, wg, async_err)
 	}
 	path := config.GetConfig().Options.PathToFilesDir + pid + "/"
 	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
@@ -176,21 +261,21 @@ func getFile(ctx context.Context, dbId int64, wg *sync.WaitGroup, dataverseKey,
 		}
 	}
 	file := path + s.filename
-	f, err := os.Create(file)
+	f, err := os.Create(file + ".part")
 	if err != nil {
 		return nil, err
 	}
-	return f, nil
+	return partFile{f, file}, nil
 }
 
-func doHash(ctx context.Context, dataverseKey, user, persistentId string, node tree.Node) ([]byte, error) {
+func doHash(ctx context.Context, dest DestinationPlugin, dataverseKey, user, persistentId string, node tree.Node) ([]byte, error) {
 	pid, err := trimProtocol(persistentId)
-	if err != nil {
+	if err != nil && dest.IsDirectUpload() {
 		return nil, err
 	}
 	storageIdentifier := node.Attributes.DestinationFile.StorageIdentifier
 	hashType := node.Attributes.RemoteHashType
-	if strings.EqualFold(hashType, types.LastModified) {
+	if !isContentHash(hashType) {
 		return []byte("unknown"), nil
 	}
 	hasher, err := getHash(hashType, node.Attributes.DestinationFile.FileSize)
@@ -198,9 +283,12 @@ func doHash(ctx context.Context, dataverseKey, user, persistentId string, node t
 		return nil, err
 	}
 	s := getStorage(storageIdentifier)
+	checkpointer := newHashCheckpointer(storageIdentifier, hashType, node.Attributes.DestinationFile.FileSize, hasher)
+	offset := int64(0)
 	var reader io.Reader
-	if !Destination.IsDirectUpload() {
-		readCloser, err := Destination.GetStream(ctx, dataverseKey, user, node.Attributes.DestinationFile.Id)
+	if !dest.IsDirectUpload() || (s.driver == "s3" && usePresignedUrls(dest)) { // no S3 credentials when using presigned URLs
+		checkpointer = nil // the stream can not start at an offset
+		readCloser, err := dest.GetStream(ctx, dataverseKey, user, node.Attributes.DestinationFile.Id)
 		if err != nil {
 			return nil, err
 		}
@@ -213,17 +301,32 @@ func doHash(ctx context.Context, dataverseKey, user, persistentId string, node t
 			return nil, err
 		}
 		defer f.Close()
+		if checkpointer != nil {
+			offset = checkpointer.restore(ctx)
+		}
+		if offset > 0 {
+			_, err = f.Seek(offset, io.SeekStart)
+			if err != nil {
+				return nil, err
+			}
+		}
 		reader = f
 	} else if s.driver == "s3" {
 		client, err := newS3Client(ctx)
 		if err != nil {
 			return nil, err
 		}
-		rawObject, err := client.GetObject(ctx,
-			&s3.GetObjectInput{
-				Bucket: aws.String(s.bucket),
-				Key:    aws.String(pid + "/" + s.filename),
-			})
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(pid + "/" + s.filename),
+		}
+		if checkpointer != nil {
+			offset = checkpointer.restore(ctx)
+		}
+		if offset > 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+		}
+		rawObject, err := client.GetObject(ctx, input)
 		if err != nil {
 			return nil, err
 		}
@@ -233,6 +336,13 @@ func doHash(ctx context.Context, dataverseKey, user, persistentId string, node t
 		return nil, fmt.Errorf("unsupported driver: %s", s.driver)
 	}
 
+	if offset > 0 {
+		logging.Logger.Printf("%v: hashing %v continues from checkpoint at %d bytes\n", persistentId, node.Id, offset)
+	}
+	if checkpointer != nil {
+		err = checkpointer.hash(ctx, reader, offset)
+		return hasher.Sum(nil), err
+	}
 	r := hashingReader{reader, hasher}
 	_, err = io.Copy(io.Discard, r)
 	return hasher.Sum(nil), err
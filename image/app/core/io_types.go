@@ -6,6 +6,7 @@ import (
 	"hash"
 	"io"
 	"mime/multipart"
+	"os"
 )
 
 type storage struct {
@@ -23,6 +24,20 @@ type ErrorHolder struct {
 	Err error
 }
 
+// file written under a temporary name, renamed to its final name only after a successful write:
+// an interrupted write never leaves a partial file under the final name
+type partFile struct {
+	*os.File
+	target string
+}
+
+func (p partFile) finish(failed bool) error {
+	if failed {
+		return os.Remove(p.Name())
+	}
+	return os.Rename(p.Name(), p.target)
+}
+
 type WriterCloser struct {
 	writer io.Writer
 	closer io.Closer
@@ -59,7 +74,7 @@ func (f *FileWriter) Write(p []byte) (int, error) {
 		part1, _ := f.writer.CreateFormField("jsonData")
 		part1.Write(f.part1bytes)
 		f.part1written = true
-		f.part2, _ = f.writer.CreateFormFile("file", f.filename)
+		f.part2, _ = createFormFile(f.writer, "file", f.filename)
 	}
 	n, err := f.part2.Write(p)
 	return n, err
@@ -5,6 +5,7 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"integration/app/config"
 	"integration/app/logging"
@@ -14,7 +15,74 @@ import (
 	"time"
 )
 
-const maxErrors = 100
+const defaultMaxJobAttempts = 100
+const defaultJobRetryBackoff = 10 * time.Second
+const maxJobRetryBackoff = 5 * time.Minute
+
+// errors that would occur again when retrying the job (e.g., no permission), the job is not retried
+type PermanentError struct {
+	Err error
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return PermanentError{err}
+}
+
+func isPermanent(err error, job Job) bool {
+	if errors.As(err, &PermanentError{}) {
+		return true
+	}
+	// the lock has expired, retrying would fail on the deadline anyway
+	return !job.Deadline.IsZero() && time.Now().After(job.Deadline)
+}
+
+func maxJobAttempts() int {
+	if n := config.GetConfig().Options.MaxJobAttempts; n > 0 {
+		return n
+	}
+	return defaultMaxJobAttempts
+}
+
+func retryBackoff(attempt int) time.Duration {
+	backoff := defaultJobRetryBackoff
+	if s := config.GetConfig().Options.JobRetryBackoff; s > 0 {
+		backoff = time.Duration(s) * time.Second
+	}
+	for i := 1; i < attempt && backoff < maxJobRetryBackoff; i++ {
+		backoff = backoff * 2
+	}
+	if backoff > maxJobRetryBackoff {
+		backoff = maxJobRetryBackoff
+	}
+	return backoff
+}
+
+// number of failed attempts of the job currently running for the dataset, reported in the compare response
+func GetFailedAttempts(ctx context.Context, persistentId string) int {
+	n, _ := config.GetRedis().Get(ctx, "attempts: "+persistentId).Int()
+	return n
+}
+
+func setFailedAttempts(persistentId string, n int) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	if n == 0 {
+		config.GetRedis().Del(ctx, "attempts: "+persistentId)
+		return
+	}
+	config.GetRedis().Set(ctx, "attempts: "+persistentId, n, config.LockMaxDuration)
+}
 
 type Job struct {
 	DataverseKey       string
@@ -30,6 +98,13 @@ type Job struct {
 	SendEmailOnSuccess bool
 	Key                string
 	Queue              string
+	Destination        string
+	CompareKey         string   // key of the compare preceding the store, its cached listing of the dataset is reused
+	Vanished           []string // files skipped because they were removed from the source after the compare
+	Conflicts          []string // files skipped because they were modified in the dataset after the compare (see replaceConflicts in the backend config)
+	Failed             []string // files skipped because writing kept failing (see maxFileAttempts in the backend config), also in the dead letters
+	Thumbnail          string   // copied image to set as dataset thumbnail (see setDatasetThumbnail in the backend config), the first copied image when empty
+	HashType           string   // hash type of the written files, set when the job starts
 }
 
 var Stop = make(chan struct{})
@@ -59,9 +134,18 @@ func AddJob(ctx context.Context, job Job) error {
 	if len(job.WritableNodes) == 0 {
 		return nil
 	}
+	if _, err := GetDestination(job.Destination); err != nil {
+		return err
+	}
 	err := addJob(ctx, job, true)
 	if err == nil {
+		setJobState(ctx, job, JobQueued)
+		addUserJob(ctx, job)
 		logging.Logger.Println("job added for " + job.PersistentId)
+		if job.Plugin != "hash-only" {
+			resetJobLog(ctx, job.PersistentId)
+		}
+		jobLog(job.PersistentId, "info", "", "job added with %v file(s)", len(job.WritableNodes))
 	}
 	return err
 }
@@ -80,22 +164,50 @@ func addJob(ctx context.Context, job Job, requireLock bool) error {
 	if err != nil {
 		return err
 	}
-	key := "jobs"
-	if job.Queue != "" {
-		key = job.Queue + " " + key
-	}
-	cmd := config.GetRedis().LPush(ctx, key, string(b))
+	cmd := config.GetRedis().LPush(ctx, queueKey(job.Queue), string(b))
 	return cmd.Err()
 }
 
-func popJob(queue string) (Job, bool) {
+// puts the popped job back in the queue when the worker is stopped before it could start the job
+func requeue(job Job) {
 	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
 	defer cancel()
-	key := "jobs"
-	if queue != "" {
-		key = queue + " " + key
+	err := addJob(ctx, job, false)
+	if err != nil {
+		logging.Logger.Println("re-adding job failed:", job.PersistentId, err)
+		unlock(job.PersistentId)
 	}
-	cmd := config.GetRedis().RPop(ctx, key)
+}
+
+func setJobStateBackground(job Job, state string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	setJobState(ctx, job, state)
+}
+
+// a cancelled job is not (re)started: the lock of the dataset is released
+func skipCancelled(job Job) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	if !isCancelled(ctx, job) {
+		return false
+	}
+	logging.Logger.Printf("%v: job cancelled\n", job.PersistentId)
+	jobLog(job.PersistentId, "warning", "", "job cancelled, %v file(s) not written", len(job.WritableNodes))
+	setFailedAttempts(job.PersistentId, 0)
+	unlock(job.PersistentId)
+	setJobState(ctx, job, JobCancelled)
+	return true
+}
+
+func (job Job) destination() DestinationPlugin {
+	return destinationOrDefault(job.Destination)
+}
+
+func popJob(queue string) (Job, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	cmd := config.GetRedis().RPop(ctx, queueKey(queue))
 	err := cmd.Err()
 	if err != nil {
 		return Job{}, false
@@ -120,37 +232,75 @@ func ProcessJobs(queue string) {
 		case <-time.After(1 * time.Second):
 		}
 		job, ok := popJob(queue)
+		if ok && skipCancelled(job) {
+			continue
+		}
 		if ok {
+			release, acquired := acquirePluginSlot(job.Plugin)
+			if !acquired {
+				requeue(job)
+				return
+			}
 			persistentId := job.PersistentId
 			logging.Logger.Printf("%v: job started\n", persistentId)
+			jobLog(persistentId, "info", "", "job started (attempt %v)", job.ErrCnt+1)
+			setJobStateBackground(job, JobRunning)
+			sendJobStartedMail(job)
+			endCheckpoint := startCheckpoint(job)
+			start := time.Now()
 			var err error
 			if job.Plugin == "compute" {
 				job, err = compute(job)
 			} else {
 				job, err = doWork(job)
 			}
+			release()
+			recordJobDuration(queue, time.Since(start))
+			retry := true
+			state := JobFinished
+			if err != nil && skipCancelled(job) {
+				endCheckpoint()
+				continue
+			}
 			if err != nil {
 				job.ErrCnt = job.ErrCnt + 1
-				if job.ErrCnt == maxErrors {
-					logging.Logger.Println("job failed and will not be retried:", persistentId, err)
+				setFailedAttempts(persistentId, job.ErrCnt)
+				if job.ErrCnt >= maxJobAttempts() || isPermanent(err, job) {
+					logging.Logger.Printf("job failed after %v attempt(s) and will not be retried: %v %v\n", job.ErrCnt, persistentId, err)
+					jobLog(persistentId, "error", "", "job failed after %v attempt(s) and will not be retried: %v", job.ErrCnt, err)
 					sendJobFailedMail(err, job)
+					retry = false
+					state = JobFailed
 				} else {
-					logging.Logger.Println("job failed, but will retry:", persistentId, err)
-					time.Sleep(10 * time.Second)
+					backoff := retryBackoff(job.ErrCnt)
+					logging.Logger.Printf("job failed (attempt %v), will retry in %v: %v %v\n", job.ErrCnt, backoff, persistentId, err)
+					jobLog(persistentId, "warning", "", "job failed (attempt %v), will retry in %v: %v", job.ErrCnt, backoff, err)
+					select {
+					case <-Stop:
+					case <-time.After(backoff):
+					}
 				}
 			}
-			if len(job.WritableNodes) > 0 && job.ErrCnt < maxErrors {
+			if len(job.WritableNodes) > 0 && retry {
 				ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
 				err = addJob(ctx, job, false)
 				cancel()
 				if err != nil {
 					logging.Logger.Println("re-adding job failed (no retry):", persistentId, err)
+					setFailedAttempts(persistentId, 0)
 					unlock(persistentId)
+					setJobStateBackground(job, JobFailed)
+				} else {
+					setJobStateBackground(job, JobQueued)
 				}
 			} else {
+				setJobStateBackground(job, state)
+				setFailedAttempts(persistentId, 0)
 				unlock(persistentId)
 				logging.Logger.Printf("%v: job ended\n", persistentId)
+				jobLog(persistentId, "info", "", "job ended")
 			}
+			endCheckpoint()
 		}
 	}
 }
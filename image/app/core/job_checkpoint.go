@@ -0,0 +1,163 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"integration/app/config"
+	"integration/app/logging"
+	"integration/app/tree"
+	"sync"
+	"time"
+)
+
+// a running job is checkpointed in the cache (the job with the files that remain to be written) and its worker refreshes the heartbeat;
+// when the worker is restarted during the job, the checkpoint stays without heartbeat and the job is put back in its queue by another worker,
+// the files that were already written are then skipped by filterRedundant
+const (
+	checkpointIndexKey         = "job checkpoints"
+	checkpointHeartbeat        = time.Minute
+	checkpointStaleAfter       = 5 * time.Minute
+	checkpointRecoveryInterval = time.Minute
+)
+
+type jobCheckpoint struct {
+	Job       Job       `json:"job"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+type runningCheckpoint struct {
+	sync.Mutex
+	job     Job
+	stop    chan struct{}
+	stopped bool // no saves after the checkpoint is removed
+}
+
+var runningCheckpoints sync.Map // persistent id -> *runningCheckpoint
+
+func checkpointKey(persistentId string) string {
+	return "job checkpoint: " + persistentId
+}
+
+// checkpoints the job and refreshes its heartbeat until the returned function is called, which removes the checkpoint:
+// the job is then finished, failed, cancelled or back in the queue
+func startCheckpoint(job Job) func() {
+	c := &runningCheckpoint{job: job, stop: make(chan struct{})}
+	runningCheckpoints.Store(job.PersistentId, c)
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	config.GetRedis().LPush(ctx, checkpointIndexKey, job.PersistentId)
+	c.save()
+	go func() {
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(checkpointHeartbeat):
+				c.save()
+			}
+		}
+	}()
+	return func() {
+		close(c.stop)
+		runningCheckpoints.Delete(job.PersistentId)
+		c.Lock()
+		defer c.Unlock()
+		c.stopped = true
+		removeCheckpoint(job.PersistentId)
+	}
+}
+
+// called by the job while writing: only the files that remain to be written are kept in the checkpoint, together with the pending files
+// (written with direct upload but not yet registered in the dataset), such that a recovered job writes and registers them again
+func checkpointJob(job Job, pending []tree.Node) {
+	v, ok := runningCheckpoints.Load(job.PersistentId)
+	if !ok {
+		return
+	}
+	c := v.(*runningCheckpoint)
+	remaining := make(map[string]tree.Node, len(job.WritableNodes))
+	for k, n := range job.WritableNodes {
+		remaining[k] = n
+	}
+	for _, n := range pending {
+		remaining[n.Id] = n
+	}
+	c.Lock()
+	c.job = job
+	c.job.WritableNodes = remaining
+	c.Unlock()
+	c.save()
+}
+
+func (c *runningCheckpoint) save() {
+	c.Lock()
+	defer c.Unlock()
+	if c.stopped {
+		return
+	}
+	b, err := json.Marshal(jobCheckpoint{Job: c.job, Heartbeat: time.Now()})
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	config.GetRedis().Set(ctx, checkpointKey(c.job.PersistentId), string(b), config.LockMaxDuration)
+}
+
+func removeCheckpoint(persistentId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	config.GetRedis().Del(ctx, checkpointKey(persistentId))
+	config.GetRedis().LRem(ctx, checkpointIndexKey, 0, persistentId)
+}
+
+// runs in each worker process until the workers are stopped
+func RecoverInterruptedJobs() {
+	for {
+		recoverInterruptedJobs()
+		select {
+		case <-Stop:
+			return
+		case <-time.After(checkpointRecoveryInterval):
+		}
+	}
+}
+
+func recoverInterruptedJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	persistentIds, err := config.GetRedis().LRange(ctx, checkpointIndexKey, 0, -1).Result()
+	if err != nil {
+		return
+	}
+	for _, persistentId := range persistentIds {
+		cached, ok, err := config.GetFromCache(ctx, checkpointKey(persistentId))
+		if err != nil {
+			continue
+		}
+		checkpoint := jobCheckpoint{}
+		if !ok || json.Unmarshal([]byte(cached), &checkpoint) != nil {
+			// expired: the lock of the dataset has expired as well
+			config.GetRedis().LRem(ctx, checkpointIndexKey, 0, persistentId)
+			continue
+		}
+		if time.Since(checkpoint.Heartbeat) < checkpointStaleAfter {
+			continue
+		}
+		// only one worker recovers the job
+		if !config.GetRedis().SetNX(ctx, "job recovery: "+persistentId, true, checkpointStaleAfter).Val() {
+			continue
+		}
+		job := checkpoint.Job
+		logging.Logger.Printf("%v: recovering interrupted job with %v remaining file(s)\n", persistentId, len(job.WritableNodes))
+		jobLog(persistentId, "warning", "", "the worker was interrupted, job put back in the queue with %v remaining file(s)", len(job.WritableNodes))
+		if err := addJob(ctx, job, false); err != nil {
+			logging.Logger.Printf("%v: recovering interrupted job failed: %v\n", persistentId, err)
+			continue
+		}
+		setJobState(ctx, job, JobQueued)
+		removeCheckpoint(persistentId)
+	}
+}
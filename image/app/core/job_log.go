@@ -0,0 +1,73 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"integration/app/config"
+	"time"
+)
+
+const defaultJobLogMaxEntries = 1000
+const defaultJobLogRetention = 7 * 24 * time.Hour
+
+// entries of the log of the jobs of one dataset, kept in Redis such that the users can see why files failed without server access
+type JobLogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`          // "info", "warning" or "error"
+	File    string    `json:"file,omitempty"` // id of the file the entry is about, if any
+	Message string    `json:"message"`
+}
+
+func jobLogKey(persistentId string) string {
+	return "job log: " + persistentId
+}
+
+func jobLogMaxEntries() int {
+	if n := config.GetConfig().Options.JobLogMaxEntries; n > 0 {
+		return n
+	}
+	return defaultJobLogMaxEntries
+}
+
+func jobLogRetention() time.Duration {
+	if s := config.GetConfig().Options.JobLogRetention; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultJobLogRetention
+}
+
+// adds an entry to the job log of the dataset, a Redis list with the most recent entry first of which only the most recent entries are kept;
+// logging is best effort, the entries can be added concurrently by the workers writing the files
+func jobLog(persistentId, level, file, format string, a ...any) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	b, _ := json.Marshal(JobLogEntry{Time: time.Now(), Level: level, File: file, Message: fmt.Sprintf(format, a...)})
+	key := jobLogKey(persistentId)
+	config.GetRedis().LPush(ctx, key, string(b))
+	config.GetRedis().LTrim(ctx, key, 0, int64(jobLogMaxEntries()-1))
+	config.GetRedis().Expire(ctx, key, jobLogRetention())
+}
+
+// a new job starts with an empty log, the retries of a job keep adding to the same log
+func resetJobLog(ctx context.Context, persistentId string) {
+	config.GetRedis().Del(ctx, jobLogKey(persistentId))
+}
+
+// returns the entries of the job log of the dataset, oldest first
+func GetJobLog(ctx context.Context, persistentId string) ([]JobLogEntry, error) {
+	values, err := config.GetRedis().LRange(ctx, jobLogKey(persistentId), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := []JobLogEntry{}
+	for i := len(values) - 1; i >= 0; i-- {
+		e := JobLogEntry{}
+		if json.Unmarshal([]byte(values[i]), &e) == nil {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
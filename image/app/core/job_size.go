@@ -0,0 +1,35 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"integration/app/tree"
+)
+
+var ErrJobTooLarge = errors.New("job too large")
+
+// number of bytes copied from the source by the job, the sizes that are not known (not listed and not yet probed) count as 0
+func jobTotalBytes(nodes map[string]tree.Node) int64 {
+	var total int64
+	for _, v := range nodes {
+		if v.Action == tree.Copy || v.Action == tree.Update {
+			total += v.Attributes.RemoteFileSize
+		}
+	}
+	return total
+}
+
+// refuses jobs copying more than maxJobTotalBytes, checked when the job is added and again once the file sizes are probed
+func CheckJobTotalSize(nodes map[string]tree.Node) error {
+	maxTotal := config.GetConfig().Options.MaxJobTotalBytes
+	if maxTotal <= 0 {
+		return nil
+	}
+	if total := jobTotalBytes(nodes); total > maxTotal {
+		return fmt.Errorf("%w: the selected files total %d bytes, more than the maximum of %d bytes per job: please select fewer files", ErrJobTooLarge, total, maxTotal)
+	}
+	return nil
+}
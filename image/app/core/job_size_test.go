@@ -0,0 +1,43 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"errors"
+	"integration/app/config"
+	"integration/app/tree"
+	"testing"
+)
+
+func TestCheckJobTotalSize(t *testing.T) {
+	sized := func(id string, action int, size int64) tree.Node {
+		return tree.Node{Id: id, Action: action, Attributes: tree.Attributes{IsFile: true, RemoteFileSize: size}}
+	}
+	tests := []struct {
+		name     string
+		max      int64
+		nodes    []tree.Node
+		tooLarge bool
+	}{
+		{"not limited", 0, []tree.Node{sized("a", tree.Copy, 1<<40)}, false},
+		{"under", 100, []tree.Node{sized("a", tree.Copy, 40), sized("b", tree.Update, 59)}, false},
+		{"at", 100, []tree.Node{sized("a", tree.Copy, 40), sized("b", tree.Update, 60)}, false},
+		{"over", 100, []tree.Node{sized("a", tree.Copy, 40), sized("b", tree.Update, 61)}, true},
+		{"deleted files excluded", 100, []tree.Node{sized("a", tree.Copy, 100), sized("b", tree.Delete, 1000)}, false},
+		{"ignored files excluded", 100, []tree.Node{sized("a", tree.Copy, 100), sized("b", tree.Ignore, 1000)}, false},
+		{"unknown sizes count as 0", 100, []tree.Node{sized("a", tree.Copy, 100), sized("b", tree.Copy, 0)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useOptions(t, config.OptionalConfig{MaxJobTotalBytes: tt.max})
+			nodes := map[string]tree.Node{}
+			for _, n := range tt.nodes {
+				nodes[n.Id] = n
+			}
+			err := CheckJobTotalSize(nodes)
+			if errors.Is(err, ErrJobTooLarge) != tt.tooLarge || (err != nil && !tt.tooLarge) {
+				t.Errorf("got %v, expected too large: %v", err, tt.tooLarge)
+			}
+		})
+	}
+}
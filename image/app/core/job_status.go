@@ -0,0 +1,144 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"integration/app/config"
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobFinished  = "finished"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+const jobStateRetention = 24 * time.Hour
+const cancelCheckInterval = 5 * time.Second
+
+var ErrJobNotFound = errors.New("job not found")
+var ErrJobEnded = errors.New("job already ended")
+
+// state of a job with a key (store and compute jobs), kept for a day after the last change
+type JobState struct {
+	Key           string    `json:"key"`
+	PersistentId  string    `json:"persistentId"`
+	State         string    `json:"state"`
+	QueuePosition int       `json:"queuePosition,omitempty"` // 1 when the job is the next in its queue, only set for queued jobs
+	Attempts      int       `json:"attempts,omitempty"`      // failed attempts, the job is retried automatically while queued
+	Failed        []string  `json:"failed,omitempty"`        // files skipped because writing kept failing
+	Updated       time.Time `json:"updated"`
+	User          string    `json:"-"`
+	Queue         string    `json:"-"`
+	Destination   string    `json:"-"`
+}
+
+type storedJobState struct {
+	JobState
+	User        string `json:"user"`
+	Queue       string `json:"queue"`
+	Destination string `json:"destination,omitempty"`
+}
+
+func jobStateKey(key string) string {
+	return "job state: " + key
+}
+
+func setJobState(ctx context.Context, job Job, state string) {
+	if job.Key == "" {
+		return
+	}
+	b, _ := json.Marshal(storedJobState{
+		JobState:    JobState{Key: job.Key, PersistentId: job.PersistentId, State: state, Attempts: job.ErrCnt, Failed: job.Failed, Updated: time.Now()},
+		User:        job.User,
+		Queue:       job.Queue,
+		Destination: job.Destination,
+	})
+	config.GetRedis().Set(ctx, jobStateKey(job.Key), string(b), jobStateRetention)
+}
+
+func getJobState(ctx context.Context, key string) (JobState, error) {
+	cached, ok, err := config.GetFromCache(ctx, jobStateKey(key))
+	if err != nil {
+		return JobState{}, err
+	}
+	res := storedJobState{}
+	if !ok || json.Unmarshal([]byte(cached), &res) != nil {
+		return JobState{}, ErrJobNotFound
+	}
+	res.JobState.User, res.JobState.Queue, res.JobState.Destination = res.User, res.Queue, res.Destination
+	return res.JobState, nil
+}
+
+// returns the state of the job of the user, ErrJobNotFound for unknown keys and jobs of other users
+func GetJobStatus(ctx context.Context, key, user string) (JobState, error) {
+	res, err := getJobState(ctx, key)
+	if err != nil {
+		return res, err
+	}
+	if res.User != user {
+		return JobState{}, ErrJobNotFound
+	}
+	if res.State == JobQueued {
+		res.QueuePosition = queuePosition(ctx, res.Queue, key)
+	}
+	return res, nil
+}
+
+// the jobs are pushed at the head of the queue and popped from the tail
+func queuePosition(ctx context.Context, queue, key string) int {
+	jobs, err := config.GetRedis().LRange(ctx, queueKey(queue), 0, -1).Result()
+	if err != nil {
+		return 0
+	}
+	for i := len(jobs) - 1; i >= 0; i-- {
+		job := Job{}
+		if json.Unmarshal([]byte(jobs[i]), &job) == nil && job.Key == key {
+			return len(jobs) - i
+		}
+	}
+	return 0
+}
+
+// flags the job as cancelled: a queued job is skipped when popped, a running job is stopped (see watchCancellation)
+func CancelJob(ctx context.Context, key, user string) error {
+	state, err := GetJobStatus(ctx, key, user)
+	if err != nil {
+		return err
+	}
+	if state.State != JobQueued && state.State != JobRunning {
+		return ErrJobEnded
+	}
+	return config.GetRedis().Set(ctx, "cancel job: "+key, true, jobStateRetention).Err()
+}
+
+func isCancelled(ctx context.Context, job Job) bool {
+	if job.Key == "" {
+		return false
+	}
+	_, ok, _ := config.GetFromCache(ctx, "cancel job: "+job.Key)
+	return ok
+}
+
+// cancels the context of the running job when the job is cancelled, until the context is done
+func watchCancellation(ctx context.Context, cancel context.CancelFunc, job Job) {
+	if job.Key == "" {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cancelCheckInterval):
+		}
+		if isCancelled(ctx, job) {
+			cancel()
+			return
+		}
+	}
+}
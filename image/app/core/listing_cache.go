@@ -0,0 +1,70 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"integration/app/config"
+	"integration/app/logging"
+	"integration/app/tree"
+	"time"
+)
+
+const defaultListingCacheDuration = 5 * time.Minute
+
+// the listing of the destination dataset obtained during compare, reused by the store that immediately follows
+type cachedListing struct {
+	PersistentId string               `json:"persistentId"`
+	Destination  string               `json:"destination"`
+	Cached       time.Time            `json:"cached"`
+	Nodes        map[string]tree.Node `json:"nodes"`
+}
+
+func listingCacheDuration() time.Duration {
+	if s := config.GetConfig().Options.ListingCacheDuration; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultListingCacheDuration
+}
+
+func CacheListing(ctx context.Context, compareKey, destination, persistentId string, nm map[string]tree.Node) {
+	b, err := json.Marshal(cachedListing{PersistentId: persistentId, Destination: destination, Cached: time.Now(), Nodes: nm})
+	if err != nil {
+		logging.Logger.Printf("%v: caching the listing failed: %v\n", persistentId, err)
+		return
+	}
+	config.GetRedis().Set(ctx, "listing: "+compareKey, string(b), listingCacheDuration())
+}
+
+// the cached listing is only used for the first attempt of the job: a failed attempt may have changed the dataset
+func getCachedListing(ctx context.Context, job Job) (map[string]tree.Node, bool) {
+	if job.CompareKey == "" || job.ErrCnt > 0 {
+		return nil, false
+	}
+	cached, ok, err := config.GetFromCache(ctx, "listing: "+job.CompareKey)
+	if err != nil || !ok {
+		return nil, false
+	}
+	res := cachedListing{}
+	err = json.Unmarshal([]byte(cached), &res)
+	if err != nil || res.PersistentId != job.PersistentId || res.Destination != job.Destination || time.Since(res.Cached) > listingCacheDuration() {
+		return nil, false
+	}
+	return res.Nodes, true
+}
+
+// caches the listing of a compare under the key of another compare that reused its result, such that its store can use it too
+func ShareListing(ctx context.Context, fromKey, toKey string) {
+	cached, ok, err := config.GetFromCache(ctx, "listing: "+fromKey)
+	if err != nil || !ok {
+		return
+	}
+	res := cachedListing{}
+	if json.Unmarshal([]byte(cached), &res) != nil {
+		return
+	}
+	if remaining := listingCacheDuration() - time.Since(res.Cached); remaining > 0 {
+		config.GetRedis().Set(ctx, "listing: "+toKey, cached, remaining)
+	}
+}
@@ -0,0 +1,71 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"fmt"
+	"integration/app/config"
+	"integration/app/tree"
+	"sort"
+)
+
+// files with equal content can be updated without uploading the content again: only the metadata is edited
+func metadataOnlyUpdates(dest DestinationPlugin) bool {
+	return config.GetConfig().Options.MetadataOnlyUpdates && dest.UpdateFileMetadata != nil
+}
+
+// a file that is new at the source and has the same content as a file that only exists at the destination was moved or renamed:
+// the destination file is matched to the new file (MovedFrom is set) and is no longer listed as deleted
+func matchMovedFiles(in map[string]tree.Node) map[string]tree.Node {
+	deleted := map[string]string{}
+	for k, v := range in {
+		if v.Attributes.IsFile && v.Attributes.RemoteHash == "" && v.Attributes.DestinationFile.Hash != "" && v.Attributes.DestinationFile.Hash != "?" {
+			deleted[movedFileKey(v.Attributes.DestinationFile.HashType, v.Attributes.DestinationFile.Hash, v.Attributes.DestinationFile.FileSize)] = k
+		}
+	}
+	if len(deleted) == 0 {
+		return in
+	}
+	keys := []string{}
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := map[string]tree.Node{}
+	for k, v := range in {
+		res[k] = v
+	}
+	for _, k := range keys {
+		v := res[k]
+		if !v.Attributes.IsFile || v.Attributes.RemoteHash == "" || v.Attributes.DestinationFile.Hash != "" {
+			continue
+		}
+		key := movedFileKey(v.Attributes.RemoteHashType, v.Attributes.RemoteHash, v.Attributes.RemoteFileSize)
+		from, ok := deleted[key]
+		if !ok {
+			continue
+		}
+		delete(deleted, key)
+		v.Attributes.DestinationFile = res[from].Attributes.DestinationFile
+		v.Attributes.DestinationFile.MovedFrom = from
+		res[k] = v
+		delete(res, from)
+	}
+	return res
+}
+
+func movedFileKey(hashType, hash string, size int64) string {
+	return hashType + ":" + hash + ":" + fmt.Sprint(size)
+}
+
+// the content is equal, but the file is stored under another name or directory label, or the description would change
+func metadataDiffers(v tree.Node) bool {
+	description := FileDescription(v)
+	return v.Attributes.DestinationFile.MovedFrom != "" || (description != "" && description != v.Attributes.DestinationFile.Description)
+}
+
+// the node selected for an update has the same content at the destination, it only needs a metadata update
+func isMetadataOnlyUpdate(dest DestinationPlugin, v tree.Node) bool {
+	return metadataOnlyUpdates(dest) && v.Action == tree.Update && v.Attributes.DestinationFile.Id != 0 &&
+		v.Attributes.DestinationFile.HashType == v.Attributes.RemoteHashType && v.Attributes.DestinationFile.Hash == v.Attributes.RemoteHash
+}
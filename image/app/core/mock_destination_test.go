@@ -0,0 +1,167 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var errMockWrite = errors.New("destination unavailable")
+
+// in-memory destination recording the writes, flushes and deletes that reach it
+type mockDestination struct {
+	mu           sync.Mutex
+	directUpload bool
+	failing      map[string]bool      // node ids of which every write over the wire fails
+	attempts     map[string]int       // node id -> number of writes over the wire
+	listing      map[string]tree.Node // returned by Query
+	queries      int
+	written      map[string]string // node id -> content written over the wire
+	writtenDbIds map[string]int64  // node id -> id of the replaced file (0 for new files)
+	added        []string          // node ids registered after direct upload
+	replaced     []string
+	identifiers  []string // storage identifiers registered after direct upload
+	deleted      []int64
+}
+
+func newMockDestination() *mockDestination {
+	return &mockDestination{
+		listing:      map[string]tree.Node{},
+		failing:      map[string]bool{},
+		attempts:     map[string]int{},
+		written:      map[string]string{},
+		writtenDbIds: map[string]int64{},
+	}
+}
+
+type recordingWriter struct {
+	bytes.Buffer
+	close func(content string)
+}
+
+func (w *recordingWriter) Close() error {
+	w.close(w.String())
+	return nil
+}
+
+func (m *mockDestination) plugin() DestinationPlugin {
+	return DestinationPlugin{
+		IsDirectUpload: func() bool { return m.directUpload },
+		GetRepoUrl:     func(pid string, draft bool) string { return "https://repo.example.org/" + pid },
+		CheckPermission: func(ctx context.Context, token, user, persistentId string) error {
+			return nil
+		},
+		WriteOverWire: func(ctx context.Context, dbId int64, nodeMapId string, categories []string, description string, token, user, persistentId string, wg *sync.WaitGroup, async_err *ErrorHolder) (io.WriteCloser, error) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.attempts[nodeMapId]++
+			if m.failing[nodeMapId] {
+				return nil, errMockWrite
+			}
+			return &recordingWriter{close: func(content string) {
+				m.mu.Lock()
+				defer m.mu.Unlock()
+				m.written[nodeMapId] = content
+				m.writtenDbIds[nodeMapId] = dbId
+			}}, nil
+		},
+		SaveAfterDirectUpload: func(ctx context.Context, replace bool, token, user, persistentId string, storageIdentifiers []string, nodes []tree.Node) error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			for _, n := range nodes {
+				if replace {
+					m.replaced = append(m.replaced, n.Id)
+				} else {
+					m.added = append(m.added, n.Id)
+				}
+			}
+			m.identifiers = append(m.identifiers, storageIdentifiers...)
+			return nil
+		},
+		DeleteFile: func(ctx context.Context, token, user string, id int64) error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.deleted = append(m.deleted, id)
+			return nil
+		},
+		Query: func(ctx context.Context, persistentId, token, user string) (map[string]tree.Node, error) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.queries++
+			res := make(map[string]tree.Node, len(m.listing))
+			for k, v := range m.listing {
+				res[k] = v
+			}
+			return res, nil
+		},
+	}
+}
+
+// replaces the Redis client with an in-memory server for the duration of the test
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previous := config.GetRedis()
+	config.SetRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	t.Cleanup(func() { config.SetRedis(previous) })
+	return mr
+}
+
+// replaces the customizations of the backend config for the duration of the test
+func useOptions(t *testing.T, options config.OptionalConfig) {
+	t.Helper()
+	previous := config.GetConfig().Options
+	config.SetOptions(options)
+	t.Cleanup(func() { config.SetOptions(previous) })
+}
+
+func md5Hex(content string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(content)))
+}
+
+// a file at the source with the given content, with the md5 hash as reported by the source
+func sourceNode(id, content string, action int, destinationId int64) tree.Node {
+	n := tree.Node{
+		Id:     id,
+		Name:   id,
+		Action: action,
+		Attributes: tree.Attributes{
+			IsFile:          true,
+			RemoteHash:      md5Hex(content),
+			RemoteHashType:  types.Md5,
+			RemoteFileSize:  int64(len(content)),
+			DestinationFile: tree.DestinationFile{Id: destinationId},
+		},
+	}
+	if destinationId != 0 {
+		n.Status = tree.Updated
+		n.Attributes.DestinationFile.Hash = md5Hex("old " + content)
+		n.Attributes.DestinationFile.HashType = types.Md5
+	}
+	return n
+}
+
+func stringStreams(contents map[string]string) map[string]types.Stream {
+	res := map[string]types.Stream{}
+	for k, c := range contents {
+		res[k] = types.Stream{
+			Open:  func() (io.Reader, error) { return strings.NewReader(c), nil },
+			Close: func() error { return nil },
+		}
+	}
+	return res
+}
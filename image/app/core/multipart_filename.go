@@ -0,0 +1,68 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"fmt"
+	"integration/app/config"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"unicode/utf8"
+)
+
+const multipartFilenameRFC5987 = "rfc5987"
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// creates the file part of a multipart upload: by default, the file name is sent as UTF-8 in the filename parameter (RFC 7578, as browsers do);
+// with multipartFilenames set to "rfc5987", a name with non-ASCII characters is sent as an ASCII fallback in the filename parameter and
+// as the percent-encoded UTF-8 name in the filename* parameter (RFC 2231/5987), for servers that read the header as ISO-8859-1
+func createFormFile(w *multipart.Writer, fieldName, filename string) (io.Writer, error) {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", contentDisposition(fieldName, filename))
+	h.Set("Content-Type", "application/octet-stream")
+	return w.CreatePart(h)
+}
+
+func contentDisposition(fieldName, filename string) string {
+	if !strings.EqualFold(config.GetConfig().Options.MultipartFilenames, multipartFilenameRFC5987) || isASCII(filename) {
+		return fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(fieldName), quoteEscaper.Replace(filename))
+	}
+	return fmt.Sprintf(`form-data; name="%s"; filename="%s"; filename*=UTF-8''%s`,
+		quoteEscaper.Replace(fieldName), quoteEscaper.Replace(asciiFallback(filename)), rfc5987Encode(filename))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// non-ASCII characters are replaced by underscores
+func asciiFallback(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= utf8.RuneSelf {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// percent-encodes all bytes except the attr-char set of RFC 5987
+func rfc5987Encode(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
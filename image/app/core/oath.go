@@ -6,24 +6,51 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"integration/app/config"
 	"integration/app/core/types"
 	"integration/app/logging"
-	"io"
+	pluginTypes "integration/app/plugin/types"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 )
 
-var PluginConfig = map[string]config.RepoPlugin{}
-var RedirectUri string
+// set from the frontend configuration and read by the request handlers and the workers, only accessed through the functions below
+var pluginConfig = map[string]config.RepoPlugin{}
+var redirectUri string
+var oauthConfigMutex sync.RWMutex
+
+// replaces the configuration of the plugins and the OAuth redirect URI, safe to call while serving requests
+func SetOauthConfig(plugins []config.RepoPlugin, uri string) {
+	m := map[string]config.RepoPlugin{}
+	for _, v := range plugins {
+		m[v.Id] = v
+	}
+	oauthConfigMutex.Lock()
+	defer oauthConfigMutex.Unlock()
+	pluginConfig = m
+	redirectUri = uri
+}
+
+func GetPluginConfig(pluginId string) config.RepoPlugin {
+	oauthConfigMutex.RLock()
+	defer oauthConfigMutex.RUnlock()
+	return pluginConfig[pluginId]
+}
+
+func getRedirectUri() string {
+	oauthConfigMutex.RLock()
+	defer oauthConfigMutex.RUnlock()
+	return redirectUri
+}
 
 func GetOauthToken(ctx context.Context, pluginId, code, refreshToken, sessionId string) (types.TokenResponse, error) {
 	res := types.TokenResponse{SessionId: sessionId}
-	clientId := PluginConfig[pluginId].TokenGetter.OauthClientId
-	redirectUri := RedirectUri
+	clientId := GetPluginConfig(pluginId).TokenGetter.OauthClientId
 	clientSecret, resource, postUrl, exchange, err := config.ClientSecret(clientId)
 	if err != nil {
 		return res, err
@@ -32,7 +59,7 @@ func GetOauthToken(ctx context.Context, pluginId, code, refreshToken, sessionId
 	if code == "" && refreshToken != "" {
 		grantType = "refresh_token"
 	}
-	req := types.OauthTokenRequest{ClientId: clientId, ClientSecret: clientSecret, Code: code, RefreshToken: refreshToken, RedirectUri: redirectUri, GrantType: grantType, Resource: resource}
+	req := types.OauthTokenRequest{ClientId: clientId, ClientSecret: clientSecret, Code: code, RefreshToken: refreshToken, RedirectUri: getRedirectUri(), GrantType: grantType, Resource: resource}
 	request, _ := http.NewRequestWithContext(ctx, "POST", postUrl, encode(req))
 	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	request.Header.Add("Accept", "application/json")
@@ -42,10 +69,10 @@ func GetOauthToken(ctx context.Context, pluginId, code, refreshToken, sessionId
 	}
 	defer r.Body.Close()
 	if r.StatusCode != 200 {
-		b, _ := io.ReadAll(r.Body)
+		b, _ := pluginTypes.ReadAll(r.Body)
 		return res, fmt.Errorf("getting API token failed: %d - %s", r.StatusCode, string(b))
 	}
-	b, err := io.ReadAll(r.Body)
+	b, err := pluginTypes.ReadAll(r.Body)
 	if err != nil {
 		return res, fmt.Errorf("getting token response failed: %v", err)
 	}
@@ -86,9 +113,14 @@ func GetOauthToken(ctx context.Context, pluginId, code, refreshToken, sessionId
 		}
 	}
 	if exchange != "" {
-		result, err = doExchange(ctx, result, exchange)
+		exchanged, err := doExchange(ctx, result, exchange)
 		if err != nil {
-			return res, err
+			if config.ExchangeFailurePolicy(clientId) != config.ExchangeFallback {
+				return res, err
+			}
+			logging.Logger.Printf("warning: using the original token for plugin id %v: %v\n", pluginId, err)
+		} else {
+			result = exchanged
 		}
 	}
 	result.Issued = time.Now()
@@ -96,47 +128,100 @@ func GetOauthToken(ctx context.Context, pluginId, code, refreshToken, sessionId
 	if err != nil {
 		return res, err
 	}
-	config.GetRedis().Set(ctx, fmt.Sprintf("%v-%v", pluginId, sessionId), string(tokenBytes), config.LockMaxDuration)
+	stored, err := encryptToken(tokenBytes)
+	if err != nil {
+		return res, err
+	}
+	config.GetRedis().Set(ctx, fmt.Sprintf("%v-%v", pluginId, sessionId), stored, config.LockMaxDuration)
 	return res, nil
 }
 
+// returns the cached OAuth token of the session, or the given token when no OAuth token is cached (e.g., a personal token was entered)
 func GetTokenFromCache(ctx context.Context, token, sessionId, pluginId string) string {
-	res, ok := getTokenFromCache(ctx, pluginId, sessionId)
-	if !ok {
+	res, err := getOauthAccessToken(ctx, sessionId, pluginId)
+	if err != nil {
+		if usesOauth(pluginId) {
+			logging.Logger.Printf("using the token as entered for plugin id %v: %v\n", pluginId, err)
+		}
 		return token
 	}
-	expired := time.Now().After(res.Issued.Add(time.Duration((res.ExpiresIn - 5*60)) * time.Second))
-	ok = true
+	return res
+}
+
+// same as GetTokenFromCache, but fails when the plugin uses OAuth and the token of the session is missing from the cache:
+// the session id would be used as token otherwise, resulting in an unclear authentication error at the source
+func GetRequiredTokenFromCache(ctx context.Context, token, sessionId, pluginId string) (string, error) {
+	res, err := getOauthAccessToken(ctx, sessionId, pluginId)
+	if errors.Is(err, errTokenNotInCache) && usesOauth(pluginId) && token == sessionId {
+		return "", Permanent(fmt.Errorf("%v: the login has expired or was evicted from the cache, please log in again", err))
+	}
+	if err != nil && !errors.Is(err, errTokenNotInCache) {
+		return "", err
+	}
+	if err != nil {
+		return token, nil
+	}
+	return res, nil
+}
+
+var errTokenNotInCache = errors.New("OAuth token not found in the cache")
+
+// resource servers of the tokens needed by the plugin types, used when not configured for the OAuth client
+var defaultResourceServers = map[string]string{
+	"globus": "transfer.api.globus.org",
+}
+
+func resourceServer(pluginId string) string {
+	p := GetPluginConfig(pluginId)
+	if s := config.TokenResourceServer(p.TokenGetter.OauthClientId); s != "" {
+		return s
+	}
+	return defaultResourceServers[p.Plugin]
+}
+
+func usesOauth(pluginId string) bool {
+	return GetPluginConfig(pluginId).TokenGetter.OauthClientId != ""
+}
+
+func getOauthAccessToken(ctx context.Context, sessionId, pluginId string) (string, error) {
+	res, err := getTokenFromCache(ctx, pluginId, sessionId)
+	if err != nil {
+		return "", err
+	}
+	margin := config.TokenRefreshMargin(GetPluginConfig(pluginId).TokenGetter.OauthClientId)
+	expired := time.Now().After(res.Issued.Add(time.Duration(res.ExpiresIn) * time.Second).Add(-margin))
 	if expired {
 		_, err := GetOauthToken(ctx, pluginId, "", res.RefreshToken, sessionId)
 		if err != nil {
 			logging.Logger.Println("token refresh failed:", err)
-			return res.AccessToken
+			return res.AccessToken, nil
 		}
-		res, ok = getTokenFromCache(ctx, pluginId, sessionId)
-		if !ok {
-			logging.Logger.Println("token not in cache after refresh for plugin id:", pluginId)
-			return token
-		}
-	}
-	for _, t := range res.OtherTokens {
-		if t.ResourceServer == "transfer.api.globus.org" {
-			res = t
-			break
+		res, err = getTokenFromCache(ctx, pluginId, sessionId)
+		if err != nil {
+			return "", fmt.Errorf("after refresh: %w", err)
 		}
 	}
-	return res.AccessToken
+	return res.ForResourceServer(resourceServer(pluginId)).AccessToken, nil
 }
 
-func getTokenFromCache(ctx context.Context, pluginId, sessionId string) (types.OauthTokenResponse, bool) {
-	cached := config.GetRedis().Get(ctx, fmt.Sprintf("%v-%v", pluginId, sessionId))
-	jsonString := cached.Val()
-	if jsonString == "" {
-		return types.OauthTokenResponse{}, false
-	}
+func getTokenFromCache(ctx context.Context, pluginId, sessionId string) (types.OauthTokenResponse, error) {
 	res := types.OauthTokenResponse{}
-	json.Unmarshal([]byte(jsonString), &res)
-	return res, true
+	stored, ok, err := config.GetFromCache(ctx, fmt.Sprintf("%v-%v", pluginId, sessionId))
+	if err != nil {
+		return res, err
+	}
+	if !ok {
+		return res, errTokenNotInCache
+	}
+	tokenBytes, err := decryptToken(stored)
+	if err != nil {
+		return res, err
+	}
+	err = json.Unmarshal(tokenBytes, &res)
+	if err != nil {
+		return res, fmt.Errorf("cached OAuth token could not be read: %v", err)
+	}
+	return res, nil
 }
 
 func encode(req types.OauthTokenRequest) *bytes.Buffer {
@@ -160,8 +245,8 @@ func encode(req types.OauthTokenRequest) *bytes.Buffer {
 	return bytes.NewBuffer([]byte(s))
 }
 
+// returns the token with the exchanged access token, or the given token unchanged together with an error when the exchange fails
 func doExchange(ctx context.Context, in types.OauthTokenResponse, url string) (types.OauthTokenResponse, error) {
-	res := in
 	req := types.ExchangeRequest{DropPermissions: true, IdToken: in.JwtToken}
 	data, _ := json.Marshal(req)
 	body := bytes.NewBuffer(data)
@@ -170,22 +255,28 @@ func doExchange(ctx context.Context, in types.OauthTokenResponse, url string) (t
 	request.Header.Add("Accept", "application/json")
 	r, err := http.DefaultClient.Do(request)
 	if err != nil {
-		return res, fmt.Errorf("exchanging API token failed: %v", err)
+		return in, fmt.Errorf("exchanging API token failed: %v", err)
 	}
 	defer r.Body.Close()
 	if r.StatusCode != 200 {
-		b, _ := io.ReadAll(r.Body)
-		return res, fmt.Errorf("exchanging API token failed: %d - %s", r.StatusCode, string(b))
+		b, _ := pluginTypes.ReadAll(r.Body)
+		return in, fmt.Errorf("exchanging API token failed: %d - %s", r.StatusCode, string(b))
 	}
-	b, err := io.ReadAll(r.Body)
+	b, err := pluginTypes.ReadAll(r.Body)
 	if err != nil {
-		return res, fmt.Errorf("exchanging token response failed: %v", err)
+		return in, fmt.Errorf("exchanging token response failed: %v", err)
 	}
 	result := types.ExchangeResponse{}
-	err = json.Unmarshal(b, &result)
-	res.AccessToken = result.Token
+	if err = json.Unmarshal(b, &result); err != nil {
+		return in, fmt.Errorf("exchanging token response could not be parsed: %v", err)
+	}
 	if result.Message != "" {
-		return res, fmt.Errorf("exchanging token failed with message: %v", result.Message)
+		return in, fmt.Errorf("exchanging token failed with message: %v", result.Message)
+	}
+	if result.Token == "" {
+		return in, fmt.Errorf("exchanging token failed: response contains no token")
 	}
-	return res, err
+	res := in
+	res.AccessToken = result.Token
+	return res, nil
 }
@@ -0,0 +1,65 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"sync"
+	"testing"
+)
+
+// run with -race: the configuration is replaced while the request handlers read it
+func TestOauthConfigConcurrentAccess(t *testing.T) {
+	previous, previousUri := GetPluginConfig("github"), getRedirectUri()
+	t.Cleanup(func() { SetOauthConfig([]config.RepoPlugin{previous}, previousUri) })
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			SetOauthConfig([]config.RepoPlugin{{Id: "github", Name: fmt.Sprintf("GitHub %v", i)}}, fmt.Sprintf("https://example.org/%v", i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			GetPluginConfig("github")
+			getRedirectUri()
+		}()
+	}
+	wg.Wait()
+	if GetPluginConfig("github").Id != "github" || getRedirectUri() == "" {
+		t.Errorf("configuration lost: %v %v", GetPluginConfig("github"), getRedirectUri())
+	}
+}
+
+func TestGetTokenFromCache(t *testing.T) {
+	mr := useMiniredis(t)
+	useTokenEncryptionKey(t, "")
+	ctx := context.Background()
+	mr.Set("github-empty", "")
+	mr.Set("github-cached", `{"access_token":"cached token"}`)
+
+	if _, err := getTokenFromCache(ctx, "github", "missing"); !errors.Is(err, errTokenNotInCache) {
+		t.Errorf("missing: got %v, expected %v", err, errTokenNotInCache)
+	}
+	if _, err := getTokenFromCache(ctx, "github", "empty"); err == nil || errors.Is(err, errTokenNotInCache) {
+		t.Errorf("an empty value is in the cache, but can not be read, got %v", err)
+	}
+	if res, err := getTokenFromCache(ctx, "github", "cached"); err != nil || res.AccessToken != "cached token" {
+		t.Errorf("cached: got %v (%v)", res.AccessToken, err)
+	}
+	if got := GetTokenFromCache(ctx, "entered token", "missing", "github"); got != "entered token" {
+		t.Errorf("the entered token must be used when no token is cached, got %q", got)
+	}
+
+	mr.SetError("cache unavailable")
+	if _, err := getTokenFromCache(ctx, "github", "cached"); err == nil || errors.Is(err, errTokenNotInCache) {
+		t.Errorf("a failing cache must not be reported as a missing token, got %v", err)
+	}
+	if got := GetTokenFromCache(ctx, "entered token", "cached", "github"); got != "entered token" {
+		t.Errorf("the entered token must be used when the cache fails, got %q", got)
+	}
+}
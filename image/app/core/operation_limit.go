@@ -0,0 +1,114 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"integration/app/config"
+	"sync"
+)
+
+// heavy operations (file uploads, computations) of all jobs running in this process share the same slots,
+// independently from the number of workers; nil when not limited
+var operationSlots chan struct{}
+var operationSlotsOnce sync.Once
+
+func getOperationSlots() chan struct{} {
+	operationSlotsOnce.Do(func() {
+		if n := config.GetConfig().Options.MaxConcurrentOperations; n > 0 {
+			operationSlots = make(chan struct{}, n)
+		}
+	})
+	return operationSlots
+}
+
+// blocks until a slot is free or the context is done, the returned function releases the slot
+func acquireOperationSlot(ctx context.Context) (func(), error) {
+	slots := getOperationSlots()
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// jobs of the plugins with a limit in maxConcurrentJobs share the slots of their plugin, a worker waits for a free slot before starting the job
+var pluginSlots = map[string]chan struct{}{}
+var pluginSlotsMutex sync.Mutex
+
+func getPluginSlots(plugin string) chan struct{} {
+	pluginSlotsMutex.Lock()
+	defer pluginSlotsMutex.Unlock()
+	if slots, ok := pluginSlots[plugin]; ok {
+		return slots
+	}
+	var slots chan struct{}
+	if n := config.GetConfig().Options.MaxConcurrentJobs[plugin]; n > 0 {
+		slots = make(chan struct{}, n)
+	}
+	pluginSlots[plugin] = slots
+	return slots
+}
+
+// blocks until a slot of the plugin is free, the returned function releases the slot;
+// ok is false when the workers are stopped while waiting
+func acquirePluginSlot(plugin string) (release func(), ok bool) {
+	slots := getPluginSlots(plugin)
+	if slots == nil {
+		return func() {}, true
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	case <-Stop:
+		return nil, false
+	}
+}
+
+// s3fs mounts of the computations running in this process share the same slots (each mount uses FUSE and file descriptors),
+// the slot is held by the job (key) from mounting until unmounting; nil when not limited
+var mountSlots chan struct{}
+var mountSlotsOnce sync.Once
+var mountsHeld = map[string]bool{}
+var mountsHeldMutex sync.Mutex
+
+func getMountSlots() chan struct{} {
+	mountSlotsOnce.Do(func() {
+		if n := config.GetConfig().Options.S3Config.MaxConcurrentMounts; n > 0 {
+			mountSlots = make(chan struct{}, n)
+		}
+	})
+	return mountSlots
+}
+
+// blocks until a mount slot is free or the context is done, the slot is released by releaseMountSlot with the same key
+func acquireMountSlot(ctx context.Context, key string) error {
+	slots := getMountSlots()
+	if slots == nil {
+		return nil
+	}
+	select {
+	case slots <- struct{}{}:
+		mountsHeldMutex.Lock()
+		defer mountsHeldMutex.Unlock()
+		mountsHeld[key] = true
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releases the mount slot held by the key, if any: safe to call when mounting failed or was not attempted
+func releaseMountSlot(key string) {
+	mountsHeldMutex.Lock()
+	defer mountsHeldMutex.Unlock()
+	if !mountsHeld[key] {
+		return
+	}
+	delete(mountsHeld, key)
+	<-mountSlots
+}
@@ -0,0 +1,78 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"integration/app/config"
+	"integration/app/tree"
+	"path"
+	"sort"
+	"strings"
+)
+
+// the source nodes with a directory label and file name (joined by "/") longer than the configured maximum number of characters
+// are removed and their ids returned, or, when truncateLongPaths is set, the directory label is shortened first and then the file name
+// (the extension is kept, a number is appended when the name is taken); the original id is kept in SourcePath for streaming the file from the source
+func LongPaths(nm map[string]tree.Node) (map[string]tree.Node, []string) {
+	maxLength := config.GetConfig().Options.MaxPathLength
+	if maxLength <= 0 {
+		return nm, nil
+	}
+	truncate := config.GetConfig().Options.TruncateLongPaths
+	res := map[string]tree.Node{}
+	tooLong := []string{}
+	for k, v := range nm {
+		if !v.Attributes.IsFile || len([]rune(destinationName(v))) <= maxLength {
+			res[k] = v
+		} else {
+			tooLong = append(tooLong, k)
+		}
+	}
+	sort.Strings(tooLong)
+	for i, k := range tooLong {
+		v := nm[k]
+		tooLong[i] = v.Id
+		if !truncate {
+			continue
+		}
+		// a number is appended when the truncated name is already taken, the path is then truncated further to make room for it
+		var id string
+		for budget := maxLength; ; {
+			dir, name := truncatePath(v.Path, v.Name, budget)
+			id = uniqueId(res, dir, name, nil)
+			overflow := len([]rune(id)) - maxLength
+			if overflow <= 0 || budget <= 1 {
+				break
+			}
+			budget -= overflow
+		}
+		dir := path.Dir(id)
+		if dir == "." {
+			dir = ""
+		}
+		v.Attributes.SourcePath = v.SourceId()
+		v.Id = id
+		v.Path = dir
+		v.Name = path.Base(id)
+		res[id] = v
+	}
+	return res, tooLong
+}
+
+func truncatePath(dir, name string, maxLength int) (string, string) {
+	maxLength = max(maxLength, 1)
+	nameRunes := []rune(name)
+	if available := maxLength - len(nameRunes) - 1; available > 0 {
+		d := strings.TrimRight(strings.TrimSpace(string([]rune(dir)[:min(available, len([]rune(dir)))])), "/ ")
+		return d, name
+	}
+	if len(nameRunes) <= maxLength {
+		return "", name
+	}
+	ext := []rune(path.Ext(name))
+	if len(ext) >= maxLength {
+		ext = nil
+	}
+	base := nameRunes[:len(nameRunes)-len(ext)]
+	return "", strings.TrimSpace(string(base[:min(maxLength-len(ext), len(base))])) + string(ext)
+}
@@ -3,19 +3,28 @@
 package core
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"integration/app/config"
 	"integration/app/logging"
 	"integration/app/plugin/funcs/stream"
 	"integration/app/plugin/types"
 	"integration/app/tree"
+	"sync"
 	"time"
 )
 
 var FileNamesInCacheDuration = 5 * time.Minute
 var deleteAndCleanupCtxDuration = 5 * time.Minute
 
+const defaultFlushBatchSize = 500
+const defaultMaxFileAttempts = 3
+const maxDeadLetters = 1000
+const deadLetterRetention = 7 * 24 * time.Hour
+
 func doWork(job Job) (Job, error) {
 	ctx, cancel := context.WithDeadline(context.Background(), job.Deadline)
 	defer cancel()
@@ -26,27 +35,44 @@ func doWork(job Job) (Job, error) {
 		case <-ctx.Done():
 		}
 	}()
+	go watchCancellation(ctx, cancel, job)
 	if job.Plugin == "hash-only" {
 		return doRehash(ctx, job.DataverseKey, job.User, job.PersistentId, job.WritableNodes, job)
 	}
+	dest := job.destination()
 	knownHashes := getKnownHashes(ctx, job.PersistentId)
 	//filter not valid actions (when someone had browser open for a very long time and other job started and finished)
-	writableNodes, err := filterRedundant(ctx, job, knownHashes)
+	writableNodes, err := filterRedundant(ctx, dest, job, knownHashes)
 	if err != nil {
 		return job, err
 	}
 	job.WritableNodes = writableNodes
-	streamNodes := map[string]tree.Node{}
-	for k, v := range writableNodes {
-		if v.Action != tree.Delete {
-			streamNodes[k] = v
-		}
+	job.HashType, err = jobHashType(ctx, dest, job)
+	if err != nil {
+		return job, err
 	}
 	streamParams := job.StreamParams
-	streamParams.Token = GetTokenFromCache(ctx, job.StreamParams.Token, job.SessionId, job.StreamParams.PluginId)
+	streamParams.Token, err = GetRequiredTokenFromCache(ctx, job.StreamParams.Token, job.SessionId, job.StreamParams.PluginId)
+	if err != nil {
+		return job, err
+	}
 	streamParams.PersistentId = job.PersistentId
 	streamParams.DVToken = job.DataverseKey
 	streamParams.SessionId = job.SessionId
+	err = stream.ProbeFileSizes(ctx, job.WritableNodes, job.Plugin, streamParams)
+	if err != nil {
+		return job, err
+	}
+	err = CheckJobTotalSize(job.WritableNodes)
+	if err != nil {
+		return job, Permanent(err)
+	}
+	streamNodes := map[string]tree.Node{}
+	for k, v := range job.WritableNodes {
+		if v.Action != tree.Delete {
+			streamNodes[k] = v
+		}
+	}
 	streams, err := stream.Streams(ctx, streamNodes, job.Plugin, streamParams)
 	if err != nil {
 		return job, err
@@ -54,10 +80,14 @@ func doWork(job Job) (Job, error) {
 	if streams.Cleanup != nil {
 		defer streams.Cleanup()
 	}
-	j, err := doPersistNodeMap(ctx, streams.Streams, job, knownHashes)
+	thumbnails, setThumbnailAfterCopy := thumbnailCandidates(job)
+	j, err := doPersistNodeMap(ctx, dest, withDownloadTimeout(streams.Streams, job.Plugin), job, knownHashes)
 	if err != nil {
 		return j, err
 	}
+	if setThumbnailAfterCopy {
+		setThumbnail(ctx, dest, j, thumbnails, streams.Streams)
+	}
 	return j, sendJobSuccessMail(j)
 }
 
@@ -65,7 +95,7 @@ func sendJobFailedMail(errIn error, job Job) error {
 	shortContext, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 	config.GetRedis().Set(shortContext, fmt.Sprintf("error %v", job.PersistentId), errIn.Error(), FileNamesInCacheDuration)
-	to, err := Destination.GetUserEmail(shortContext, job.DataverseKey, job.User)
+	to, err := job.destination().GetUserEmail(shortContext, job.DataverseKey, job.User)
 	if err != nil {
 		return fmt.Errorf("error when sending email on error (%v): %v", errIn, err)
 	}
@@ -78,13 +108,14 @@ func sendJobFailedMail(errIn error, job Job) error {
 	return errIn
 }
 
+// the email on success is replaced by the email on partial failure when files were skipped after failing, which is always sent
 func sendJobSuccessMail(job Job) error {
-	if !job.SendEmailOnSuccess {
+	if !job.SendEmailOnSuccess && len(job.Failed) == 0 {
 		return nil
 	}
 	shortContext, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
-	to, err := Destination.GetUserEmail(shortContext, job.DataverseKey, job.User)
+	to, err := job.destination().GetUserEmail(shortContext, job.DataverseKey, job.User)
 	if err != nil {
 		return fmt.Errorf("error when sending email on success: %v", err)
 	}
@@ -97,7 +128,27 @@ func sendJobSuccessMail(job Job) error {
 	return nil
 }
 
-func filterRedundant(ctx context.Context, job Job, knownHashes map[string]calculatedHashes) (map[string]tree.Node, error) {
+// acknowledges the job when it starts for the first time (not when retried), only when sendMailOnStart is set and the user asked for emails
+func sendJobStartedMail(job Job) {
+	if !config.GetConfig().Options.SendMailOnStart || !job.SendEmailOnSuccess || job.ErrCnt > 0 || job.Plugin == "hash-only" {
+		return
+	}
+	shortContext, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	to, err := job.destination().GetUserEmail(shortContext, job.DataverseKey, job.User)
+	if err != nil {
+		logging.Logger.Printf("%v: error when sending email on start: %v\n", job.PersistentId, err)
+		return
+	}
+	msg := fmt.Sprintf("To: %v\r\nMIME-version: 1.0;\r\nContent-Type: text/html; charset=\"UTF-8\";\r\n"+
+		"Subject: %v\r\n\r\n<html><body>%v</body>\r\n", to, getSubjectOnStart(job), getContentOnStart(job))
+	err = SendMail(msg, []string{to})
+	if err != nil {
+		logging.Logger.Printf("%v: error when sending email on start: %v\n", job.PersistentId, err)
+	}
+}
+
+func filterRedundant(ctx context.Context, dest DestinationPlugin, job Job, knownHashes map[string]calculatedHashes) (map[string]tree.Node, error) {
 	filteredEqual := map[string]tree.Node{}
 	isDelete := false
 	for k, v := range job.WritableNodes {
@@ -114,9 +165,13 @@ func filterRedundant(ctx context.Context, job Job, knownHashes map[string]calcul
 		return filteredEqual, nil
 	}
 	res := map[string]tree.Node{}
-	nm, err := Destination.Query(ctx, job.PersistentId, job.DataverseKey, job.User)
-	if err != nil {
-		return nil, err
+	nm, ok := getCachedListing(ctx, job)
+	if !ok {
+		var err error
+		nm, err = dest.Query(ctx, job.PersistentId, job.DataverseKey, job.User)
+		if err != nil {
+			return nil, err
+		}
 	}
 	for k, v := range filteredEqual {
 		_, ok := nm[k]
@@ -128,10 +183,11 @@ func filterRedundant(ctx context.Context, job Job, knownHashes map[string]calcul
 	return res, nil
 }
 
-func doPersistNodeMap(ctx context.Context, streams map[string]types.Stream, in Job, knownHashes map[string]calculatedHashes) (out Job, err error) {
+func doPersistNodeMap(ctx context.Context, dest DestinationPlugin, streams map[string]types.Stream, in Job, knownHashes map[string]calculatedHashes) (out Job, err error) {
 	dataverseKey, user, persistentId, writableNodes := in.DataverseKey, in.User, in.PersistentId, in.WritableNodes
-	err = Destination.CheckPermission(ctx, dataverseKey, user, persistentId)
+	err = dest.CheckPermission(ctx, dataverseKey, user, persistentId)
 	if err != nil {
+		err = Permanent(err)
 		return
 	}
 	defer storeKnownHashes(ctx, persistentId, knownHashes)
@@ -144,41 +200,64 @@ func doPersistNodeMap(ctx context.Context, streams map[string]types.Stream, in J
 	toAddNodes := &[]tree.Node{}
 	toReplaceIdentifiers := &[]string{}
 	toReplaceNodes := &[]tree.Node{}
-	defer doFlush(ctx, toAddNodes, toReplaceNodes, &out, knownHashes, toAddIdentifiers, toReplaceIdentifiers)
+	defer doFlush(ctx, dest, toAddNodes, toReplaceNodes, &out, knownHashes, toAddIdentifiers, toReplaceIdentifiers)
+	current := &currentFiles{}
 
+	// the files are streamed and hashed by at most streamConcurrency workers; mu guards knownHashes, out and the slices above,
+	// the workers are waited for before flushing (deferred after doFlush, so it runs first), such that all written files are flushed or rolled back
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	sem := make(chan struct{}, streamConcurrency(dest))
+	var workerErr error
+	nodes := make(map[string]tree.Node, len(writableNodes)) // out.WritableNodes is modified by the workers while iterating
 	for k, v := range writableNodes {
+		nodes[k] = v
+	}
+
+	for k, v := range nodes {
 		select {
 		case <-ctx.Done():
 			err = ctx.Err()
 			return
 		default:
 		}
+		mu.Lock()
+		err = workerErr
 		i++
-		if i%10 == 0 && i < total {
+		if err == nil && i%10 == 0 && i < total {
 			storeKnownHashes(ctx, persistentId, knownHashes) //if we have many files to hash -> polling at the gui is happier to see some progress
+			checkpointJob(out, unflushed(nodes, *toAddNodes, *toReplaceNodes))
 			logging.Logger.Printf("%v: processed %v/%v\n", persistentId, i, total)
 		}
+		mu.Unlock()
+		if err != nil {
+			return
+		}
 
 		redisKey := fmt.Sprintf("%v -> %v", persistentId, k)
 		if v.Action == tree.Delete {
-			err = deleteFile(ctx, dataverseKey, user, v.Attributes.DestinationFile.Id)
+			err = deleteFile(ctx, dest, dataverseKey, user, v.Attributes.DestinationFile.Id)
 			if err != nil {
 				return
 			}
+			mu.Lock()
 			delete(knownHashes, v.Id)
 			delete(out.WritableNodes, k)
 			config.GetRedis().Set(ctx, redisKey, types.Deleted, FileNamesInCacheDuration)
 			writtenKeys = append(writtenKeys, redisKey)
+			mu.Unlock()
 			continue
 		}
 
 		if in.Plugin == "globus" {
 			if v.Action == tree.Update {
-				err = deleteFile(ctx, dataverseKey, user, v.Attributes.DestinationFile.Id)
+				err = deleteFile(ctx, dest, dataverseKey, user, v.Attributes.DestinationFile.Id)
 				if err != nil {
 					return
 				}
 			}
+			mu.Lock()
 			delete(out.WritableNodes, k)
 			knownHashes[v.Id] = calculatedHashes{
 				LocalHashType:  types.LastModified,
@@ -187,66 +266,99 @@ func doPersistNodeMap(ctx context.Context, streams map[string]types.Stream, in J
 			}
 			config.GetRedis().Set(ctx, redisKey, types.Written, FileNamesInCacheDuration)
 			writtenKeys = append(writtenKeys, redisKey)
+			mu.Unlock()
 			continue
 		}
 
-		fileStream := streams[k]
-		fileName := generateFileName()
-		storageIdentifier := generateStorageIdentifier(fileName)
-		hashType := config.GetConfig().Options.DefaultHash
-		remoteHashType := v.Attributes.RemoteHashType
-
-		var h []byte
-		var remoteH []byte
-		var size int64
-		h, remoteH, size, err = write(ctx, v.Attributes.DestinationFile.Id, dataverseKey, user, fileStream, storageIdentifier, persistentId, hashType, remoteHashType, k, v.Attributes.RemoteFileSize)
+		var modified bool
+		modified, err = current.modified(ctx, dest, in, v)
 		if err != nil {
 			return
 		}
-
-		hashValue := fmt.Sprintf("%x", h)
-		v.Attributes.DestinationFile.Hash = hashValue
-		v.Attributes.DestinationFile.HashType = hashType
-		v.Attributes.DestinationFile.FileSize = size
-
-		//updated or new: always rehash
-		remoteHashValue := fmt.Sprintf("%x", remoteH)
-		if remoteHashType == types.GitHash || remoteHashType == types.LastModified {
-			// gitlab does not provide filesize... If we do not know the filesize before calculating the hash, we can't calculate the git hash
-			// we also cannot calculate the last modified in the file system...
-			remoteHashValue = v.Attributes.RemoteHash
-		}
-		if v.Attributes.RemoteHash != remoteHashValue && v.Attributes.RemoteHash != types.NotNeeded { // not all local file system hashes are calculated on beforehand (types.NotNeeded)
-			if remoteHashType == types.QuickXorHash { //some sharepoint hashes fail
-				logging.Logger.Println("WARNING: quickXorHash not equal, expected", v.Attributes.RemoteHash, "got", remoteHashValue)
-				remoteHashValue = v.Attributes.RemoteHash
-			} else {
-				err = fmt.Errorf("downloaded file hash not equal")
+		if modified {
+			if replaceConflictsPolicy() == replaceConflictsFail {
+				err = Permanent(fmt.Errorf("%w: %v", ErrConcurrentModification, k))
 				return
 			}
+			logging.Logger.Printf("WARNING: %v: %v was modified in the dataset after the compare and is skipped\n", persistentId, k)
+			jobLog(persistentId, "warning", k, "modified in the dataset after the compare, skipped")
+			mu.Lock()
+			out.Conflicts = append(out.Conflicts, k)
+			delete(out.WritableNodes, k)
+			mu.Unlock()
+			continue
 		}
 
-		if Destination.IsDirectUpload() {
-			if v.Attributes.DestinationFile.Id != 0 {
-				*toReplaceIdentifiers = append(*toReplaceIdentifiers, storageIdentifier)
-				*toReplaceNodes = append(*toReplaceNodes, v)
-			} else {
-				*toAddIdentifiers = append(*toAddIdentifiers, storageIdentifier)
-				*toAddNodes = append(*toAddNodes, v)
+		if isMetadataOnlyUpdate(dest, v) {
+			err = dest.UpdateFileMetadata(ctx, dataverseKey, user, persistentId, v)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			if from := v.Attributes.DestinationFile.MovedFrom; from != "" {
+				delete(knownHashes, from)
 			}
+			delete(out.WritableNodes, k)
+			config.GetRedis().Set(ctx, redisKey, types.Written, FileNamesInCacheDuration)
+			writtenKeys = append(writtenKeys, redisKey)
+			mu.Unlock()
+			continue
 		}
 
-		if hashValue != remoteHashValue {
-			knownHashes[v.Id] = calculatedHashes{
-				LocalHashType:  hashType,
-				LocalHashValue: hashValue,
-				RemoteHashes:   map[string]string{remoteHashType: remoteHashValue},
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(k string, v tree.Node) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			v, storageIdentifier, hashValue, remoteHashValue, err := writeNodeWithRetry(ctx, dest, dataverseKey, user, persistentId, in.HashType, k, v, streams[k])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if ctx.Err() != nil {
+					workerErr = cmp.Or(workerErr, err)
+					return
+				}
+				if isVanished(err) {
+					logging.Logger.Printf("WARNING: %v: %v was removed from the source after the compare and is skipped: %v\n", persistentId, k, err)
+					jobLog(persistentId, "warning", k, "removed from the source after the compare, skipped: %v", err)
+					out.Vanished = append(out.Vanished, k)
+					delete(out.WritableNodes, k)
+					return
+				}
+				// the file keeps failing: record it for later inspection and continue with the other files
+				addToDeadLetters(ctx, persistentId, k, err)
+				out.Failed = append(out.Failed, k)
+				delete(out.WritableNodes, k)
+				return
 			}
-		}
-		config.GetRedis().Set(ctx, redisKey, types.Written, FileNamesInCacheDuration)
-		writtenKeys = append(writtenKeys, redisKey)
 
-		delete(out.WritableNodes, k)
+			if dest.IsDirectUpload() {
+				if v.Attributes.DestinationFile.Id != 0 {
+					*toReplaceIdentifiers = append(*toReplaceIdentifiers, storageIdentifier)
+					*toReplaceNodes = append(*toReplaceNodes, v)
+				} else {
+					*toAddIdentifiers = append(*toAddIdentifiers, storageIdentifier)
+					*toAddNodes = append(*toAddNodes, v)
+				}
+			}
+
+			if hashValue != remoteHashValue {
+				knownHashes[v.Id] = calculatedHashes{
+					LocalHashType:  v.Attributes.DestinationFile.HashType,
+					LocalHashValue: hashValue,
+					RemoteHashes:   map[string]string{v.Attributes.RemoteHashType: remoteHashValue},
+				}
+			}
+			config.GetRedis().Set(ctx, redisKey, types.Written, FileNamesInCacheDuration)
+			writtenKeys = append(writtenKeys, redisKey)
+
+			delete(out.WritableNodes, k)
+		}(k, v)
+	}
+	wg.Wait()
+	if workerErr != nil {
+		err = workerErr
+		return
 	}
 
 	select {
@@ -261,10 +373,107 @@ func doPersistNodeMap(ctx context.Context, streams map[string]types.Stream, in J
 	return
 }
 
-func doFlush(ctx context.Context, toAddNodes *[]tree.Node, toReplaceNodes *[]tree.Node, job *Job, knownHashes map[string]calculatedHashes, toAddIdentifiers, toReplaceIdentifiers *[]string) {
+// the nodes as selected for the job of the files written with direct upload that are not registered yet (see doFlush);
+// the written nodes carry the hash of the new content, with which filterRedundant would skip them
+func unflushed(nodes map[string]tree.Node, written ...[]tree.Node) []tree.Node {
+	res := []tree.Node{}
+	for _, w := range written {
+		for _, n := range w {
+			res = append(res, nodes[n.Id])
+		}
+	}
+	return res
+}
+
+func writeNodeWithRetry(ctx context.Context, dest DestinationPlugin, dataverseKey, user, persistentId, hashType, k string, v tree.Node, fileStream types.Stream) (tree.Node, string, string, string, error) {
+	attempts := maxFileAttempts()
+	for attempt := 1; ; attempt++ {
+		node, storageIdentifier, hashValue, remoteHashValue, err := writeNode(ctx, dest, dataverseKey, user, persistentId, hashType, k, v, fileStream)
+		if err == nil || attempt >= attempts || ctx.Err() != nil || isVanished(err) || errors.As(err, &PermanentError{}) {
+			return node, storageIdentifier, hashValue, remoteHashValue, err
+		}
+		logging.Logger.Printf("%v: writing %v failed (attempt %v/%v): %v\n", persistentId, k, attempt, attempts, err)
+		jobLog(persistentId, "warning", k, "writing failed (attempt %v/%v): %v", attempt, attempts, err)
+		select {
+		case <-ctx.Done():
+			return node, "", "", "", ctx.Err()
+		case <-time.After(retryBackoff(attempt)):
+		}
+	}
+}
+
+// writes the file and verifies the hash of the source, returns the node with the destination file attributes set
+func writeNode(ctx context.Context, dest DestinationPlugin, dataverseKey, user, persistentId, hashType, k string, v tree.Node, fileStream types.Stream) (tree.Node, string, string, string, error) {
+	fileName := generateFileName()
+	storageIdentifier := generateStorageIdentifier(fileName)
+	remoteHashType := v.Attributes.RemoteHashType
+
+	release, err := acquireOperationSlot(ctx)
+	if err != nil {
+		return v, "", "", "", err
+	}
+	h, remoteH, size, storageIdentifier, err := write(ctx, dest, v.Attributes.DestinationFile.Id, dataverseKey, user, fileStream, storageIdentifier, persistentId, hashType, remoteHashType, k, v.Attributes.Categories, FileDescription(v), v.Attributes.RemoteFileSize)
+	release()
+	if err != nil {
+		return v, "", "", "", err
+	}
+
+	hashValue := fmt.Sprintf("%x", h)
+	v.Attributes.DestinationFile.Hash = hashValue
+	v.Attributes.DestinationFile.HashType = hashType
+	v.Attributes.DestinationFile.FileSize = size
+
+	//updated or new: always rehash
+	remoteHashValue := fmt.Sprintf("%x", remoteH)
+	switch remoteHashCheckFor(v, size) {
+	case keepRemoteHash:
+		remoteHashValue = v.Attributes.RemoteHash
+	case warnRemoteHashMismatch:
+		if v.Attributes.RemoteHash != remoteHashValue {
+			logging.Logger.Println("WARNING:", remoteHashType, "not equal, expected", v.Attributes.RemoteHash, "got", remoteHashValue)
+			remoteHashValue = v.Attributes.RemoteHash
+		}
+	case verifyRemoteHash:
+		if v.Attributes.RemoteHash != remoteHashValue {
+			return v, "", "", "", fmt.Errorf("downloaded file hash not equal")
+		}
+	}
+	return v, storageIdentifier, hashValue, remoteHashValue, nil
+}
+
+func isVanished(err error) bool {
+	return config.GetConfig().Options.SkipVanishedFiles && errors.Is(err, types.ErrNotFound)
+}
+
+type deadLetter struct {
+	Id    string    `json:"id"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// files that keep failing are pushed to the "dead letters: <persistentId>" list for later inspection or reprocessing,
+// the list keeps the most recent entries and expires when no file of the dataset failed for deadLetterRetention
+func addToDeadLetters(ctx context.Context, persistentId, k string, err error) {
+	logging.Logger.Printf("%v: writing %v failed after %v attempts, added to the dead letters: %v\n", persistentId, k, maxFileAttempts(), err)
+	jobLog(persistentId, "error", k, "writing failed, the file is skipped: %v", err)
+	b, _ := json.Marshal(deadLetter{Id: k, Error: err.Error(), Time: time.Now()})
+	key := "dead letters: " + persistentId
+	config.GetRedis().LPush(ctx, key, string(b))
+	config.GetRedis().LTrim(ctx, key, 0, maxDeadLetters-1)
+	config.GetRedis().Expire(ctx, key, deadLetterRetention)
+}
+
+func maxFileAttempts() int {
+	if n := config.GetConfig().Options.MaxFileAttempts; n > 0 {
+		return n
+	}
+	return defaultMaxFileAttempts
+}
+
+func doFlush(ctx context.Context, dest DestinationPlugin, toAddNodes *[]tree.Node, toReplaceNodes *[]tree.Node, job *Job, knownHashes map[string]calculatedHashes, toAddIdentifiers, toReplaceIdentifiers *[]string) {
 	if len(*toAddNodes) > 0 || len(*toReplaceNodes) > 0 {
 		logging.Logger.Printf("%v: flushing added: %v replaced: %v...\n", job.PersistentId, len(*toAddNodes), len(*toReplaceNodes))
-		flushed, err := flush(ctx, job.DataverseKey, job.User, job.PersistentId, *toAddIdentifiers, *toReplaceIdentifiers, *toAddNodes, *toReplaceNodes)
+		flushed, err := flush(ctx, dest, job.DataverseKey, job.User, job.PersistentId, *toAddIdentifiers, *toReplaceIdentifiers, *toAddNodes, *toReplaceNodes)
 		if err != nil {
 			rollback := *toAddNodes
 			rollback = append(rollback, *toReplaceNodes...)
@@ -287,27 +496,98 @@ func doFlush(ctx context.Context, toAddNodes *[]tree.Node, toReplaceNodes *[]tre
 	}
 }
 
-func flush(ctx context.Context, dataverseKey, user, persistentId string, toAddIdentifiers, toReplaceIdentifiers []string, toAddNodes, toReplaceNodes []tree.Node) (res map[string]bool, err error) {
+func flush(ctx context.Context, dest DestinationPlugin, dataverseKey, user, persistentId string, toAddIdentifiers, toReplaceIdentifiers []string, toAddNodes, toReplaceNodes []tree.Node) (res map[string]bool, err error) {
 	res = make(map[string]bool)
-	if len(toAddNodes) > 0 {
-		err = Destination.SaveAfterDirectUpload(ctx, false, dataverseKey, user, persistentId, toAddIdentifiers, toAddNodes)
-		if err != nil {
-			return
-		}
-		for _, node := range toAddNodes {
-			res[node.Id] = true
-		}
+	// all adds are flushed before the replaces
+	err = flushBatches(ctx, dest, false, dataverseKey, user, persistentId, toAddIdentifiers, toAddNodes, res)
+	if err != nil {
+		return
+	}
+	err = flushBatches(ctx, dest, true, dataverseKey, user, persistentId, toReplaceIdentifiers, toReplaceNodes, res)
+	return
+}
+
+// splits the nodes in batches of at most flushBatchSize nodes, flushed with at most flushConcurrency calls at the same time;
+// the nodes of the successful batches are marked in res, such that only the nodes of the failed batches are rolled back
+func flushBatches(ctx context.Context, dest DestinationPlugin, replace bool, dataverseKey, user, persistentId string, identifiers []string, nodes []tree.Node, res map[string]bool) error {
+	batchSize := flushBatchSize()
+	sem := make(chan struct{}, flushConcurrency())
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	errs := []error{}
+	for start := 0; start < len(nodes); start += batchSize {
+		end := min(start+batchSize, len(nodes))
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(batchIdentifiers []string, batchNodes []tree.Node) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := verifyDirectUploads(ctx, dest, dataverseKey, user, persistentId, batchIdentifiers, batchNodes)
+			if err == nil {
+				err = dest.SaveAfterDirectUpload(ctx, replace, dataverseKey, user, persistentId, batchIdentifiers, batchNodes)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for _, node := range batchNodes {
+				res[node.Id] = true
+			}
+		}(identifiers[start:end], nodes[start:end])
 	}
-	if len(toReplaceNodes) > 0 {
-		err = Destination.SaveAfterDirectUpload(ctx, true, dataverseKey, user, persistentId, toReplaceIdentifiers, toReplaceNodes)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// re-reads the stored objects and compares their hashes with the hashes calculated while streaming (verifyDirectUpload),
+// a mismatch fails the batch such that its nodes are rolled back and written again;
+// the objects uploaded to presigned URLs can only be read after registration and are not verified
+func verifyDirectUploads(ctx context.Context, dest DestinationPlugin, dataverseKey, user, persistentId string, identifiers []string, nodes []tree.Node) error {
+	if !config.GetConfig().Options.VerifyDirectUpload {
+		return nil
+	}
+	for i, node := range nodes {
+		if getStorage(identifiers[i]).driver == "s3" && usePresignedUrls(dest) {
+			continue
+		}
+		stored := node
+		stored.Attributes.DestinationFile.StorageIdentifier = identifiers[i]
+		stored.Attributes.RemoteHashType = node.Attributes.DestinationFile.HashType
+		h, err := doHash(ctx, dest, dataverseKey, user, persistentId, stored)
 		if err != nil {
-			return
+			return fmt.Errorf("verifying %v failed: %w", node.Id, err)
 		}
-		for _, node := range toReplaceNodes {
-			res[node.Id] = true
+		if hashValue := fmt.Sprintf("%x", h); hashValue != node.Attributes.DestinationFile.Hash {
+			jobLog(persistentId, "error", node.Id, "stored file hash %v does not equal the hash of the written content %v", hashValue, node.Attributes.DestinationFile.Hash)
+			return fmt.Errorf("verifying %v failed: stored file hash %v not equal to written %v", node.Id, hashValue, node.Attributes.DestinationFile.Hash)
 		}
 	}
-	return
+	return nil
+}
+
+// only direct uploads are parallel: the files uploaded over the wire are added to the dataset one by one by the native API,
+// and Dataverse locks the dataset during each add, such that parallel adds fail with a lock conflict (409)
+func streamConcurrency(dest DestinationPlugin) int {
+	if n := config.GetConfig().Options.StreamConcurrency; n > 0 && dest.IsDirectUpload() {
+		return n
+	}
+	return 1
+}
+
+func flushBatchSize() int {
+	if n := config.GetConfig().Options.FlushBatchSize; n > 0 {
+		return n
+	}
+	return defaultFlushBatchSize
+}
+
+func flushConcurrency() int {
+	if n := config.GetConfig().Options.FlushConcurrency; n > 0 {
+		return n
+	}
+	return 1
 }
 
 // func cleanup(ctx context.Context, token, user, persistentId string, writtenKeys []string) error {
@@ -326,8 +606,8 @@ func cleanRedis(writtenKeys []string) {
 	}
 }
 
-func deleteFile(_ context.Context, token, user string, id int64) error {
+func deleteFile(_ context.Context, dest DestinationPlugin, token, user string, id int64) error {
 	shortContext, cancel := context.WithTimeout(context.Background(), deleteAndCleanupCtxDuration)
 	defer cancel()
-	return Destination.DeleteFile(shortContext, token, user, id)
+	return dest.DeleteFile(shortContext, token, user, id)
 }
@@ -0,0 +1,63 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"integration/app/config"
+	"integration/app/tree"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFailingFileGoesToDeadLetters(t *testing.T) {
+	mr := useMiniredis(t)
+	useOptions(t, config.OptionalConfig{MaxFileAttempts: 2, JobRetryBackoff: 1})
+	m := newMockDestination()
+	m.failing["broken.txt"] = true
+	dest := registerMock(t, "mock failing", m)
+
+	contents := map[string]string{"broken.txt": "broken content", "first.txt": "first content", "second.txt": "second content"}
+	job := testJob(
+		sourceNode("broken.txt", contents["broken.txt"], tree.Copy, 0),
+		sourceNode("first.txt", contents["first.txt"], tree.Copy, 0),
+		sourceNode("second.txt", contents["second.txt"], tree.Update, 2),
+	)
+	out, err := doPersistNodeMap(context.Background(), dest, stringStreams(contents), job, map[string]calculatedHashes{})
+	if err != nil {
+		t.Fatalf("a failing file must not fail the job: %v", err)
+	}
+
+	if !slices.Equal(out.Failed, []string{"broken.txt"}) {
+		t.Errorf("failed: got %v, expected [broken.txt]", out.Failed)
+	}
+	if len(out.WritableNodes) != 0 {
+		t.Errorf("the failed file must not be retried by the job, left: %v", out.WritableNodes)
+	}
+	if m.attempts["broken.txt"] != 2 {
+		t.Errorf("got %v attempts, expected maxFileAttempts (2)", m.attempts["broken.txt"])
+	}
+	for _, k := range []string{"first.txt", "second.txt"} {
+		if m.written[k] != contents[k] {
+			t.Errorf("the other files must still be written, %v: got %q", k, m.written[k])
+		}
+	}
+
+	key := "dead letters: " + testPersistentId
+	entries, err := mr.List(key)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 dead letter in %q, got %v (%v)", key, entries, err)
+	}
+	letter := deadLetter{}
+	if err := json.Unmarshal([]byte(entries[0]), &letter); err != nil {
+		t.Fatalf("invalid dead letter: %v", err)
+	}
+	if letter.Id != "broken.txt" || !strings.Contains(letter.Error, errMockWrite.Error()) || letter.Time.IsZero() {
+		t.Errorf("got dead letter %+v", letter)
+	}
+	if mr.TTL(key) <= 0 {
+		t.Errorf("the dead letters must expire")
+	}
+}
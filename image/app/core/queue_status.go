@@ -0,0 +1,69 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"integration/app/config"
+	"strconv"
+	"time"
+)
+
+const recentJobDurations = 20
+
+type QueueStatus struct {
+	Queue         string `json:"queue"`         // empty for the synchronization (and hashing) queue
+	Depth         int64  `json:"depth"`         // number of jobs waiting in the queue
+	EstimatedWait int64  `json:"estimatedWait"` // rough estimate in seconds: the depth times the average duration of the recent jobs
+}
+
+func queueKey(queue string) string {
+	if queue == "" {
+		return "jobs"
+	}
+	return queue + " jobs"
+}
+
+func GetQueueStatus(ctx context.Context, queue string) (QueueStatus, error) {
+	depth, err := config.GetRedis().LLen(ctx, queueKey(queue)).Result()
+	if err != nil {
+		return QueueStatus{}, err
+	}
+	durations := getJobDurations(ctx, queue)
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	res := QueueStatus{Queue: queue, Depth: depth}
+	if len(durations) > 0 {
+		res.EstimatedWait = int64((total / time.Duration(len(durations))).Seconds()) * depth
+	}
+	return res, nil
+}
+
+func jobDurationsKey(queue string) string {
+	return "job durations: " + queue
+}
+
+func getJobDurations(ctx context.Context, queue string) []time.Duration {
+	res := []time.Duration{}
+	values, err := config.GetRedis().LRange(ctx, jobDurationsKey(queue), 0, recentJobDurations-1).Result()
+	if err != nil {
+		return res
+	}
+	for _, v := range values {
+		if d, err := strconv.ParseInt(v, 10, 64); err == nil {
+			res = append(res, time.Duration(d))
+		}
+	}
+	return res
+}
+
+// only the most recent durations are kept, such that the estimate follows the current load;
+// the jobs of a queue end concurrently (several workers and processes), the durations are pushed to a Redis list that is trimmed atomically
+func recordJobDuration(queue string, d time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCtxDuration)
+	defer cancel()
+	config.GetRedis().LPush(ctx, jobDurationsKey(queue), strconv.FormatInt(int64(d), 10))
+	config.GetRedis().LTrim(ctx, jobDurationsKey(queue), 0, recentJobDurations-1)
+}
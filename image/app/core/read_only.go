@@ -0,0 +1,101 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"integration/app/logging"
+	"integration/app/tree"
+	"io"
+	"sync"
+)
+
+// returned by the writing functions of the destinations when they are called with a read-only context:
+// e.g., a compare must never change the dataset, not even by creating a draft version of a published dataset
+var ErrReadOnly = errors.New("write attempted in a read-only operation")
+
+type readOnlyKey struct{}
+
+// marks the context of an operation that only reads from the destination (e.g., compare)
+func ReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+func checkWritable(ctx context.Context, operation string) error {
+	if readOnly, _ := ctx.Value(readOnlyKey{}).(bool); readOnly {
+		logging.Logger.Printf("ERROR: %v called in a read-only operation\n", operation)
+		return fmt.Errorf("%w: %v", ErrReadOnly, operation)
+	}
+	return nil
+}
+
+// wraps the functions of the destination that can change a dataset (and create its draft version) with the read-only check
+func guardWrites(d DestinationPlugin) DestinationPlugin {
+	if f := d.CreateNewRepo; f != nil {
+		d.CreateNewRepo = func(ctx context.Context, collection, token, userName string) (string, error) {
+			if err := checkWritable(ctx, "CreateNewRepo"); err != nil {
+				return "", err
+			}
+			return f(ctx, collection, token, userName)
+		}
+	}
+	if f := d.WriteOverWire; f != nil {
+		d.WriteOverWire = func(ctx context.Context, dbId int64, nodeMapId string, categories []string, description string, token, user, persistentId string, wg *sync.WaitGroup, async_err *ErrorHolder) (io.WriteCloser, error) {
+			if err := checkWritable(ctx, "WriteOverWire"); err != nil {
+				return nil, err
+			}
+			return f(ctx, dbId, nodeMapId, categories, description, token, user, persistentId, wg, async_err)
+		}
+	}
+	if f := d.SaveAfterDirectUpload; f != nil {
+		d.SaveAfterDirectUpload = func(ctx context.Context, replace bool, token, user, persistentId string, storageIdentifiers []string, nodes []tree.Node) error {
+			if err := checkWritable(ctx, "SaveAfterDirectUpload"); err != nil {
+				return err
+			}
+			return f(ctx, replace, token, user, persistentId, storageIdentifiers, nodes)
+		}
+	}
+	if f := d.CleanupLeftOverFiles; f != nil {
+		d.CleanupLeftOverFiles = func(ctx context.Context, persistentId, token, user string) error {
+			if err := checkWritable(ctx, "CleanupLeftOverFiles"); err != nil {
+				return err
+			}
+			return f(ctx, persistentId, token, user)
+		}
+	}
+	if f := d.DeleteFile; f != nil {
+		d.DeleteFile = func(ctx context.Context, token, user string, id int64) error {
+			if err := checkWritable(ctx, "DeleteFile"); err != nil {
+				return err
+			}
+			return f(ctx, token, user, id)
+		}
+	}
+	if f := d.UploadToPresignedUrl; f != nil {
+		d.UploadToPresignedUrl = func(ctx context.Context, token, user, persistentId string, reader io.Reader, size int64) (string, error) {
+			if err := checkWritable(ctx, "UploadToPresignedUrl"); err != nil {
+				return "", err
+			}
+			return f(ctx, token, user, persistentId, reader, size)
+		}
+	}
+	if f := d.SetThumbnail; f != nil {
+		d.SetThumbnail = func(ctx context.Context, token, user, persistentId string, fileId int64) error {
+			if err := checkWritable(ctx, "SetThumbnail"); err != nil {
+				return err
+			}
+			return f(ctx, token, user, persistentId, fileId)
+		}
+	}
+	if f := d.UpdateFileMetadata; f != nil {
+		d.UpdateFileMetadata = func(ctx context.Context, token, user, persistentId string, node tree.Node) error {
+			if err := checkWritable(ctx, "UpdateFileMetadata"); err != nil {
+				return err
+			}
+			return f(ctx, token, user, persistentId, node)
+		}
+	}
+	return d
+}
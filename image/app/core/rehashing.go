@@ -5,6 +5,7 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"integration/app/config"
 	"integration/app/logging"
@@ -18,7 +19,7 @@ type calculatedHashes struct {
 	RemoteHashes   map[string]string
 }
 
-func localRehashToMatchRemoteHashType(ctx context.Context, dataverseKey, user, persistentId string, nodes map[string]tree.Node, addJobs bool) (map[string]tree.Node, bool) {
+func localRehashToMatchRemoteHashType(ctx context.Context, destination, dataverseKey, user, persistentId string, nodes map[string]tree.Node, addJobs bool) (map[string]tree.Node, bool) {
 	knownHashes := getKnownHashes(ctx, persistentId)
 	jobNodes := map[string]tree.Node{}
 	res := map[string]tree.Node{}
@@ -53,6 +54,7 @@ func localRehashToMatchRemoteHashType(ctx context.Context, dataverseKey, user, p
 				PersistentId:  persistentId,
 				WritableNodes: jobNodes,
 				Plugin:        "hash-only",
+				Destination:   destination,
 			},
 		)
 		if err != nil {
@@ -63,7 +65,8 @@ func localRehashToMatchRemoteHashType(ctx context.Context, dataverseKey, user, p
 }
 
 func doRehash(ctx context.Context, dataverseKey, user, persistentId string, nodes map[string]tree.Node, in Job) (out Job, err error) {
-	err = Destination.CheckPermission(ctx, dataverseKey, user, persistentId)
+	dest := in.destination()
+	err = dest.CheckPermission(ctx, dataverseKey, user, persistentId)
 	if err != nil {
 		return
 	}
@@ -75,7 +78,7 @@ func doRehash(ctx context.Context, dataverseKey, user, persistentId string, node
 	i := 0
 	total := len(nodes)
 	for k, node := range nodes {
-		err = calculateHash(ctx, dataverseKey, user, persistentId, node, knownHashes)
+		err = calculateHash(ctx, dest, dataverseKey, user, persistentId, node, knownHashes)
 		if err != nil {
 			return
 		}
@@ -93,9 +96,17 @@ func getKnownHashes(ctx context.Context, persistentId string) map[string]calcula
 	shortContext, cancel := context.WithTimeout(ctx, redisCtxDuration)
 	defer cancel()
 	res := map[string]calculatedHashes{}
-	cache := config.GetRedis().Get(shortContext, "hashes: "+persistentId)
-	err := json.Unmarshal([]byte(cache.Val()), &res)
+	cache, ok, err := config.GetFromCache(shortContext, "hashes: "+persistentId)
 	if err != nil {
+		logging.Logger.Printf("%v: known hashes not available, files will be rehashed: %v\n", persistentId, err)
+		return res
+	}
+	if !ok {
+		return res
+	}
+	err = json.Unmarshal([]byte(cache), &res)
+	if err != nil {
+		logging.Logger.Printf("%v: cached known hashes could not be read, files will be rehashed: %v\n", persistentId, err)
 		return map[string]calculatedHashes{}
 	}
 	return res
@@ -118,7 +129,7 @@ func invalidateKnownHashes(ctx context.Context, persistentId string) {
 	config.GetRedis().Del(shortContext, "hashes: "+persistentId)
 }
 
-func calculateHash(ctx context.Context, dataverseKey, user, persistentId string, node tree.Node, knownHashes map[string]calculatedHashes) error {
+func calculateHash(ctx context.Context, dest DestinationPlugin, dataverseKey, user, persistentId string, node tree.Node, knownHashes map[string]calculatedHashes) error {
 	hashType := node.Attributes.RemoteHashType
 	known, ok := knownHashes[node.Id]
 	if ok && known.LocalHashType == node.Attributes.DestinationFile.HashType && known.LocalHashValue == node.Attributes.DestinationFile.Hash {
@@ -133,7 +144,7 @@ func calculateHash(ctx context.Context, dataverseKey, user, persistentId string,
 			RemoteHashes:   map[string]string{},
 		}
 	}
-	h, err := doHash(ctx, dataverseKey, user, persistentId, node)
+	h, err := doHash(ctx, dest, dataverseKey, user, persistentId, node)
 	if err != nil {
 		return fmt.Errorf("failed to hash local file %v: %v", node.Attributes.DestinationFile.StorageIdentifier, err)
 	}
@@ -142,6 +153,15 @@ func calculateHash(ctx context.Context, dataverseKey, user, persistentId string,
 	return nil
 }
 
+// the known hashes have no expiration, they are removed when the dataset turns out to be deleted
+func ForgetDeletedDataset(ctx context.Context, persistentId string, err error) {
+	if !config.GetConfig().Options.CleanupDeletedDatasets || !errors.Is(err, ErrDatasetNotFound) {
+		return
+	}
+	logging.Logger.Printf("%v: dataset not found, removing its known hashes from the cache\n", persistentId)
+	invalidateKnownHashes(ctx, persistentId)
+}
+
 func CheckKnownHashes(ctx context.Context, persistentId string, mapped map[string]tree.Node) {
 	knownHashes := getKnownHashes(ctx, persistentId)
 	for k, v := range mapped {
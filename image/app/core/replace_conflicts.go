@@ -0,0 +1,59 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"integration/app/tree"
+	"strings"
+)
+
+const (
+	replaceConflictsForce = "force"
+	replaceConflictsSkip  = "skip"
+	replaceConflictsFail  = "fail"
+)
+
+var ErrConcurrentModification = errors.New("file was modified in the dataset after the compare")
+
+func replaceConflictsPolicy() string {
+	switch p := strings.ToLower(config.GetConfig().Options.ReplaceConflicts); p {
+	case replaceConflictsSkip, replaceConflictsFail:
+		return p
+	}
+	return replaceConflictsForce
+}
+
+// when the files in the dataset are not checked for concurrent modification, the replace is forced
+// (Dataverse then also accepts a new file with another content type)
+func ForceReplace() bool {
+	return replaceConflictsPolicy() == replaceConflictsForce
+}
+
+// the files in the dataset as they are when the job writes them, listed once per job (only when an updated file is checked)
+type currentFiles struct {
+	nodes map[string]tree.Node
+}
+
+// the file to update was replaced (another file id), deleted, or its content changed (another hash of the same type)
+// since the compare, e.g., by another user; only checked when replaceConflicts is "skip" or "fail"
+func (c *currentFiles) modified(ctx context.Context, dest DestinationPlugin, job Job, node tree.Node) (bool, error) {
+	if replaceConflictsPolicy() == replaceConflictsForce || node.Action != tree.Update || node.Attributes.DestinationFile.Id == 0 {
+		return false, nil
+	}
+	if c.nodes == nil {
+		nm, err := dest.Query(ctx, job.PersistentId, job.DataverseKey, job.User)
+		if err != nil {
+			return false, fmt.Errorf("listing the dataset for detecting concurrent modifications failed: %w", err)
+		}
+		c.nodes = nm
+	}
+	seen, current := node.Attributes.DestinationFile, c.nodes[node.Id].Attributes.DestinationFile
+	if current.Id != seen.Id {
+		return true, nil
+	}
+	return seen.HashType == current.HashType && seen.Hash != "" && current.Hash != "" && seen.Hash != current.Hash, nil
+}
@@ -0,0 +1,138 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+const (
+	smtpSecurityNone     = "none"
+	smtpSecurityStartTLS = "starttls"
+	smtpSecurityTLS      = "tls"
+
+	smtpAuthNone  = "none"
+	smtpAuthPlain = "plain"
+
+	smtpTimeout = time.Minute
+)
+
+// the envelope sender (MAIL FROM) defaults to the From header address
+func smtpEnvelopeFrom(conf config.Smtp) string {
+	if conf.EnvelopeFrom != "" {
+		return conf.EnvelopeFrom
+	}
+	return conf.From
+}
+
+// authentication is used when a password is configured, unless it is explicitly disabled; "plain" requires the password
+func smtpAuth(conf config.Smtp) (smtp.Auth, error) {
+	username := conf.Username
+	if username == "" {
+		username = conf.From
+	}
+	switch conf.Auth {
+	case smtpAuthNone:
+		return nil, nil
+	case smtpAuthPlain:
+		if config.SmtpPassword == "" {
+			return nil, fmt.Errorf("smtp authentication is required but no password is configured")
+		}
+		return smtp.PlainAuth("", username, config.SmtpPassword, conf.Host), nil
+	case "":
+		if config.SmtpPassword == "" {
+			return nil, nil
+		}
+		return smtp.PlainAuth("", username, config.SmtpPassword, conf.Host), nil
+	}
+	return nil, fmt.Errorf("unknown smtp auth mode: %v", conf.Auth)
+}
+
+// sends the message using the configured security mode:
+// "none" never upgrades the connection, "starttls" requires STARTTLS, "tls" connects with implicit TLS (e.g., port 465),
+// and when not set, STARTTLS is used only when the server offers it
+func sendSmtp(conf config.Smtp, auth smtp.Auth, from string, to []string, msg []byte) error {
+	switch conf.Security {
+	case "", smtpSecurityNone, smtpSecurityStartTLS, smtpSecurityTLS:
+	default:
+		return fmt.Errorf("unknown smtp security mode: %v", conf.Security)
+	}
+	addr := net.JoinHostPort(conf.Host, conf.Port)
+	tlsConfig := &tls.Config{ServerName: conf.Host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if conf.Security == smtpSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to smtp server %v failed: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	c, err := smtp.NewClient(conn, conf.Host)
+	if err != nil {
+		conn.Close()
+		return smtpError("greeting", err)
+	}
+	defer c.Close()
+
+	if conf.Security == smtpSecurityStartTLS || conf.Security == "" {
+		ok, _ := c.Extension("STARTTLS")
+		if !ok && conf.Security == smtpSecurityStartTLS {
+			return fmt.Errorf("smtp server %v does not support STARTTLS", addr)
+		}
+		if ok {
+			if err = c.StartTLS(tlsConfig); err != nil {
+				return smtpError("STARTTLS", err)
+			}
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp server %v does not support authentication", addr)
+		}
+		if err = c.Auth(auth); err != nil {
+			return smtpError("AUTH", err)
+		}
+	}
+	if err = c.Mail(from); err != nil {
+		return smtpError("MAIL FROM", err)
+	}
+	for _, rcpt := range to {
+		if err = c.Rcpt(rcpt); err != nil {
+			return smtpError("RCPT TO", err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return smtpError("DATA", err)
+	}
+	if _, err = w.Write(msg); err != nil {
+		return smtpError("DATA", err)
+	}
+	if err = w.Close(); err != nil {
+		return smtpError("DATA", err)
+	}
+	if err = c.Quit(); err != nil {
+		return smtpError("QUIT", err)
+	}
+	return nil
+}
+
+// keeps the response code of the server in the error message
+func smtpError(command string, err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return fmt.Errorf("smtp %v failed with response code %d: %v", command, protoErr.Code, protoErr.Msg)
+	}
+	return fmt.Errorf("smtp %v failed: %w", command, err)
+}
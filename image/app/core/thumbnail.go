@@ -0,0 +1,96 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"integration/app/config"
+	"integration/app/logging"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+func isImage(name string) bool {
+	return strings.HasPrefix(mime.TypeByExtension(strings.ToLower(filepath.Ext(name))), "image/")
+}
+
+// the extension can be misleading, the content is checked by sniffing the first bytes of the file at the source
+func hasImageContent(s types.Stream) (bool, error) {
+	if s.Open == nil {
+		return false, fmt.Errorf("no stream")
+	}
+	reader, err := s.Open()
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+	b := make([]byte, 512)
+	n, err := io.ReadFull(reader, b)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return strings.HasPrefix(http.DetectContentType(b[:n]), "image/"), nil
+}
+
+// the image designated in the store request, or the copied images (in the order of the file ids) when none is designated
+func thumbnailCandidates(job Job) ([]string, bool) {
+	if !config.GetConfig().Options.SetDatasetThumbnail {
+		return nil, false
+	}
+	if job.Thumbnail != "" {
+		node, ok := job.WritableNodes[job.Thumbnail]
+		return []string{job.Thumbnail}, ok && node.Action != tree.Delete && isImage(node.Name)
+	}
+	keys := []string{}
+	for k, v := range job.WritableNodes {
+		if v.Action != tree.Delete && isImage(v.Name) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, len(keys) > 0
+}
+
+// best effort: failing to set the thumbnail does not fail the job, the files are already copied;
+// the first candidate that was copied and has image content becomes the thumbnail
+func setThumbnail(ctx context.Context, dest DestinationPlugin, job Job, candidates []string, streams map[string]types.Stream) {
+	for _, id := range candidates {
+		_, notWritten := job.WritableNodes[id]
+		if notWritten || slices.Contains(job.Vanished, id) || slices.Contains(job.Failed, id) {
+			logging.Logger.Printf("%v: %v was not copied, it is not used as dataset thumbnail\n", job.PersistentId, id)
+			continue
+		}
+		ok, err := hasImageContent(streams[id])
+		if err != nil || !ok {
+			logging.Logger.Printf("%v: %v is not an image, it is not used as dataset thumbnail: %v\n", job.PersistentId, id, err)
+			continue
+		}
+		err = doSetThumbnail(ctx, dest, job, id)
+		if err != nil {
+			logging.Logger.Printf("%v: setting the dataset thumbnail failed: %v\n", job.PersistentId, err)
+		}
+		return
+	}
+}
+func doSetThumbnail(ctx context.Context, dest DestinationPlugin, job Job, id string) error {
+	if dest.SetThumbnail == nil {
+		return fmt.Errorf("thumbnails are not supported by the destination")
+	}
+	nm, err := dest.Query(ctx, job.PersistentId, job.DataverseKey, job.User)
+	if err != nil {
+		return err
+	}
+	node, ok := nm[id]
+	if !ok || node.Attributes.DestinationFile.Id == 0 {
+		return fmt.Errorf("%v not found in the dataset", id)
+	}
+	return dest.SetThumbnail(ctx, job.DataverseKey, job.User, job.PersistentId, node.Attributes.DestinationFile.Id)
+}
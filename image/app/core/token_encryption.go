@@ -0,0 +1,74 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"strings"
+)
+
+// prefix of the encrypted tokens in the cache, tokens without it were stored before the encryption was configured
+const encryptedTokenPrefix = "enc:v1:"
+
+var errTokenEncryptionKeyMissing = errors.New("cached OAuth token is encrypted, but no token encryption key is configured")
+
+// AES-256-GCM with the key derived from the configured key, so that the key file can contain a passphrase of any length
+func tokenCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(config.TokenEncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypts the token when the token encryption key is configured, returns it unchanged otherwise
+func encryptToken(plain []byte) (string, error) {
+	if config.TokenEncryptionKey == "" {
+		return string(plain), nil
+	}
+	gcm, err := tokenCipher()
+	if err != nil {
+		return "", fmt.Errorf("encrypting token failed: %w", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("encrypting token failed: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, nil)
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypts the cached token; tokens cached unencrypted (e.g., before the key was configured) are returned as they are
+func decryptToken(stored string) ([]byte, error) {
+	if !strings.HasPrefix(stored, encryptedTokenPrefix) {
+		return []byte(stored), nil
+	}
+	if config.TokenEncryptionKey == "" {
+		return nil, errTokenEncryptionKeyMissing
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedTokenPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting token failed: %w", err)
+	}
+	gcm, err := tokenCipher()
+	if err != nil {
+		return nil, fmt.Errorf("decrypting token failed: %w", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("decrypting token failed: ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting token failed (was the key changed?): %w", err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,79 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"integration/app/config"
+	"strings"
+	"testing"
+)
+
+const plainToken = `{"access_token":"gho_secret_access_token","refresh_token":"ghr_secret_refresh_token"}`
+
+func useTokenEncryptionKey(t *testing.T, key string) {
+	t.Helper()
+	previous := config.TokenEncryptionKey
+	config.TokenEncryptionKey = key
+	t.Cleanup(func() { config.TokenEncryptionKey = previous })
+}
+
+func TestTokenEncryptionRoundTrip(t *testing.T) {
+	useTokenEncryptionKey(t, "a passphrase of any length")
+	stored, err := encryptToken([]byte(plainToken))
+	if err != nil {
+		t.Fatalf("encrypting failed: %v", err)
+	}
+	if !strings.HasPrefix(stored, encryptedTokenPrefix) {
+		t.Errorf("the stored token must start with %q, got %q", encryptedTokenPrefix, stored)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedTokenPrefix))
+	if err != nil {
+		t.Fatalf("the stored token must be base64 encoded: %v", err)
+	}
+	for _, secret := range []string{"gho_secret_access_token", "ghr_secret_refresh_token"} {
+		if strings.Contains(stored, secret) || strings.Contains(string(sealed), secret) {
+			t.Errorf("the stored token contains the plaintext %v", secret)
+		}
+	}
+	if again, _ := encryptToken([]byte(plainToken)); again == stored {
+		t.Errorf("the nonce must differ for each encryption")
+	}
+
+	plain, err := decryptToken(stored)
+	if err != nil || string(plain) != plainToken {
+		t.Errorf("got %q (%v), expected %q", plain, err, plainToken)
+	}
+
+	useTokenEncryptionKey(t, "another key")
+	if _, err := decryptToken(stored); err == nil {
+		t.Errorf("decrypting with another key must fail")
+	}
+}
+
+func TestTokenEncryptionLegacyPlaintext(t *testing.T) {
+	useTokenEncryptionKey(t, "")
+	stored, err := encryptToken([]byte(plainToken))
+	if err != nil || stored != plainToken {
+		t.Errorf("without key the token must be stored unencrypted, got %q (%v)", stored, err)
+	}
+
+	useTokenEncryptionKey(t, "a passphrase of any length")
+	plain, err := decryptToken(plainToken)
+	if err != nil || string(plain) != plainToken {
+		t.Errorf("a token cached before the key was configured must be returned as it is, got %q (%v)", plain, err)
+	}
+}
+
+func TestTokenEncryptionKeyMissing(t *testing.T) {
+	useTokenEncryptionKey(t, "a passphrase of any length")
+	stored, err := encryptToken([]byte(plainToken))
+	if err != nil {
+		t.Fatalf("encrypting failed: %v", err)
+	}
+	useTokenEncryptionKey(t, "")
+	if _, err := decryptToken(stored); !errors.Is(err, errTokenEncryptionKeyMissing) {
+		t.Errorf("got %v, expected %v", err, errTokenEncryptionKeyMissing)
+	}
+}
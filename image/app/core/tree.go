@@ -4,7 +4,9 @@ package core
 
 import (
 	"context"
+	"integration/app/config"
 	"integration/app/tree"
+	"strings"
 )
 
 const (
@@ -14,12 +16,74 @@ const (
 )
 
 type CompareResponse struct {
-	Id          string      `json:"id"`
-	Status      int         `json:"status"`
-	Data        []tree.Node `json:"data"`
-	Url         string      `json:"url"`
-	MaxFileSize int64       `json:"maxFileSize,omitempty"`
-	Rejected    []string    `json:"rejected,omitempty"`
+	Id                string      `json:"id"`
+	Status            int         `json:"status"`
+	Data              []tree.Node `json:"data"`
+	Url               string      `json:"url"`
+	MaxFileSize       int64       `json:"maxFileSize,omitempty"`
+	MaxJobTotalBytes  int64       `json:"maxJobTotalBytes,omitempty"`  // maximum total size of the files selected for one store, see maxJobTotalBytes in the backend config
+	Rejected          []string    `json:"rejected,omitempty"`          // too large (see maxFileSize) or with a file name or folder path not accepted by Dataverse
+	NameRules         string      `json:"nameRules,omitempty"`         // description of the accepted file names and folder paths, set when files are rejected
+	Collisions        []string    `json:"collisions,omitempty"`        // rejected because another file would be stored under the same directory label and file name
+	CaseCollisions    []string    `json:"caseCollisions,omitempty"`    // the directory label and file name differ only by case from another file: rejected or renamed, see caseCollisions in the backend config
+	TooLong           []string    `json:"tooLong,omitempty"`           // the directory label and file name are longer than maxPathLength: rejected or truncated, see truncateLongPaths in the backend config
+	SkippedZeroByte   []string    `json:"skippedZeroByte,omitempty"`   // zero-byte files at the source, skipped by the zeroByteFiles policy
+	SkippedEmptyHash  []string    `json:"skippedEmptyHash,omitempty"`  // files for which the source returned no hash, skipped by the emptyHashPolicy
+	Embargoed         []string    `json:"embargoed,omitempty"`         // files under embargo at the source, left out as they can not be downloaded
+	MixedFixity       bool        `json:"mixedFixity,omitempty"`       // the files in the dataset use different hash types (only detected when detectDatasetHash is set)
+	EqualCount        int         `json:"equalCount,omitempty"`        // number of equal files left out of the data when omitEqualNodes is set
+	Attempts          int         `json:"attempts,omitempty"`          // failed attempts of the running job, it is retried automatically
+	ConfirmationToken string      `json:"confirmationToken,omitempty"` // to be presented by the store request, see requireStoreConfirmation in the backend config
+	Summary           *Summary    `json:"summary,omitempty"`           // totals per status, only set when compareSummary is configured
+}
+
+type Summary struct {
+	New     SummaryCount `json:"new"`     // files to add
+	Updated SummaryCount `json:"updated"` // files to update
+	Deleted SummaryCount `json:"deleted"` // files only present in the dataset, deleted when selected
+	Equal   SummaryCount `json:"equal"`
+	Unknown SummaryCount `json:"unknown"` // the hashes could not be compared yet
+}
+
+type SummaryCount struct {
+	Count int   `json:"count"`
+	Size  int64 `json:"size"` // bytes at the source, or in the dataset for the deleted files
+}
+
+// counts the files and sums their sizes per status, computed before leaving out the equal files (see OmitEqualNodes)
+func summarize(data []tree.Node) *Summary {
+	res := &Summary{}
+	for _, v := range data {
+		count, size := &res.Unknown, v.Attributes.RemoteFileSize
+		switch v.Status {
+		case tree.New:
+			count = &res.New
+		case tree.Updated:
+			count = &res.Updated
+		case tree.Deleted:
+			count, size = &res.Deleted, v.Attributes.DestinationFile.FileSize
+		case tree.Equal:
+			count = &res.Equal
+		}
+		count.Count++
+		count.Size += size
+	}
+	return res
+}
+
+// keeps only the nodes in the given directory (directory label) and its subdirectories, an empty directory keeps all nodes
+func FilterByDirectory(nm map[string]tree.Node, directory string) map[string]tree.Node {
+	directory = strings.Trim(directory, "/")
+	if directory == "" {
+		return nm
+	}
+	res := map[string]tree.Node{}
+	for k, v := range nm {
+		if v.Path == directory || strings.HasPrefix(v.Path, directory+"/") {
+			res[k] = v
+		}
+	}
+	return res
 }
 
 func MergeNodeMaps(to, from map[string]tree.Node) map[string]tree.Node {
@@ -39,28 +103,44 @@ func MergeNodeMaps(to, from map[string]tree.Node) map[string]tree.Node {
 			node.Attributes.RemoteHash = v.Attributes.RemoteHash
 			node.Attributes.RemoteHashType = v.Attributes.RemoteHashType
 			node.Attributes.URL = v.Attributes.URL
+			node.Attributes.Categories = v.Attributes.Categories
+			node.Attributes.SourceVersion = v.Attributes.SourceVersion
+			node.Attributes.SourcePath = v.Attributes.SourcePath
+			node.Attributes.Restricted = v.Attributes.Restricted
+			node.Attributes.EmbargoActive = v.Attributes.EmbargoActive
 		}
 		res[k] = node
 	}
 	return res
 }
 
-func Compare(ctx context.Context, in map[string]tree.Node, pid, dataverseKey, user string, addJobs bool) CompareResponse {
-	in, jobNeeded := localRehashToMatchRemoteHashType(ctx, dataverseKey, user, pid, in, addJobs)
+func Compare(ctx context.Context, in map[string]tree.Node, destination, pid, dataverseKey, user string, addJobs bool) CompareResponse {
+	dest := destinationOrDefault(destination)
+	in, jobNeeded := localRehashToMatchRemoteHashType(ctx, destination, dataverseKey, user, pid, in, addJobs)
+	metadataOnly := metadataOnlyUpdates(dest)
+	if metadataOnly {
+		in = matchMovedFiles(in)
+	}
 	data := []tree.Node{}
 	empty := false
 	for _, v := range in {
 		if !v.Attributes.IsFile {
 			continue
 		}
+		v.StatusReason = "" // the nodes are sent back by the frontend when polling
 		if v.Attributes.RemoteHash != "" {
 			switch {
 			case v.Attributes.DestinationFile.Hash == "":
 				v.Status = tree.New
 			case v.Attributes.DestinationFile.Hash == "?":
 				v.Status = tree.Unknown
+				v.StatusReason = tree.PendingRehash
 			case v.Attributes.DestinationFile.Hash != v.Attributes.RemoteHash:
 				v.Status = tree.Updated
+				v.StatusReason = tree.ContentDiffers
+			case metadataOnly && metadataDiffers(v):
+				v.Status = tree.Updated
+				v.StatusReason = tree.MetadataDiffers
 			case v.Attributes.DestinationFile.Hash == v.Attributes.RemoteHash:
 				v.Status = tree.Equal
 			}
@@ -71,15 +151,47 @@ func Compare(ctx context.Context, in map[string]tree.Node, pid, dataverseKey, us
 		empty = empty || v.Attributes.DestinationFile.Hash != ""
 	}
 	status := Finished
+	attempts := 0
 	if jobNeeded || IsLocked(ctx, pid) {
 		status = Updating
+		attempts = GetFailedAttempts(ctx, pid)
 	} else if empty {
 		status = New
 	}
-	return CompareResponse{
-		Id:     pid,
-		Status: status,
-		Data:   data,
-		Url:    Destination.GetRepoUrl(pid, false),
+	res := CompareResponse{
+		Id:       pid,
+		Status:   status,
+		Data:     data,
+		Url:      dest.GetRepoUrl(pid, false),
+		Attempts: attempts,
+	}
+	if config.GetConfig().Options.CompareSummary {
+		res.Summary = summarize(data)
+	}
+	return res
+}
+
+// leaves the equal files out of the response (only their number is returned) when omitEqualNodes is set, unless the full listing is requested
+func OmitEqualNodes(res CompareResponse, fullListing bool) CompareResponse {
+	if fullListing || !config.GetConfig().Options.OmitEqualNodes {
+		return res
+	}
+	data := []tree.Node{}
+	for _, v := range res.Data {
+		if v.Status == tree.Equal {
+			res.EqualCount++
+			continue
+		}
+		data = append(data, v)
+	}
+	res.Data = data
+	return res
+}
+
+// the description of the file at the destination, recording the source version when known
+func FileDescription(node tree.Node) string {
+	if node.Attributes.SourceVersion == "" {
+		return ""
 	}
+	return "source version: " + node.Attributes.SourceVersion
 }
@@ -33,6 +33,20 @@ type OauthTokenResponse struct {
 	OtherTokens           []OauthTokenResponse `json:"other_tokens"`
 }
 
+// providers like Globus issue tokens for multiple resource servers in one response, returns the token for the given resource server;
+// the main token is returned when the resource server is empty or not found
+func (t OauthTokenResponse) ForResourceServer(resourceServer string) OauthTokenResponse {
+	if resourceServer == "" || t.ResourceServer == resourceServer {
+		return t
+	}
+	for _, other := range t.OtherTokens {
+		if other.ResourceServer == resourceServer {
+			return other
+		}
+	}
+	return t
+}
+
 type OauthTokenResponseStrings struct {
 	AccessToken           string `json:"access_token"`
 	JwtToken              string `json:"id_token"`
@@ -0,0 +1,79 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"integration/app/config"
+	"time"
+)
+
+// job of the user, with the link to the dataset such that the user can find the job back after closing the browser
+type UserJob struct {
+	JobState
+	Added time.Time `json:"added"`
+	Url   string    `json:"url"`
+}
+
+type userJobEntry struct {
+	Key   string    `json:"key"`
+	Added time.Time `json:"added"`
+}
+
+// most recent jobs kept in the index of a user
+const maxUserJobs = 100
+
+func userJobsKey(user string) string {
+	return "user jobs: " + user
+}
+
+// returns the entries of the index, most recent first, without the entries older than jobStateRetention
+func getUserJobEntries(ctx context.Context, user string) ([]userJobEntry, error) {
+	values, err := config.GetRedis().LRange(ctx, userJobsKey(user), 0, maxUserJobs-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := []userJobEntry{}
+	for _, v := range values {
+		e := userJobEntry{}
+		if json.Unmarshal([]byte(v), &e) != nil || time.Since(e.Added) >= jobStateRetention {
+			continue
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// indexes the job by its user in a list holding the most recent jobs, the list expires together with the last job state (see jobStateRetention)
+func addUserJob(ctx context.Context, job Job) {
+	if job.Key == "" || job.User == "" {
+		return
+	}
+	b, _ := json.Marshal(userJobEntry{Key: job.Key, Added: time.Now()})
+	key := userJobsKey(job.User)
+	config.GetRedis().LPush(ctx, key, string(b))
+	config.GetRedis().LTrim(ctx, key, 0, maxUserJobs-1)
+	config.GetRedis().Expire(ctx, key, jobStateRetention)
+}
+
+// returns the jobs of the user with a known state, most recent first, only the jobs of the given dataset when persistentId is not empty
+func GetUserJobs(ctx context.Context, user, persistentId string) ([]UserJob, error) {
+	entries, err := getUserJobEntries(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	res := []UserJob{}
+	for _, e := range entries {
+		state, err := getJobState(ctx, e.Key)
+		if err != nil || state.User != user || (persistentId != "" && state.PersistentId != persistentId) {
+			continue
+		}
+		res = append(res, UserJob{
+			JobState: state,
+			Added:    e.Added,
+			Url:      destinationOrDefault(state.Destination).GetRepoUrl(state.PersistentId, false),
+		})
+	}
+	return res, nil
+}
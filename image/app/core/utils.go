@@ -5,10 +5,13 @@ package core
 import (
 	"context"
 	"fmt"
+	"html"
 	"integration/app/config"
 	"integration/app/logging"
 	"net/http"
-	"net/smtp"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/libis/rdm-dataverse-go-api/api"
@@ -34,6 +37,26 @@ func GetUserFromHeader(h http.Header) string {
 	return getValueFromHeader(h, hn)
 }
 
+var sessionIdR = regexp.MustCompile(`^[a-zA-Z0-9_\-]{1,128}$`)
+
+// checks the presence of the user in the header without resolving it (no call to Dataverse)
+func HasUserInHeader(h http.Header) bool {
+	if getValueFromHeader(h, "X-Forwarded-Access-Token") != "" {
+		return true
+	}
+	hn := "Ajp_uid"
+	if config.GetConfig().Options.UserHeaderName != "" {
+		hn = config.GetConfig().Options.UserHeaderName
+	}
+	return getValueFromHeader(h, hn) != ""
+}
+
+// the session id is optional, but when present it is used in cache keys and must be well-formed
+func ValidSessionId(h http.Header) bool {
+	fromHeader := getValueFromHeader(h, "Ajp_shib-Session-Id")
+	return fromHeader == "" || sessionIdR.MatchString(fromHeader)
+}
+
 func GetSessionId(h http.Header) string {
 	fromHeader := getValueFromHeader(h, "Ajp_shib-Session-Id")
 	if fromHeader == "" {
@@ -52,11 +75,14 @@ func SendMail(msg string, to []string) error {
 		return nil
 	}
 	conf := config.GetConfig().Options.SmtpConfig
-	var auth smtp.Auth
-	if config.SmtpPassword != "" {
-		auth = smtp.PlainAuth("", conf.From, config.SmtpPassword, conf.Host)
+	auth, err := smtpAuth(conf)
+	if err == nil {
+		err = sendSmtp(conf, auth, smtpEnvelopeFrom(conf), to, []byte(fmt.Sprintf("From: %v\r\n%v", conf.From, msg)))
 	}
-	return smtp.SendMail(conf.Host+":"+conf.Port, auth, conf.From, to, []byte(msg))
+	if err != nil {
+		logging.Logger.Printf("sending mail to %v failed: %v\n", to, err)
+	}
+	return err
 }
 
 func getSubjectOnSuccess(job Job) string {
@@ -64,6 +90,12 @@ func getSubjectOnSuccess(job Job) string {
 	if config.GetConfig().Options.MailConfig.SubjectOnSuccess != "" {
 		template = config.GetConfig().Options.MailConfig.SubjectOnSuccess
 	}
+	if len(job.Failed) > 0 {
+		template = "[rdm-integration] Some files could not be uploaded to dataset %v"
+		if config.GetConfig().Options.MailConfig.SubjectOnPartial != "" {
+			template = config.GetConfig().Options.MailConfig.SubjectOnPartial
+		}
+	}
 	return fmt.Sprintf(template, job.PersistentId)
 }
 
@@ -72,7 +104,49 @@ func getContentOnSuccess(job Job) string {
 	if config.GetConfig().Options.MailConfig.ContentOnSuccess != "" {
 		template = config.GetConfig().Options.MailConfig.ContentOnSuccess
 	}
-	return fmt.Sprintf(template, Destination.GetRepoUrl(job.PersistentId, true), job.PersistentId)
+	if len(job.Failed) > 0 {
+		template = "Not all files could be updated: some files kept failing and were skipped. You can review the content and edit the metadata in the dataset: <a href=\"%v\">%v</a>."
+		if config.GetConfig().Options.MailConfig.ContentOnPartial != "" {
+			template = config.GetConfig().Options.MailConfig.ContentOnPartial
+		}
+	}
+	res := fmt.Sprintf(template, repoUrl(job), job.PersistentId)
+	if len(job.Failed) > 0 {
+		res += fmt.Sprintf("<br><br>The following files could not be written and were skipped: %v.", escapedList(job.Failed))
+	}
+	if len(job.Vanished) > 0 {
+		res += fmt.Sprintf("<br><br>The following files were removed from the source after the comparison and were skipped: %v.", escapedList(job.Vanished))
+	}
+	if len(job.Conflicts) > 0 {
+		res += fmt.Sprintf("<br><br>The following files were modified in the dataset after the comparison and were not replaced: %v.", escapedList(job.Conflicts))
+	}
+	return res
+}
+
+// the file names come from the source and are escaped, such that they are shown as text in the html email
+func escapedList(names []string) string {
+	escaped := make([]string, len(names))
+	for i, n := range names {
+		escaped[i] = html.EscapeString(n)
+	}
+	return strings.Join(escaped, ", ")
+}
+
+func getSubjectOnStart(job Job) string {
+	template := "[rdm-integration] Started uploading files to dataset %v"
+	if config.GetConfig().Options.MailConfig.SubjectOnStart != "" {
+		template = config.GetConfig().Options.MailConfig.SubjectOnStart
+	}
+	return fmt.Sprintf(template, job.PersistentId)
+}
+
+// the template gets the link to the dataset, the persistent id and the job key (for the job status API)
+func getContentOnStart(job Job) string {
+	template := "Your request was accepted and the files are being uploaded to the dataset <a href=\"%v\">%v</a>. You will receive an email when the upload is done. The key of the job is %v, it can be used to request its status."
+	if config.GetConfig().Options.MailConfig.ContentOnStart != "" {
+		template = config.GetConfig().Options.MailConfig.ContentOnStart
+	}
+	return fmt.Sprintf(template, repoUrl(job), job.PersistentId, job.Key)
 }
 
 func getSubjectOnError(_ error, job Job) string {
@@ -88,5 +162,21 @@ func getContentOnError(_ error, job Job) string {
 	if config.GetConfig().Options.MailConfig.ContentOnError != "" {
 		template = config.GetConfig().Options.MailConfig.ContentOnError
 	}
-	return fmt.Sprintf(template, Destination.GetRepoUrl(job.PersistentId, true), job.PersistentId)
+	return fmt.Sprintf(template, repoUrl(job), job.PersistentId)
+}
+
+// link to the current version of the dataset: the draft when there is one, the published version otherwise
+func repoUrl(job Job) string {
+	dest := job.destination()
+	if dest.IsDraft == nil {
+		return dest.GetRepoUrl(job.PersistentId, true)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	draft, err := dest.IsDraft(ctx, job.DataverseKey, job.User, job.PersistentId)
+	if err != nil {
+		logging.Logger.Printf("%v: could not determine the version state, linking to the draft: %v\n", job.PersistentId, err)
+		draft = true
+	}
+	return dest.GetRepoUrl(job.PersistentId, draft)
 }
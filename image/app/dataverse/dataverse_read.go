@@ -8,11 +8,14 @@ import (
 	"github.com/libis/rdm-dataverse-go-api/api"
 	"integration/app/config"
 	"integration/app/core"
+	"integration/app/logging"
+	dv "integration/app/plugin/impl/dataverse"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"io"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 )
 
@@ -44,14 +47,39 @@ func GetNodeMap(ctx context.Context, persistentId, token, user string) (map[stri
 		return nil, err
 	}
 	if res.Status != "OK" {
+		if datasetNotFound(shortContext, persistentId) {
+			return nil, fmt.Errorf("listing files for %s failed: %w: %s", persistentId, core.ErrDatasetNotFound, res.Message)
+		}
 		return nil, fmt.Errorf("listing files for %s failed: %+v", persistentId, res)
 	}
-	mapped := mapToNodes(res.Data)
+	// OK with no files (or null data) is a valid empty dataset, only the entries themselves can be malformed
+	data, err := dv.ValidEntries(persistentId, res.Data, func(d api.MetaData) api.MetaData { return d })
+	if err != nil {
+		return nil, err
+	}
+	mapped := mapToNodes(data)
 	//check known hashes cache
 	core.CheckKnownHashes(ctx, persistentId, mapped)
 	return mapped, nil
 }
 
+// the api library does not expose the status code, the existence of the dataset is checked with an anonymous request:
+// Dataverse responds with 404 to unknown persistent ids only, an existing unpublished dataset is refused with another status
+func datasetNotFound(ctx context.Context, persistentId string) bool {
+	url := fmt.Sprintf("%s/api/v1/datasets/:persistentId?persistentId=%s", config.GetConfig().DataverseServer, persistentId)
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false
+	}
+	r, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return false
+	}
+	defer r.Body.Close()
+	io.Copy(io.Discard, r.Body)
+	return r.StatusCode == http.StatusNotFound
+}
+
 func mapToNodes(data []api.MetaData) map[string]tree.Node {
 	res := map[string]tree.Node{}
 	for _, d := range data {
@@ -77,6 +105,7 @@ func mapToNodes(data []api.MetaData) map[string]tree.Node {
 					Hash:              hash,
 					HashType:          hashType,
 					StorageIdentifier: d.DataFile.StorageIdentifier,
+					Description:       d.Description,
 				},
 				IsFile: true,
 			},
@@ -85,25 +114,47 @@ func mapToNodes(data []api.MetaData) map[string]tree.Node {
 	return res
 }
 
+// support of persistent ids (containing a slash) by the admin permissions API (https://github.com/IQSS/dataverse/pull/8995),
+// detected with the first successful permission check: the persistent id is tried first, then the database id of the dataset
+const (
+	slashUnknown int32 = iota
+	slashSupported
+	slashUnsupported
+)
+
+var slashInPermissions atomic.Int32
+
 func CheckPermission(ctx context.Context, token, user, persistentId string) error {
 	shortContext, cancel := context.WithTimeout(ctx, dvContextDuration)
 	defer cancel()
 	if config.UnblockKey == "" {
 		return nil
 	}
-	path := fmt.Sprintf("/api/v1/admin/permissions/:persistentId?persistentId=%s&unblock-key=%s", persistentId, config.UnblockKey)
-	if slashInPermissions != "true" {
-		var err error
-		path, err = noSlashPermissionUrl(shortContext, persistentId, token, user)
-		if err != nil {
+	res := api.Permissions{}
+	if slashInPermissions.Load() != slashUnsupported {
+		path := fmt.Sprintf("/api/v1/admin/permissions/:persistentId?persistentId=%s&unblock-key=%s", persistentId, config.UnblockKey)
+		err := api.Do(shortContext, GetRequest(path, "GET", user, token, nil, nil), &res)
+		// an older Dataverse can respond with something else than JSON, the database id is then tried
+		if err != nil && slashInPermissions.Load() == slashSupported {
 			return err
 		}
+		if err == nil && res.Status == "OK" && slashInPermissions.CompareAndSwap(slashUnknown, slashSupported) {
+			logging.Logger.Println("the permissions API supports persistent ids")
+		}
 	}
-	res := api.Permissions{}
-	req := GetRequest(path, "GET", user, token, nil, nil)
-	err := api.Do(shortContext, req, &res)
-	if err != nil {
-		return err
+	if res.Status != "OK" && slashInPermissions.Load() != slashSupported {
+		path, err := noSlashPermissionUrl(shortContext, persistentId, token, user)
+		if err != nil {
+			return err
+		}
+		res = api.Permissions{}
+		err = api.Do(shortContext, GetRequest(path, "GET", user, token, nil, nil), &res)
+		if err != nil {
+			return err
+		}
+		if res.Status == "OK" && slashInPermissions.CompareAndSwap(slashUnknown, slashUnsupported) {
+			logging.Logger.Println("the permissions API does not support persistent ids, the database id of the dataset is used")
+		}
 	}
 	if res.Status != "OK" {
 		return fmt.Errorf("permission check status is %s for dataset %s", res.Status, persistentId)
@@ -123,6 +174,7 @@ func noSlashPermissionUrl(ctx context.Context, persistentId, token, user string)
 		Id int `json:"id"`
 	}
 	type Res struct {
+		api.DvResponse
 		Data `json:"data"`
 	}
 	path := "/api/v1/datasets/:persistentId?persistentId=" + persistentId
@@ -133,6 +185,9 @@ func noSlashPermissionUrl(ctx context.Context, persistentId, token, user string)
 		return "", err
 	}
 	id := res.Id
+	if id == 0 && datasetNotFound(shortContext, persistentId) {
+		return "", fmt.Errorf("%w: %v", core.ErrDatasetNotFound, persistentId)
+	}
 	if id == 0 {
 		return "", fmt.Errorf("dataset %v not found", persistentId)
 	}
@@ -151,6 +206,28 @@ func GetDatasetUrl(pid string, draft bool) string {
 	return fmt.Sprintf("%v/dataset.xhtml?%vpersistentId=%v", url, draftVersion, pid)
 }
 
+type versionStateResponse struct {
+	api.DvResponse
+	Data struct {
+		VersionState string `json:"versionState"`
+	} `json:"data"`
+}
+
+func IsDraft(ctx context.Context, token, user, persistentId string) (bool, error) {
+	shortContext, cancel := context.WithTimeout(ctx, dvContextDuration)
+	defer cancel()
+	path := "/api/v1/datasets/:persistentId/versions/:latest?excludeFiles=true&persistentId=" + persistentId
+	res := versionStateResponse{}
+	err := api.Do(shortContext, GetRequest(path, "GET", user, token, nil, nil), &res)
+	if err != nil {
+		return false, err
+	}
+	if res.Status != "OK" {
+		return false, fmt.Errorf("getting the version of %v failed: %v", persistentId, res.Message)
+	}
+	return res.Data.VersionState == "DRAFT", nil
+}
+
 func DownloadFile(ctx context.Context, token, user string, id int64) (io.ReadCloser, error) {
 	path := fmt.Sprintf("/api/v1/access/datafile/%v", id)
 	req := GetRequest(path, "GET", user, token, nil, nil)
@@ -3,6 +3,7 @@
 package dataverse
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -42,10 +43,12 @@ func SaveAfterDirectUpload(ctx context.Context, replace bool, token, user, persi
 	for i, v := range nodes {
 		jsonData = append(jsonData, api.JsonData{
 			FileToReplaceId:   v.Attributes.DestinationFile.Id,
-			ForceReplace:      v.Attributes.DestinationFile.Id != 0,
+			ForceReplace:      v.Attributes.DestinationFile.Id != 0 && core.ForceReplace(),
 			StorageIdentifier: storageIdentifiers[i],
 			FileName:          v.Name,
 			DirectoryLabel:    v.Path,
+			Categories:        v.Attributes.Categories,
+			Description:       core.FileDescription(v),
 			MimeType:          "application/octet-stream", // default that will be replaced by Dataverse while adding/replacing the file
 			TabIngest:         false,
 			Checksum: &api.Checksum{
@@ -55,10 +58,28 @@ func SaveAfterDirectUpload(ctx context.Context, replace bool, token, user, persi
 		})
 	}
 
+	if batchFileAdd != "true" {
+		for _, v := range jsonData {
+			path := "/api/v1/datasets/:persistentId/add?persistentId=" + persistentId
+			if v.FileToReplaceId != 0 {
+				path = "/api/v1/files/" + fmt.Sprint(v.FileToReplaceId) + "/replace"
+			}
+			err := saveStorageIdentifiers(ctx, path, token, user, v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	path := "/api/v1/datasets/:persistentId/addFiles?persistentId=" + persistentId
 	if replace {
 		path = "/api/v1/datasets/:persistentId/replaceFiles?persistentId=" + persistentId
 	}
+	return saveStorageIdentifiers(ctx, path, token, user, jsonData)
+}
+
+// registers the directly uploaded file(s): one file with the add or replace API, a list with the addFiles or replaceFiles API
+func saveStorageIdentifiers(ctx context.Context, path, token, user string, jsonData any) error {
 	data, err := json.Marshal(jsonData)
 	if err != nil {
 		return err
@@ -88,15 +109,17 @@ func requestBody(data []byte) (io.Reader, string) {
 	return body, writer.FormDataContentType()
 }
 
-func ApiAddReplaceFile(ctx context.Context, dbId int64, id, token, user, persistentId string, wg *sync.WaitGroup, async_err *core.ErrorHolder) (io.WriteCloser, error) {
-	if strings.HasSuffix(id, ".zip") {
-		// workaround: upload via SWORD api
-		if dbId != 0 {
-			err := DeleteFile(ctx, token, user, dbId)
-			if err != nil {
-				return nil, err
-			}
+func ApiAddReplaceFile(ctx context.Context, dbId int64, id string, categories []string, description string, token, user, persistentId string, wg *sync.WaitGroup, async_err *core.ErrorHolder) (io.WriteCloser, error) {
+	zipMode, isZip := zipUploadMode(id)
+	if isZip && dbId != 0 {
+		// the wrapped zip can not replace a file: the old file is deleted and the new one added
+		err := DeleteFile(ctx, token, user, dbId)
+		if err != nil {
+			return nil, err
 		}
+		dbId = 0
+	}
+	if zipMode == zipUploadSword {
 		return uploadViaSword(ctx, dbId, id, token, user, persistentId, wg, async_err)
 	}
 
@@ -108,7 +131,13 @@ func ApiAddReplaceFile(ctx context.Context, dbId int64, id, token, user, persist
 	filename, dir := splitId(id)
 	jsonData := api.JsonData{
 		DirectoryLabel: dir,
-		ForceReplace:   dbId != 0,
+		Categories:     categories,
+		Description:    description,
+		ForceReplace:   dbId != 0 && core.ForceReplace(),
+	}
+	if isZip {
+		// the directory label is taken from the path in the wrapper
+		filename, jsonData.DirectoryLabel = filename+".zip", ""
 	}
 	jsonDataBytes, _ := json.Marshal(jsonData)
 	pr, pw := io.Pipe()
@@ -118,14 +147,17 @@ func ApiAddReplaceFile(ctx context.Context, dbId int64, id, token, user, persist
 	requestHeader := http.Header{}
 	requestHeader.Add("Content-Type", writer.FormDataContentType())
 
+	uploadCtx, cancel := overWireContext(ctx)
 	request := GetRequest(path, "POST", user, token, pr, requestHeader)
+	hasher := overWireHasher()
 
 	wg.Add(1)
 	go func(req *api.Request) {
 		defer wg.Done()
+		defer cancel()
 		defer pr.Close()
 		res := api.AddReplaceFileResponse{}
-		err := api.Do(ctx, request, &res)
+		err := api.Do(uploadCtx, request, &res)
 		if err != nil {
 			if async_err != nil {
 				async_err.Err = fmt.Errorf("writing file in %s failed: %s", persistentId, err)
@@ -136,10 +168,29 @@ func ApiAddReplaceFile(ctx context.Context, dbId int64, id, token, user, persist
 			if async_err != nil {
 				async_err.Err = fmt.Errorf("adding file failed: %+v", res)
 			}
+			return
+		}
+		if hasher != nil && async_err != nil {
+			async_err.Err = verifyOverWireChecksum(hasher, res)
 		}
 	}(request)
 
-	return core.NewWriterCloser(fw, fw, pw), nil
+	var content io.Writer = fw
+	var closer io.Closer = fw
+	if isZip {
+		zipWriter := zip.NewWriter(fw)
+		entry, err := zipWriter.Create(id)
+		if err != nil {
+			pw.CloseWithError(err)
+			return nil, err
+		}
+		content, closer = entry, zipWrapperCloser{zipWriter, fw}
+	}
+	if hasher != nil {
+		// the content is hashed before it is written to the pipe, the hash is complete when the response arrives
+		content = io.MultiWriter(hasher, content)
+	}
+	return core.NewWriterCloser(content, closer, pw), nil
 }
 
 func splitId(id string) (string, string) {
@@ -185,3 +236,63 @@ func DeleteFile(ctx context.Context, token, user string, id int64) error {
 	}
 	return nil
 }
+
+func SetThumbnail(ctx context.Context, token, user, persistentId string, fileId int64) error {
+	shortContext, cancel := context.WithTimeout(ctx, dvContextDuration)
+	defer cancel()
+	path := fmt.Sprintf("/api/v1/datasets/:persistentId/thumbnail/%d?persistentId=%s", fileId, persistentId)
+	res := api.DvResponse{}
+	req := GetRequest(path, "POST", user, token, nil, nil)
+	err := api.Do(shortContext, req, &res)
+	if err != nil {
+		return err
+	}
+	if res.Status != "OK" {
+		return fmt.Errorf("setting file %d as thumbnail of %s failed: %s", fileId, persistentId, res.Message)
+	}
+	return nil
+}
+
+// the metadata of a file with equal content at the source: the file is moved or renamed, and the description is updated when known
+type fileMetadata struct {
+	Label          string `json:"label"`
+	DirectoryLabel string `json:"directoryLabel"`
+	Description    string `json:"description,omitempty"`
+}
+
+func UpdateFileMetadata(ctx context.Context, token, user, persistentId string, node tree.Node) error {
+	shortContext, cancel := context.WithTimeout(ctx, dvContextDuration)
+	defer cancel()
+	filename, dir := splitId(node.Id)
+	jsonData, err := json.Marshal(fileMetadata{
+		Label:          filename,
+		DirectoryLabel: dir,
+		Description:    core.FileDescription(node),
+	})
+	if err != nil {
+		return err
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	err = writer.WriteField("jsonData", string(jsonData))
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	requestHeader := http.Header{}
+	requestHeader.Add("Content-Type", writer.FormDataContentType())
+	path := fmt.Sprintf("/api/v1/files/%d/metadata", node.Attributes.DestinationFile.Id)
+	res := api.DvResponse{}
+	req := GetRequest(path, "POST", user, token, body, requestHeader)
+	err = api.Do(shortContext, req, &res)
+	if err != nil {
+		return err
+	}
+	if res.Status != "OK" {
+		return fmt.Errorf("updating metadata of %s in %s failed: %s", node.Id, persistentId, res.Message)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package dataverse
+
+import (
+	"context"
+	"fmt"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"net/url"
+	"sort"
+
+	"github.com/libis/rdm-dataverse-go-api/api"
+)
+
+type metadataBlocksResponse struct {
+	api.DvResponse
+	Data []metadataBlock `json:"data"`
+}
+
+type metadataBlock struct {
+	Name   string                      `json:"name"`
+	Fields map[string]datasetFieldType `json:"fields"`
+}
+
+type datasetFieldType struct {
+	Name       string `json:"name"`
+	Title      string `json:"title"`
+	IsRequired bool   `json:"isRequired"`
+}
+
+type datasetVersionResponse struct {
+	api.DvResponse
+	Data datasetVersion `json:"data"`
+}
+
+type datasetVersion struct {
+	MetadataBlocks map[string]datasetMetadataBlock `json:"metadataBlocks"`
+}
+
+type datasetMetadataBlock struct {
+	Fields []datasetField `json:"fields"`
+}
+
+type datasetField struct {
+	TypeName string      `json:"typeName"`
+	Value    interface{} `json:"value"`
+}
+
+// returns the fields required by the collection that are still empty in the latest version of the dataset
+func MissingRequiredFields(ctx context.Context, token, user, collection, persistentId string) ([]types.SelectItem, error) {
+	if collection == "" {
+		collection = config.GetConfig().Options.RootDataverseId
+	}
+	shortContext, cancel := context.WithTimeout(ctx, dvContextDuration)
+	defer cancel()
+
+	blocks := metadataBlocksResponse{}
+	path := "/api/v1/dataverses/" + url.PathEscape(collection) + "/metadatablocks?returnDatasetFieldTypes=true&onlyDisplayedOnCreate=true"
+	err := api.Do(shortContext, GetRequest(path, "GET", user, token, nil, nil), &blocks)
+	if err != nil {
+		return nil, err
+	}
+	if blocks.Status != "OK" {
+		return nil, fmt.Errorf("getting metadata blocks of %v failed: %v", collection, blocks.Message)
+	}
+
+	version := datasetVersionResponse{}
+	path = "/api/v1/datasets/:persistentId/versions/:latest?persistentId=" + persistentId
+	err = api.Do(shortContext, GetRequest(path, "GET", user, token, nil, nil), &version)
+	if err != nil {
+		return nil, err
+	}
+	if version.Status != "OK" {
+		return nil, fmt.Errorf("getting metadata of %v failed: %v", persistentId, version.Message)
+	}
+
+	return missingFields(blocks.Data, version.Data), nil
+}
+
+func missingFields(blocks []metadataBlock, version datasetVersion) []types.SelectItem {
+	filled := map[string]bool{}
+	for _, b := range version.MetadataBlocks {
+		for _, f := range b.Fields {
+			filled[f.TypeName] = !isEmpty(f.Value)
+		}
+	}
+	res := []types.SelectItem{}
+	for _, b := range blocks {
+		for _, f := range b.Fields {
+			if f.IsRequired && !filled[f.Name] {
+				res = append(res, types.SelectItem{Label: f.Title, Value: f.Name})
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Value.(string) < res[j].Value.(string) })
+	return res
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package dataverse
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"hash"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/libis/rdm-dataverse-go-api/api"
+)
+
+// handling of zip files uploaded over the wire (no direct upload driver configured): Dataverse unzips uploaded zip files,
+// the zip file is therefore wrapped in another zip that is unzipped by Dataverse instead
+const (
+	zipUploadSword  = "sword"  // wrapped zip uploaded with the SWORD API (default)
+	zipUploadNative = "native" // wrapped zip uploaded with the native API, for installations without SWORD API
+)
+
+func zipUploadMode(id string) (string, bool) {
+	if !strings.HasSuffix(strings.ToLower(id), ".zip") {
+		return "", false
+	}
+	if config.GetConfig().Options.ZipUpload == zipUploadNative {
+		return zipUploadNative, true
+	}
+	return zipUploadSword, true
+}
+
+// the upload request of one file runs in the background while the file is streamed, it is cancelled when the timeout expires
+func overWireContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s := config.GetConfig().Options.OverWireUploadTimeout; s > 0 {
+		return context.WithTimeout(ctx, time.Duration(s)*time.Second)
+	}
+	return context.WithCancel(ctx)
+}
+
+// closes the zip wrapper before the multipart writer
+type zipWrapperCloser struct {
+	zipWriter *zip.Writer
+	outer     io.Closer
+}
+
+func (z zipWrapperCloser) Close() error {
+	err := z.zipWriter.Close()
+	if err != nil {
+		z.outer.Close()
+		return err
+	}
+	return z.outer.Close()
+}
+
+// the native add API does not accept a checksum for uploaded content (only for direct uploads), Dataverse calculates it itself:
+// when enabled, the checksum of the streamed content is calculated as well and compared with the one stored by Dataverse
+func overWireHasher() hash.Hash {
+	if !config.GetConfig().Options.VerifyOverWireChecksum {
+		return nil
+	}
+	h, _ := types.NewSourceHasher(config.GetConfig().Options.DefaultHash)
+	return h
+}
+
+func verifyOverWireChecksum(h hash.Hash, res api.AddReplaceFileResponse) error {
+	if len(res.Data.Files) == 0 || res.Data.Files[0].DataFile.Checksum == nil {
+		return fmt.Errorf("checksum verification failed: no checksum in the response")
+	}
+	stored := res.Data.Files[0].DataFile.Checksum
+	_, hashType := types.NewSourceHasher(config.GetConfig().Options.DefaultHash)
+	if !strings.EqualFold(strings.ReplaceAll(stored.Type, "-", ""), strings.ReplaceAll(hashType, "-", "")) {
+		return fmt.Errorf("checksum verification failed: expected %v checksum, got %v", hashType, stored.Type)
+	}
+	if calculated := fmt.Sprintf("%x", h.Sum(nil)); !strings.EqualFold(calculated, stored.Value) {
+		return fmt.Errorf("checksum verification failed: stored %v %v, calculated %v", stored.Type, stored.Value, calculated)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package dataverse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"integration/app/plugin/types"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/libis/rdm-dataverse-go-api/api"
+)
+
+type uploadUrlsResponse struct {
+	api.DvResponse
+	Data uploadUrls `json:"data"`
+}
+
+// either url is set (single part upload) or urls with the abort and complete paths (multipart upload)
+type uploadUrls struct {
+	Url               string            `json:"url"`
+	Urls              map[string]string `json:"urls"`
+	Abort             string            `json:"abort"`
+	Complete          string            `json:"complete"`
+	PartSize          int64             `json:"partSize"`
+	StorageIdentifier string            `json:"storageIdentifier"`
+}
+
+// uploads the file content to S3 using presigned URLs issued by Dataverse, no S3 credentials are needed;
+// returns the storage identifier to be used when registering the file with addFiles/replaceFiles
+func UploadToPresignedUrl(ctx context.Context, token, user, persistentId string, reader io.Reader, size int64) (string, error) {
+	res := uploadUrlsResponse{}
+	path := "/api/v1/datasets/:persistentId/uploadurls?persistentId=" + url.QueryEscape(persistentId) + "&size=" + fmt.Sprint(size)
+	err := api.Do(ctx, GetRequest(path, "GET", user, token, nil, nil), &res)
+	if err != nil {
+		return "", err
+	}
+	if res.Status != "OK" {
+		return "", fmt.Errorf("requesting upload urls for %s failed: %s", persistentId, res.Message)
+	}
+	if res.Data.Url != "" {
+		_, err = putPart(ctx, res.Data.Url, reader, size, true)
+		return res.Data.StorageIdentifier, err
+	}
+	err = multipartUpload(ctx, token, user, res.Data, reader, size)
+	return res.Data.StorageIdentifier, err
+}
+
+func multipartUpload(ctx context.Context, token, user string, urls uploadUrls, reader io.Reader, size int64) error {
+	if urls.PartSize <= 0 || len(urls.Urls) == 0 {
+		return fmt.Errorf("unexpected upload urls response: no url and no parts")
+	}
+	parts := make([]int, 0, len(urls.Urls))
+	for k := range urls.Urls {
+		n, err := strconv.Atoi(k)
+		if err != nil {
+			return fmt.Errorf("unexpected part number in upload urls response: %v", k)
+		}
+		parts = append(parts, n)
+	}
+	sort.Ints(parts)
+	eTags := map[string]string{}
+	remaining := size
+	for _, n := range parts {
+		partSize := min(urls.PartSize, remaining)
+		eTag, err := putPart(ctx, urls.Urls[fmt.Sprint(n)], io.LimitReader(reader, partSize), partSize, false)
+		if err != nil {
+			abortMultipartUpload(token, user, urls.Abort)
+			return err
+		}
+		eTags[fmt.Sprint(n)] = eTag
+		remaining -= partSize
+	}
+	data, _ := json.Marshal(eTags)
+	res := api.DvResponse{}
+	err := api.Do(ctx, GetRequest(urls.Complete, "PUT", user, token, bytes.NewReader(data), api.JsonContentHeader()), &res)
+	if err != nil {
+		abortMultipartUpload(token, user, urls.Abort)
+		return err
+	}
+	if res.Status != "OK" {
+		abortMultipartUpload(token, user, urls.Abort)
+		return fmt.Errorf("completing multipart upload failed: %s", res.Message)
+	}
+	return nil
+}
+
+// abort is best effort: the original error is reported to the caller
+func abortMultipartUpload(token, user, path string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dvContextDuration)
+	defer cancel()
+	stream, err := api.DoStream(ctx, GetRequest(path, "DELETE", user, token, nil, nil))
+	if err == nil {
+		stream.Close()
+	}
+}
+
+func putPart(ctx context.Context, u string, reader io.Reader, size int64, tagged bool) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, "PUT", u, reader)
+	if err != nil {
+		return "", err
+	}
+	// presigned urls do not support chunked transfer encoding
+	request.ContentLength = size
+	if size == 0 {
+		request.Body = http.NoBody
+	}
+	if tagged {
+		// Dataverse signs single part uploads with the temp tag, the tag is removed when the file is added to the dataset
+		request.Header.Add("x-amz-tagging", "dv-state=temp")
+	}
+	r, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		b, _ := types.ReadAll(r.Body)
+		return "", fmt.Errorf("uploading to presigned url failed: %d - %s", r.StatusCode, string(b))
+	}
+	return r.Header.Get("ETag"), nil
+}
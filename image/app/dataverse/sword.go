@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"integration/app/config"
 	"integration/app/core"
+	"integration/app/plugin/types"
 	"io"
 	"net/http"
 	"sync"
@@ -26,7 +27,7 @@ func swordDelete(ctx context.Context, token, _ string, id int64) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != 200 && r.StatusCode != 202 && r.StatusCode != 204 {
-		b, _ := io.ReadAll(r.Body)
+		b, _ := types.ReadAll(r.Body)
 		return fmt.Errorf("deleting file %d failed: %d - %s", id, r.StatusCode, string(b))
 	}
 	return nil
@@ -37,7 +38,8 @@ func uploadViaSword(ctx context.Context, _ int64, id, token, _, persistentId str
 	pr, pw := io.Pipe()
 	zipWriter := zip.NewWriter(pw)
 	writer, _ := zipWriter.Create(id)
-	request, _ := http.NewRequestWithContext(ctx, "POST", url, pr)
+	uploadCtx, cancel := overWireContext(ctx)
+	request, _ := http.NewRequestWithContext(uploadCtx, "POST", url, pr)
 	request.Header.Add("Content-Type", "application/zip")
 	request.Header.Add("Content-Disposition", "attachment;filename=example.zip")
 	request.Header.Add("Packaging", "http://purl.org/net/sword/package/SimpleZip")
@@ -46,6 +48,7 @@ func uploadViaSword(ctx context.Context, _ int64, id, token, _, persistentId str
 	wg.Add(1)
 	go func(req http.Request) {
 		defer wg.Done()
+		defer cancel()
 		defer pr.Close()
 		resp, err := http.DefaultClient.Do(request)
 		if err != nil {
@@ -56,10 +59,19 @@ func uploadViaSword(ctx context.Context, _ int64, id, token, _, persistentId str
 		}
 		defer resp.Body.Close()
 		if resp.StatusCode != 201 && async_err != nil {
-			b, _ := io.ReadAll(resp.Body)
-			async_err.Err = fmt.Errorf("writing file in %s failed: %d - %s", persistentId, resp.StatusCode, string(b))
+			b, _ := types.ReadAll(resp.Body)
+			async_err.Err = swordError(fmt.Errorf("writing file in %s failed: %d - %s", persistentId, resp.StatusCode, string(b)), resp.StatusCode)
 		}
 	}(*request)
 
 	return core.NewWriterCloser(writer, zipWriter, pw), nil
 }
+
+// client errors (authentication, validation) fail again when the file is re-streamed and are not retried,
+// network errors and server errors are retried (see maxFileAttempts)
+func swordError(err error, statusCode int) error {
+	if statusCode >= 400 && statusCode < 500 && statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests {
+		return core.Permanent(err)
+	}
+	return err
+}
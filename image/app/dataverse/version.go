@@ -9,7 +9,7 @@ import (
 	"github.com/libis/rdm-dataverse-go-api/api"
 	"integration/app/config"
 	"integration/app/logging"
-	"io"
+	"integration/app/plugin/types"
 	"net/http"
 	"strconv"
 	"strings"
@@ -23,8 +23,13 @@ var defaultVersion dvVersion = "5.14"
 var filesCleanup = "5.13"
 var urlSigning = "5.14"
 var directUpload = "5.14"
-var slashInPermissions = "https://github.com/IQSS/dataverse/pull/8995" // will be replaced with version when pull request is merged
 var nativeApiDelete = "5.14"
+var batchFileAdd = "5.13" // addFiles and replaceFiles registering the files after direct upload in one call, the add and replace APIs per file otherwise
+
+const (
+	fileAddBatch   = "batch"
+	fileAddPerFile = "perfile"
+)
 
 func init() {
 	if config.GetConfig().DataverseServer != "" {
@@ -46,14 +51,24 @@ func Init() {
 		logging.Logger.Printf("version %v >= %v: direct upload feature is on", version, directUpload)
 		directUpload = "true"
 	}
-	if version.GreaterOrEqual(slashInPermissions) {
-		logging.Logger.Printf("version %v >= %v: slash in permissions feature is on", version, slashInPermissions)
-		slashInPermissions = "true"
-	}
 	if version.GreaterOrEqual(nativeApiDelete) {
 		logging.Logger.Printf("version %v >= %v: native API delete feature is on", version, nativeApiDelete)
 		nativeApiDelete = "true"
 	}
+	// the configured API takes precedence over the version, the batch APIs are not reliable in all versions and set-ups
+	switch strings.ToLower(config.GetConfig().Options.FileAddApi) {
+	case fileAddBatch:
+		batchFileAdd = "true"
+	case fileAddPerFile:
+		batchFileAdd = "false"
+	default:
+		if version.GreaterOrEqual(batchFileAdd) {
+			logging.Logger.Printf("version %v >= %v: batch file add feature is on", version, batchFileAdd)
+			batchFileAdd = "true"
+		}
+	}
+	logging.Logger.Printf("Dataverse %v capabilities: files cleanup: %v, url signing: %v, direct upload: %v, native API delete: %v, batch file add: %v\n",
+		version, filesCleanup == "true", urlSigning == "true", directUpload == "true", nativeApiDelete == "true", batchFileAdd == "true")
 }
 
 func getVersion() dvVersion {
@@ -73,12 +88,12 @@ func getVersion() dvVersion {
 		return defaultVersion
 	}
 	defer r.Body.Close()
-	b, _ := io.ReadAll(r.Body)
+	b, _ := types.ReadAll(r.Body)
 	res := api.VersionResponse{}
+	json.Unmarshal(b, &res)
 	if r.StatusCode != 200 {
-		logging.Logger.Println("error when getting version:", res.Message)
+		logging.Logger.Println("error when getting version:", r.StatusCode, res.Message)
 	}
-	json.Unmarshal(b, &res)
 	logging.Logger.Println("Dataverse version:", res.Data.Version)
 	ver := res.Data.Version
 	if ver == "" {
@@ -96,7 +111,7 @@ func (v1 dvVersion) GreaterOrEqual(v2 string) bool {
 		l = len(split2)
 	}
 	for i := 0; i < l; i++ {
-		n1, _ := strconv.Atoi(split1[i])
+		n1, _ := strconv.Atoi(leadingDigits(split1[i]))
 		n2, err := strconv.Atoi(split2[i])
 		if err != nil || n1 < n2 {
 			return false
@@ -107,3 +122,12 @@ func (v1 dvVersion) GreaterOrEqual(v2 string) bool {
 	}
 	return len(v1) >= len(v2)
 }
+
+// version parts can have suffixes, e.g., "6.2-SNAPSHOT"
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
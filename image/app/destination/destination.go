@@ -5,10 +5,11 @@ package destination
 import (
 	"integration/app/core"
 	"integration/app/dataverse"
+	"integration/app/invenio"
 )
 
-func SetDataverseAsDestination() {
-	core.Destination = core.DestinationPlugin{
+func init() {
+	core.RegisterDestination("dataverse", core.DestinationPlugin{
 		IsDirectUpload:        dataverse.IsDirectUpload,
 		CheckPermission:       dataverse.CheckPermission,
 		CreateNewRepo:         dataverse.CreateNewDataset,
@@ -21,5 +22,37 @@ func SetDataverseAsDestination() {
 		GetStream:             dataverse.DownloadFile,
 		Query:                 dataverse.GetNodeMap,
 		GetUserEmail:          dataverse.GetUserEmail,
+		MissingMetadata:       dataverse.MissingRequiredFields,
+		IsDraft:               dataverse.IsDraft,
+		UploadToPresignedUrl:  dataverse.UploadToPresignedUrl,
+		SetThumbnail:          dataverse.SetThumbnail,
+		UpdateFileMetadata:    dataverse.UpdateFileMetadata,
+	})
+	core.RegisterDestination("invenio", core.DestinationPlugin{
+		IsDirectUpload:        invenio.IsDirectUpload,
+		CheckPermission:       invenio.CheckPermission,
+		CreateNewRepo:         invenio.CreateNewRecord,
+		GetRepoUrl:            invenio.GetRecordUrl,
+		WriteOverWire:         invenio.WriteOverWire,
+		SaveAfterDirectUpload: invenio.SaveAfterDirectUpload,
+		CleanupLeftOverFiles:  invenio.CleanupLeftOverFiles,
+		DeleteFile:            invenio.DeleteFile,
+		Options:               invenio.Records,
+		GetStream:             invenio.DownloadFile,
+		Query:                 invenio.GetNodeMap,
+		GetUserEmail:          invenio.GetUserEmail,
+		IsDraft:               invenio.IsDraft,
+	})
+}
+
+func SetDataverseAsDestination() {
+	SetDestination("dataverse")
+}
+
+// sets the default destination, used when the request does not specify one
+func SetDestination(name string) {
+	err := core.SetDefaultDestination(name)
+	if err != nil {
+		panic(err)
 	}
 }
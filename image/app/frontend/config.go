@@ -6,12 +6,14 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"integration/app/common"
 	"integration/app/config"
 	"integration/app/core"
 	"integration/app/logging"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 )
 
 //go:embed default_frontend_config.json
@@ -31,13 +33,15 @@ func init() {
 	if err != nil {
 		panic(fmt.Errorf("could not unmarshal config: %v", err))
 	}
-	for _, v := range Config.Plugins {
-		core.PluginConfig[v.Id] = v
-	}
-	core.RedirectUri = Config.RedirectUri
+	core.SetOauthConfig(Config.Plugins, Config.RedirectUri)
 }
 
+// the configuration is completed on the first request, concurrent requests must wait for it
+var configMutex sync.Mutex
+
 func GetConfig(w http.ResponseWriter, r *http.Request) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
 	if Config.ExternalURL == "" {
 		Config.ExternalURL = config.GetExternalDestinationURL()
 		logging.Logger.Println(Config.ExternalURL)
@@ -48,8 +52,7 @@ func GetConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	b, err := json.Marshal(Config)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
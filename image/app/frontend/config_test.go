@@ -0,0 +1,39 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package frontend
+
+import (
+	"encoding/json"
+	"integration/app/config"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// run with -race: the configuration is completed by the first requests, served at the same time
+func TestGetConfigConcurrentRequests(t *testing.T) {
+	wg := sync.WaitGroup{}
+	responses := make([]*httptest.ResponseRecorder, 20)
+	for i := range responses {
+		responses[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			GetConfig(w, httptest.NewRequest(http.MethodGet, "/api/frontend/config", nil))
+		}(responses[i])
+	}
+	wg.Wait()
+	for _, w := range responses {
+		res := config.Configuration{}
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %v", w.Code)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("invalid configuration: %v", err)
+		}
+		if res.ExternalURL != config.GetExternalDestinationURL() {
+			t.Errorf("got external URL %q, expected %q", res.ExternalURL, config.GetExternalDestinationURL())
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package invenio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"integration/app/config"
+	"integration/app/core"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var invenioContextDuration = 5 * time.Minute
+
+type FilesResponse struct {
+	Entries []FileEntry `json:"entries"`
+}
+
+type FileEntry struct {
+	Key      string `json:"key"`
+	Checksum string `json:"checksum"`
+	Size     int64  `json:"size"`
+	FileId   string `json:"file_id"`
+	Status   string `json:"status"`
+}
+
+type Record struct {
+	Id       string   `json:"id"`
+	Metadata Metadata `json:"metadata"`
+}
+
+type Metadata struct {
+	Title string `json:"title"`
+}
+
+type SearchResponse struct {
+	Hits Hits `json:"hits"`
+}
+
+type Hits struct {
+	Hits []Record `json:"hits"`
+}
+
+type User struct {
+	Email string `json:"email"`
+}
+
+type fileRef struct {
+	RecordId string `json:"recordId"`
+	Key      string `json:"key"`
+}
+
+func server() string {
+	return strings.TrimSuffix(config.GetConfig().Options.InvenioServer, "/")
+}
+
+func doRequest(ctx context.Context, method, path, token string, body io.Reader, contentType string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, server()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Add("Accept", "application/json")
+	if contentType != "" {
+		request.Header.Add("Content-Type", contentType)
+	}
+	if token != "" {
+		request.Header.Add("Authorization", "Bearer "+token)
+	}
+	return http.DefaultClient.Do(request)
+}
+
+func do(ctx context.Context, method, path, token string, body io.Reader, contentType string, res interface{}) error {
+	r, err := doRequest(ctx, method, path, token, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	b, err := types.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return fmt.Errorf("%v %v failed: %d - %s", method, path, r.StatusCode, string(b))
+	}
+	if res == nil || len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, res)
+}
+
+func filesPath(recordId string) string {
+	return "/api/records/" + url.PathEscape(recordId) + "/draft/files"
+}
+
+func filePath(recordId, key string) string {
+	return filesPath(recordId) + "/" + url.PathEscape(key)
+}
+
+func IsDirectUpload() bool {
+	return false
+}
+
+func GetNodeMap(ctx context.Context, persistentId, token, _ string) (map[string]tree.Node, error) {
+	shortContext, cancel := context.WithTimeout(ctx, invenioContextDuration)
+	defer cancel()
+	res := FilesResponse{}
+	err := do(shortContext, "GET", filesPath(persistentId), token, nil, "", &res)
+	if err != nil {
+		return nil, fmt.Errorf("listing files for %s failed: %v", persistentId, err)
+	}
+	mapped := map[string]tree.Node{}
+	for _, e := range res.Entries {
+		id := fileId(persistentId, e.Key)
+		storeFileRef(shortContext, id, fileRef{persistentId, e.Key})
+		hashType, hash := mapChecksum(e.Checksum)
+		name, path := splitKey(e.Key)
+		mapped[e.Key] = tree.Node{
+			Id:   e.Key,
+			Name: name,
+			Path: path,
+			Attributes: tree.Attributes{
+				DestinationFile: tree.DestinationFile{
+					Id:                id,
+					FileSize:          e.Size,
+					Hash:              hash,
+					HashType:          hashType,
+					StorageIdentifier: e.FileId,
+				},
+				IsFile: true,
+			},
+		}
+	}
+	//check known hashes cache
+	core.CheckKnownHashes(ctx, persistentId, mapped)
+	return mapped, nil
+}
+
+// Invenio checksums are prefixed with the algorithm, e.g., "md5:...", other algorithms are returned as they are
+func mapChecksum(checksum string) (string, string) {
+	algorithm, value, found := strings.Cut(checksum, ":")
+	if !found {
+		return types.Md5, checksum
+	}
+	switch strings.ToLower(algorithm) {
+	case "sha1":
+		return types.SHA1, value
+	case "sha256":
+		return types.SHA256, value
+	case "sha512":
+		return types.SHA512, value
+	case "md5":
+		return types.Md5, value
+	}
+	return algorithm, value
+}
+
+func splitKey(key string) (string, string) {
+	spl := strings.Split(key, "/")
+	return spl[len(spl)-1], strings.Join(spl[:len(spl)-1], "/")
+}
+
+// Invenio identifies files by record id and key, the destination plugin interface uses numeric ids:
+// the id is derived from the key and the mapping is stored in the cache shared with the workers
+func fileId(recordId, key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(recordId + "/" + key))
+	return int64(h.Sum64()&math.MaxInt64) | 1
+}
+
+func storeFileRef(ctx context.Context, id int64, ref fileRef) {
+	b, _ := json.Marshal(ref)
+	config.GetRedis().Set(ctx, fmt.Sprintf("invenio file: %v", id), string(b), config.LockMaxDuration)
+}
+
+func getFileRef(ctx context.Context, id int64) (fileRef, error) {
+	res := fileRef{}
+	cached, ok, err := config.GetFromCache(ctx, fmt.Sprintf("invenio file: %v", id))
+	if err != nil {
+		return res, err
+	}
+	if !ok {
+		return res, fmt.Errorf("invenio file with id %v not found, please compare again", id)
+	}
+	err = json.Unmarshal([]byte(cached), &res)
+	return res, err
+}
+
+func CheckPermission(ctx context.Context, token, _, persistentId string) error {
+	shortContext, cancel := context.WithTimeout(ctx, invenioContextDuration)
+	defer cancel()
+	err := do(shortContext, "GET", "/api/records/"+url.PathEscape(persistentId)+"/draft", token, nil, "", nil)
+	if err != nil {
+		return fmt.Errorf("no permission to edit the draft of record %v: %v", persistentId, err)
+	}
+	return nil
+}
+
+func GetRecordUrl(pid string, draft bool) string {
+	if draft {
+		return fmt.Sprintf("%v/uploads/%v", server(), pid)
+	}
+	return fmt.Sprintf("%v/records/%v", server(), pid)
+}
+
+func IsDraft(ctx context.Context, token, _, persistentId string) (bool, error) {
+	r, err := doRequest(ctx, "GET", "/api/records/"+url.PathEscape(persistentId)+"/draft", token, nil, "")
+	if err != nil {
+		return false, err
+	}
+	r.Body.Close()
+	switch r.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("getting record %v failed: %d", persistentId, r.StatusCode)
+}
+
+func DownloadFile(ctx context.Context, token, _ string, id int64) (io.ReadCloser, error) {
+	ref, err := getFileRef(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r, err := doRequest(ctx, "GET", filePath(ref.RecordId, ref.Key)+"/content", token, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		b, _ := types.ReadAll(r.Body)
+		r.Body.Close()
+		return nil, fmt.Errorf("downloading file %v failed: %d - %s", ref.Key, r.StatusCode, string(b))
+	}
+	return r.Body, nil
+}
+
+// only records (datasets) can be listed, communities are not supported as collections
+func Records(ctx context.Context, objectType, _, searchTerm, token, _ string) ([]types.SelectItem, error) {
+	res := []types.SelectItem{}
+	if objectType == "Dataverse" {
+		return res, nil
+	}
+	search := SearchResponse{}
+	path := "/api/user/records?size=100&q=" + url.QueryEscape(searchTerm)
+	err := do(ctx, "GET", path, token, nil, "", &search)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range search.Hits.Hits {
+		res = append(res, types.SelectItem{
+			Label: v.Metadata.Title + " (" + v.Id + ")",
+			Value: v.Id,
+		})
+	}
+	return res, nil
+}
+
+func GetUserEmail(ctx context.Context, token, _ string) (string, error) {
+	u := User{}
+	err := do(ctx, "GET", "/api/me", token, nil, "", &u)
+	return u.Email, err
+}
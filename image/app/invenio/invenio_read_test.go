@@ -0,0 +1,137 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package invenio
+
+import (
+	"context"
+	"encoding/json"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// in-memory InvenioRDM draft files API of a single record
+type mockInvenio struct {
+	mu          sync.Mutex
+	entries     []FileEntry
+	requests    []string          // "METHOD path" in the order received
+	contents    map[string]string // key -> uploaded content
+	renamed     map[string]string // temporary key -> key
+	failContent bool
+}
+
+func newMockInvenio(t *testing.T, entries ...FileEntry) *mockInvenio {
+	t.Helper()
+	m := &mockInvenio{entries: entries, contents: map[string]string{}, renamed: map[string]string{}}
+	s := httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(s.Close)
+
+	mr := miniredis.RunT(t)
+	previousRedis := config.GetRedis()
+	config.SetRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	previousOptions := config.GetConfig().Options
+	config.SetOptions(config.OptionalConfig{InvenioServer: s.URL + "/"})
+	t.Cleanup(func() {
+		config.SetRedis(previousRedis)
+		config.SetOptions(previousOptions)
+	})
+	return m
+}
+
+func (m *mockInvenio) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, r.Method+" "+r.URL.Path)
+	const files = "/api/records/rec1/draft/files"
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, files), "/")
+	switch {
+	case r.Method == "GET" && r.URL.Path == files:
+		json.NewEncoder(w).Encode(FilesResponse{Entries: m.entries})
+	case r.Method == "POST" && r.URL.Path == files:
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT" && strings.HasSuffix(key, "/content"):
+		if m.failContent {
+			http.Error(w, "storage unavailable", http.StatusInternalServerError)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		m.contents[strings.TrimSuffix(key, "/content")] = string(b)
+	case r.Method == "POST" && strings.HasSuffix(key, "/commit"):
+	case r.Method == "PUT":
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		m.renamed[key] = body["key"]
+	case r.Method == "DELETE":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *mockInvenio) received() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string{}, m.requests...)
+}
+
+func TestGetNodeMap(t *testing.T) {
+	newMockInvenio(t,
+		FileEntry{Key: "data/table.csv", Checksum: "md5:0cc175b9c0f1b6a831c399e269772661", Size: 1, FileId: "f1"},
+		FileEntry{Key: "readme.txt", Checksum: "sha256:ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb", Size: 1, FileId: "f2"},
+		FileEntry{Key: "image.png", Checksum: "adler32:062c0215", Size: 1, FileId: "f3"},
+	)
+	ctx := context.Background()
+	nodes, err := GetNodeMap(ctx, "rec1", "token", "")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	expected := map[string][2]string{
+		"data/table.csv": {types.Md5, "0cc175b9c0f1b6a831c399e269772661"},
+		"readme.txt":     {types.SHA256, "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"},
+		"image.png":      {"adler32", "062c0215"},
+	}
+	if len(nodes) != len(expected) {
+		t.Fatalf("expected %v nodes, got %v", len(expected), nodes)
+	}
+	for k, e := range expected {
+		f := nodes[k].Attributes.DestinationFile
+		if f.HashType != e[0] || f.Hash != e[1] {
+			t.Errorf("%v: got %v %v, expected %v %v", k, f.HashType, f.Hash, e[0], e[1])
+		}
+		ref, err := getFileRef(ctx, f.Id)
+		if err != nil || ref != (fileRef{"rec1", k}) {
+			t.Errorf("%v: file reference not stored: %v %v", k, ref, err)
+		}
+	}
+	if n := nodes["data/table.csv"]; n.Name != "table.csv" || n.Path != "data" {
+		t.Errorf("key not split in path and name: %v %v", n.Path, n.Name)
+	}
+}
+
+func TestMapChecksum(t *testing.T) {
+	tests := []struct {
+		checksum, hashType, hash string
+	}{
+		{"md5:abc", types.Md5, "abc"},
+		{"MD5:abc", types.Md5, "abc"},
+		{"sha1:abc", types.SHA1, "abc"},
+		{"sha256:abc", types.SHA256, "abc"},
+		{"sha512:abc", types.SHA512, "abc"},
+		{"adler32:abc", "adler32", "abc"},
+		{"abc", types.Md5, "abc"},
+	}
+	for _, tt := range tests {
+		hashType, hash := mapChecksum(tt.checksum)
+		if hashType != tt.hashType || hash != tt.hash {
+			t.Errorf("%v: got %v %v, expected %v %v", tt.checksum, hashType, hash, tt.hashType, tt.hash)
+		}
+	}
+}
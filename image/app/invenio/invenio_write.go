@@ -0,0 +1,113 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package invenio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"integration/app/core"
+	"integration/app/tree"
+	"io"
+	"sync"
+	"time"
+)
+
+func CreateNewRecord(ctx context.Context, _, token, _ string) (string, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"access": map[string]string{"record": "public", "files": "public"},
+		"files":  map[string]bool{"enabled": true},
+		"metadata": map[string]string{
+			"title": "New record",
+		},
+	})
+	res := Record{}
+	err := do(ctx, "POST", "/api/records", token, bytes.NewBuffer(body), "application/json", &res)
+	if err != nil {
+		return "", err
+	}
+	if res.Id == "" {
+		return "", fmt.Errorf("creating new record failed: record id not found in the response")
+	}
+	return res.Id, nil
+}
+
+// files are written via the draft files API: initialize the file, upload the content and commit;
+// a replaced file is only deleted once the new content is committed under a temporary key, which is then renamed
+func WriteOverWire(ctx context.Context, dbId int64, id string, _ []string, _ string, token, _, persistentId string, wg *sync.WaitGroup, async_err *core.ErrorHolder) (io.WriteCloser, error) {
+	key := id
+	if dbId != 0 {
+		key = temporaryKey(id)
+	}
+	pr, pw := io.Pipe()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer pr.Close()
+		err := upload(ctx, token, persistentId, key, pr)
+		if err == nil && dbId != 0 {
+			err = replace(ctx, token, persistentId, dbId, key, id)
+		}
+		if err != nil && async_err != nil {
+			async_err.Err = fmt.Errorf("writing file in %s failed: %v", persistentId, err)
+		}
+	}()
+
+	return pw, nil
+}
+
+func temporaryKey(key string) string {
+	return fmt.Sprintf("%v.%x.replacing", key, time.Now().UnixNano())
+}
+
+func upload(ctx context.Context, token, recordId, key string, content io.Reader) error {
+	files, _ := json.Marshal([]map[string]string{{"key": key}})
+	err := do(ctx, "POST", filesPath(recordId), token, bytes.NewBuffer(files), "application/json", nil)
+	if err != nil {
+		return err
+	}
+	err = do(ctx, "PUT", filePath(recordId, key)+"/content", token, content, "application/octet-stream", nil)
+	if err != nil {
+		return err
+	}
+	storeFileRef(ctx, fileId(recordId, key), fileRef{recordId, key})
+	return do(ctx, "POST", filePath(recordId, key)+"/commit", token, nil, "", nil)
+}
+
+// deletes the replaced file and renames the committed new file from the temporary key to its key;
+// when the rename fails, the new content is kept under the temporary key
+func replace(ctx context.Context, token, recordId string, dbId int64, temporaryKey, key string) error {
+	err := DeleteFile(ctx, token, "", dbId)
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(map[string]string{"key": key})
+	err = do(ctx, "PUT", filePath(recordId, temporaryKey), token, bytes.NewBuffer(body), "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("renaming %v to %v failed: %v", temporaryKey, key, err)
+	}
+	storeFileRef(ctx, fileId(recordId, key), fileRef{recordId, key})
+	return nil
+}
+
+func SaveAfterDirectUpload(_ context.Context, _ bool, _, _, persistentId string, _ []string, _ []tree.Node) error {
+	return fmt.Errorf("direct upload is not supported for Invenio record %v", persistentId)
+}
+
+func CleanupLeftOverFiles(_ context.Context, _, _, _ string) error {
+	return nil
+}
+
+func DeleteFile(ctx context.Context, token, _ string, id int64) error {
+	ref, err := getFileRef(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = do(ctx, "DELETE", filePath(ref.RecordId, ref.Key), token, nil, "", nil)
+	if err != nil {
+		return fmt.Errorf("deleting file %v failed: %v", ref.Key, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package invenio
+
+import (
+	"context"
+	"integration/app/core"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const recordFiles = "/api/records/rec1/draft/files"
+
+func write(t *testing.T, dbId int64, key, content string) error {
+	t.Helper()
+	wg := &sync.WaitGroup{}
+	asyncErr := &core.ErrorHolder{}
+	w, err := WriteOverWire(context.Background(), dbId, key, nil, "", "token", "", "rec1", wg, asyncErr)
+	if err != nil {
+		return err
+	}
+	io.WriteString(w, content)
+	w.Close()
+	wg.Wait()
+	return asyncErr.Err
+}
+
+func TestWriteOverWireAddsFile(t *testing.T) {
+	m := newMockInvenio(t)
+	err := write(t, 0, "data.csv", "a,b\n")
+	if err != nil {
+		t.Fatalf("writing failed: %v", err)
+	}
+	expected := []string{
+		"POST " + recordFiles,
+		"PUT " + recordFiles + "/data.csv/content",
+		"POST " + recordFiles + "/data.csv/commit",
+	}
+	if got := m.received(); !slices.Equal(got, expected) {
+		t.Errorf("got requests %v, expected %v", got, expected)
+	}
+	if m.contents["data.csv"] != "a,b\n" {
+		t.Errorf("got content %q", m.contents["data.csv"])
+	}
+}
+
+func TestWriteOverWireReplacesAfterCommit(t *testing.T) {
+	m := newMockInvenio(t)
+	id := fileId("rec1", "data.csv")
+	storeFileRef(context.Background(), id, fileRef{"rec1", "data.csv"})
+	err := write(t, id, "data.csv", "c,d\n")
+	if err != nil {
+		t.Fatalf("writing failed: %v", err)
+	}
+
+	got := m.received()
+	if len(got) != 5 {
+		t.Fatalf("expected init, upload, commit, delete and rename, got %v", got)
+	}
+	temporaryKey := strings.TrimSuffix(strings.TrimPrefix(got[1], "PUT "+recordFiles+"/"), "/content")
+	if temporaryKey == "data.csv" || !strings.HasPrefix(temporaryKey, "data.csv.") {
+		t.Fatalf("the new content must be uploaded under a temporary key, got %v", got[1])
+	}
+	expected := []string{
+		"POST " + recordFiles,
+		"PUT " + recordFiles + "/" + temporaryKey + "/content",
+		"POST " + recordFiles + "/" + temporaryKey + "/commit",
+		"DELETE " + recordFiles + "/data.csv",
+		"PUT " + recordFiles + "/" + temporaryKey,
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("got requests %v, expected %v", got, expected)
+	}
+	if m.contents[temporaryKey] != "c,d\n" || m.renamed[temporaryKey] != "data.csv" {
+		t.Errorf("new content not renamed: contents %v, renamed %v", m.contents, m.renamed)
+	}
+}
+
+func TestWriteOverWireKeepsFileWhenUploadFails(t *testing.T) {
+	m := newMockInvenio(t)
+	m.failContent = true
+	id := fileId("rec1", "data.csv")
+	storeFileRef(context.Background(), id, fileRef{"rec1", "data.csv"})
+	err := write(t, id, "data.csv", "c,d\n")
+	if err == nil {
+		t.Fatal("expected the failed upload to be reported")
+	}
+	for _, r := range m.received() {
+		if strings.HasPrefix(r, "DELETE") {
+			t.Errorf("the replaced file must not be deleted when the upload fails, got %v", r)
+		}
+	}
+}
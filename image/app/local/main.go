@@ -39,6 +39,7 @@ var (
 	hashAlg     = flag.String("hash", DefaultHash, "Default hashing algorithm in Dataverse: MD5, SHA-1")
 	roleIDs     = flag.String("roleIDs", MyDataRoleIds, "My data query role IDs: comma separated ints")
 	maxFileSize = flag.String("maxFileSize", MaxFileSize, "Maximum file size in bytes for upload.")
+	workers     = flag.Int("workers", 1, "Number of parallel workers processing the jobs.")
 )
 
 func main() {
@@ -90,7 +91,11 @@ func main() {
 		}
 	}()
 
-	spinner.SpinWorkers(1, "ALL")
+	numberWorkers := *workers
+	if numberWorkers < 1 {
+		numberWorkers = 1
+	}
+	spinner.SpinWorkers(numberWorkers, "ALL")
 	ticker.Stop()
 	done <- true
 }
@@ -138,15 +143,19 @@ func (f *fakeRedis) Ping(ctx context.Context) *redis.StatusCmd {
 func (f *fakeRedis) Get(ctx context.Context, key string) *redis.StringCmd {
 	f.Lock()
 	defer f.Unlock()
-	v := f.values[key]
+	v, found := f.values[key]
 	exp, ok := f.expirations[key]
 	if ok && exp.Before(time.Now()) {
-		v = ""
+		v, found = "", false
 		delete(f.values, key)
 		delete(f.expirations, key)
 	}
 	cmd := redis.NewStringCmd(ctx)
 	cmd.SetVal(v)
+	if !found {
+		// same as the real redis client: a missing key is reported with redis.Nil
+		cmd.SetErr(redis.Nil)
+	}
 	return cmd
 }
 
@@ -193,6 +202,7 @@ func (f *fakeRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
 	for _, key := range keys {
 		delete(f.values, key)
 		delete(f.expirations, key)
+		delete(f.valueSlices, key)
 	}
 	cmd := redis.NewIntCmd(ctx)
 	cmd.SetVal(int64(len(keys)))
@@ -208,7 +218,7 @@ func (f *fakeRedis) LPush(ctx context.Context, key string, values ...interface{}
 	}
 	f.valueSlices[key] = append(newValues, f.valueSlices[key]...)
 	cmd := redis.NewIntCmd(ctx)
-	cmd.SetVal(int64(len(key)))
+	cmd.SetVal(int64(len(f.valueSlices[key])))
 	return cmd
 }
 
@@ -228,6 +238,91 @@ func (f *fakeRedis) RPop(ctx context.Context, key string) *redis.StringCmd {
 	return cmd
 }
 
+func (f *fakeRedis) LLen(ctx context.Context, key string) *redis.IntCmd {
+	f.Lock()
+	defer f.Unlock()
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetVal(int64(len(f.valueSlices[key])))
+	return cmd
+}
+
+func (f *fakeRedis) LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	f.Lock()
+	defer f.Unlock()
+	values := f.valueSlices[key]
+	l := int64(len(values))
+	if start < 0 {
+		start = max(l+start, 0)
+	}
+	if stop < 0 {
+		stop = l + stop
+	}
+	stop = min(stop, l-1)
+	cmd := redis.NewStringSliceCmd(ctx)
+	if start > stop {
+		cmd.SetVal([]string{})
+		return cmd
+	}
+	cmd.SetVal(append([]string{}, values[start:stop+1]...))
+	return cmd
+}
+
+func (f *fakeRedis) LRem(ctx context.Context, key string, count int64, value interface{}) *redis.IntCmd {
+	f.Lock()
+	defer f.Unlock()
+	v := fmt.Sprintf("%v", value)
+	kept := []string{}
+	removed := int64(0)
+	for _, s := range f.valueSlices[key] {
+		if s == v && (count == 0 || removed < count) {
+			removed++
+			continue
+		}
+		kept = append(kept, s)
+	}
+	f.valueSlices[key] = kept
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetVal(removed)
+	return cmd
+}
+
+func (f *fakeRedis) LTrim(ctx context.Context, key string, start, stop int64) *redis.StatusCmd {
+	f.Lock()
+	defer f.Unlock()
+	values := f.valueSlices[key]
+	l := int64(len(values))
+	if start < 0 {
+		start = max(l+start, 0)
+	}
+	if stop < 0 {
+		stop = l + stop
+	}
+	stop = min(stop, l-1)
+	if start > stop {
+		delete(f.valueSlices, key)
+	} else {
+		f.valueSlices[key] = append([]string{}, values[start:stop+1]...)
+	}
+	cmd := redis.NewStatusCmd(ctx)
+	cmd.SetVal("OK")
+	return cmd
+}
+
+// the expiration applies to values and lists alike
+func (f *fakeRedis) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	f.Lock()
+	defer f.Unlock()
+	cmd := redis.NewBoolCmd(ctx)
+	_, isValue := f.values[key]
+	_, isList := f.valueSlices[key]
+	if !isValue && !isList {
+		return cmd
+	}
+	f.expirations[key] = time.Now().Add(expiration)
+	cmd.SetVal(true)
+	return cmd
+}
+
 func (f *fakeRedis) cleanupExpired() {
 	f.Lock()
 	defer f.Unlock()
@@ -235,6 +330,7 @@ func (f *fakeRedis) cleanupExpired() {
 		if v.Before(time.Now()) {
 			delete(f.expirations, k)
 			delete(f.values, k)
+			delete(f.valueSlices, k)
 		}
 	}
 }
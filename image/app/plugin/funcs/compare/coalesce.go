@@ -0,0 +1,99 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package compare
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"integration/app/common"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"time"
+)
+
+// scope of the coalescing of identical compares (coalesceCompares in the backend config): only the compares of the same user,
+// e.g., the same compare started in several browser tabs, as the source is queried with the credentials of the first compare
+const coalesceUser = "user"
+
+const coalescePollInterval = time.Second
+const coalesceMaxDuration = 2 * time.Hour // the maximum duration of a compare, see doCompare
+
+type compareFingerprint struct {
+	Plugin       string `json:"plugin"`
+	PluginId     string `json:"pluginId"`
+	Url          string `json:"url"`
+	RepoName     string `json:"repoName"`
+	Option       string `json:"option"`
+	PersistentId string `json:"persistentId"`
+	Destination  string `json:"destination"`
+	Directory    string `json:"directory"`
+	FullListing  bool   `json:"fullListing"`
+	Include      string `json:"include"`
+	Exclude      string `json:"exclude"`
+	User         string `json:"user"`
+}
+
+// returns the key identifying identical compares, false when the compare is not coalesced;
+// the compares copying the metadata to a newly created dataset are never coalesced
+func fingerprint(req types.CompareRequest, user string) (string, bool) {
+	scope := config.GetConfig().Options.CoalesceCompares
+	if scope != coalesceUser || req.NewlyCreated {
+		return "", false
+	}
+	f := compareFingerprint{
+		Plugin:       req.Plugin,
+		PluginId:     req.PluginId,
+		Url:          req.Url,
+		RepoName:     req.RepoName,
+		Option:       req.Option,
+		PersistentId: req.PersistentId,
+		Destination:  req.Destination,
+		Directory:    req.Directory,
+		FullListing:  req.FullListing,
+		Include:      req.Include,
+		Exclude:      req.Exclude,
+		User:         user,
+	}
+	b, _ := json.Marshal(f)
+	return fmt.Sprintf("compare in flight: %x", sha256.Sum256(b)), true
+}
+
+// registers the compare as running under the fingerprint, or returns the key of the identical compare that is already running
+func joinCompare(ctx context.Context, fingerprint, key string) (leader string, isLeader bool) {
+	if config.GetRedis().SetNX(ctx, fingerprint, key, coalesceMaxDuration).Val() {
+		return key, true
+	}
+	return config.GetRedis().Get(ctx, fingerprint).Val(), false
+}
+
+// called once the result of the compare is cached, the next identical compare starts anew
+func leaveCompare(fingerprint string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	config.GetRedis().Del(ctx, fingerprint)
+}
+
+// waits for the result of the running identical compare; false when that compare ended without result (e.g., the process was stopped)
+func awaitCompare(ctx context.Context, leader, fingerprint string) (common.CachedResponse, bool) {
+	res := common.CachedResponse{}
+	if leader == "" {
+		return res, false
+	}
+	for {
+		running := config.GetRedis().Get(ctx, fingerprint).Val() == leader
+		cached, ok, err := config.GetFromCache(ctx, leader)
+		if err == nil && ok && json.Unmarshal([]byte(cached), &res) == nil {
+			return res, true
+		}
+		if !running {
+			return res, false
+		}
+		select {
+		case <-ctx.Done():
+			return res, false
+		case <-time.After(coalescePollInterval):
+		}
+	}
+}
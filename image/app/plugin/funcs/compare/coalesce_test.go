@@ -0,0 +1,98 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"integration/app/common"
+	"integration/app/config"
+	"integration/app/core"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// replaces the cache and the customizations of the backend config for the duration of the test
+func useCache(t *testing.T, options config.OptionalConfig) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previousRedis := config.GetRedis()
+	config.SetRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	previousOptions := config.GetConfig().Options
+	config.SetOptions(options)
+	t.Cleanup(func() {
+		config.SetRedis(previousRedis)
+		config.SetOptions(previousOptions)
+	})
+	return mr
+}
+
+func TestFingerprint(t *testing.T) {
+	useCache(t, config.OptionalConfig{CoalesceCompares: coalesceUser})
+	req := types.CompareRequest{Plugin: "github", PluginId: "github", RepoName: "libis/rdm-integration", Option: "main", PersistentId: "doi:10.5072/FK2/ABC"}
+	fp, ok := fingerprint(req, "user")
+	if !ok {
+		t.Fatal("compare not coalesced")
+	}
+	if same, _ := fingerprint(req, "user"); same != fp {
+		t.Errorf("identical compares of the same user must have the same fingerprint")
+	}
+	if other, _ := fingerprint(req, "other user"); other == fp {
+		t.Errorf("the compares of other users must never be coalesced")
+	}
+	otherOption := req
+	otherOption.Option = "develop"
+	if other, _ := fingerprint(otherOption, "user"); other == fp {
+		t.Errorf("compares of another branch must not be coalesced")
+	}
+	newlyCreated := req
+	newlyCreated.NewlyCreated = true
+	if _, ok := fingerprint(newlyCreated, "user"); ok {
+		t.Errorf("compares of newly created datasets must not be coalesced")
+	}
+}
+
+func TestIdenticalCompareReusesInFlightResult(t *testing.T) {
+	useCache(t, config.OptionalConfig{CoalesceCompares: coalesceUser})
+	core.RegisterDestination("mock coalesce", core.DestinationPlugin{
+		CheckPermission: func(ctx context.Context, token, user, persistentId string) error { return nil },
+		Query: func(ctx context.Context, persistentId, token, user string) (map[string]tree.Node, error) {
+			t.Error("the destination must not be queried again by an identical compare")
+			return nil, nil
+		},
+	})
+	req := types.CompareRequest{Plugin: "github", PluginId: "github", RepoName: "libis/rdm-integration", PersistentId: "doi:10.5072/FK2/ABC", Destination: "mock coalesce"}
+	fp, _ := fingerprint(req, "user")
+	ctx := context.Background()
+	if _, isLeader := joinCompare(ctx, fp, "leader"); !isLeader {
+		t.Fatal("the first compare must lead")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		doCompare(req, "follower", "user")
+	}()
+	time.Sleep(100 * time.Millisecond) // the follower waits for the running compare
+	common.CacheResponse(common.CachedResponse{Key: "leader", Ready: true, Response: core.CompareResponse{Id: req.PersistentId, Status: core.Finished}})
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the identical compare did not reuse the result of the running compare")
+	}
+
+	cached, ok, err := config.GetFromCache(ctx, "follower")
+	if err != nil || !ok {
+		t.Fatalf("result of the identical compare not cached: %v", err)
+	}
+	res := common.CachedResponse{}
+	json.Unmarshal([]byte(cached), &res)
+	if res.Key != "follower" || !res.Ready || res.Response.Id != req.PersistentId || res.ErrorMessage != "" {
+		t.Errorf("got %+v, expected the result of the running compare under the key of the identical compare", res)
+	}
+}
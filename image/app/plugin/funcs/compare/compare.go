@@ -5,29 +5,82 @@ package compare
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"integration/app/common"
 	"integration/app/config"
 	"integration/app/core"
+	"integration/app/logging"
 	"integration/app/plugin"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"io"
 	"net/http"
-	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-var fileNameR, _ = regexp.Compile(`^[^:<>;#"\/\*\|\?\\]*$`)
-var folderNameR, _ = regexp.Compile(`^[a-zA-Z0-9_\.\/\- \\]*$`)
+// policies for zero-byte files at the source, these sometimes indicate failed uploads
+const (
+	zeroByteCopy = "copy"
+	zeroByteSkip = "skip"
+	zeroByteFail = "fail"
+)
+
+func zeroByteFilesPolicy() string {
+	switch p := config.GetConfig().Options.ZeroByteFiles; p {
+	case zeroByteSkip, zeroByteFail:
+		return p
+	}
+	return zeroByteCopy
+}
+
+// policies for files listed by the source without hash, these can not be compared with the files in the dataset;
+// by default they are kept as listed, since some sources (e.g., OSF addon providers, WebDAV) do not always return a hash
+const (
+	emptyHashKeep  = "keep"
+	emptyHashError = "error"
+	emptyHashSize  = "size"
+	emptyHashSkip  = "skip"
+)
+
+func emptyHashPolicy(pluginName string) string {
+	switch p := config.GetConfig().Options.EmptyHashPolicy[pluginName]; p {
+	case emptyHashError, emptyHashSize, emptyHashSkip:
+		return p
+	}
+	return emptyHashKeep
+}
+
+// messages shown to the user when the source rejects the credentials, the raw error is only logged
+var authErrorMessages = map[string]string{
+	"github":   "GitHub token is invalid, expired or lacks the repo scope: please log in again or create a new token with the repo scope",
+	"gitlab":   "GitLab token is invalid, expired or lacks the read_api and read_repository scopes: please log in again or create a new token",
+	"osf":      "OSF token is invalid or expired, or you do not have access to this project: please log in again",
+	"onedrive": "OneDrive or SharePoint session expired or access was denied: please log in again",
+	"redcap":   "REDCap API token is invalid or lacks the export permission for this project",
+	"globus":   "Globus session expired or the endpoint refused access: please log in again",
+	"sftp":     "SFTP login failed: please check your username and password",
+}
+
+func queryErrorMessage(pluginName string, err error) string {
+	if errors.Is(err, types.ErrRateLimited) || !errors.Is(err, types.ErrUnauthorized) {
+		return err.Error()
+	}
+	logging.Logger.Printf("%v: query failed: %v\n", pluginName, err)
+	if msg, ok := authErrorMessages[pluginName]; ok {
+		return msg
+	}
+	return "the credentials are invalid or expired, or lack the permissions needed to access the selected source: please log in again"
+}
 
 func Compare(w http.ResponseWriter, r *http.Request) {
 	if !config.RedisReady(r.Context()) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - cache not ready"))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeCacheNotReady, "cache not ready")
 		return
 	}
 	user := core.GetUserFromHeader(r.Header)
@@ -36,14 +89,12 @@ func Compare(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeBadRequest, "bad request")
 		return
 	}
 	err = json.Unmarshal(b, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeBadRequest, "bad request")
 		return
 	}
 	key := uuid.New().String()
@@ -51,64 +102,191 @@ func Compare(w http.ResponseWriter, r *http.Request) {
 	res := common.Key{Key: key}
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
 }
 
+// the query of the repository does not receive the nodes of the destination: only for plugins with an independent query;
+// when one of the queries fails, the other is cancelled and only the error that caused the cancellation is reported
+func queryInParallel(ctx context.Context, destination core.DestinationPlugin, p plugin.Plugin, req types.CompareRequest, user string) (nm, repoNm map[string]tree.Node, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var repoErr error
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		repoNm, repoErr = p.Query(ctx, req, map[string]tree.Node{})
+		if repoErr != nil {
+			cancel()
+		}
+	}()
+	nm, err = destination.Query(ctx, req.PersistentId, req.DataverseKey, user)
+	if err != nil {
+		cancel()
+	}
+	wg.Wait()
+	if repoErr != nil && (err == nil || errors.Is(err, context.Canceled)) {
+		return nil, nil, repoErr
+	}
+	if err != nil && errors.Is(repoErr, context.Canceled) {
+		return nil, nil, err
+	}
+	return nm, repoNm, errors.Join(err, repoErr)
+}
+
+// the compare only reads from the destination through the destination plugin (guarded by core.ReadOnly): it never changes the dataset,
+// nor creates a draft version of a published dataset; copyMetaData, called for a newly created dataset, writes with its own request instead
 func doCompare(req types.CompareRequest, key, user string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
 	defer cancel()
+	ctx = core.ReadOnly(ctx)
 	cachedRes := common.CachedResponse{
 		Key: key,
 	}
-	//check permission
-	err := core.Destination.CheckPermission(ctx, req.DataverseKey, user, req.PersistentId)
+	destination, err := core.GetDestination(req.Destination)
 	if err != nil {
 		cachedRes.ErrorMessage = err.Error()
 		common.CacheResponse(cachedRes)
 		return
 	}
 
-	//query dataverse
-	nm, err := core.Destination.Query(ctx, req.PersistentId, req.DataverseKey, user)
+	//check permission
+	err = destination.CheckPermission(ctx, req.DataverseKey, user, req.PersistentId)
 	if err != nil {
+		core.ForgetDeletedDataset(ctx, req.PersistentId, err)
 		cachedRes.ErrorMessage = err.Error()
 		common.CacheResponse(cachedRes)
 		return
 	}
 
-	//query repository
-	nmCopy := map[string]tree.Node{}
-	for k, v := range nm {
-		nmCopy[k] = v
+	//reuse the result of an identical compare that is already running (see coalesceCompares in the backend config)
+	if fp, ok := fingerprint(req, user); ok {
+		leader, isLeader := joinCompare(ctx, fp, key)
+		if isLeader {
+			defer leaveCompare(fp)
+		} else if res, ok := awaitCompare(ctx, leader, fp); ok {
+			core.ShareListing(ctx, leader, key)
+			core.RecordCompare(ctx, key, req.Destination, req.PersistentId)
+			res.Key = key
+			common.CacheResponse(res)
+			return
+		}
 	}
+
+	p := plugin.GetPlugin(req.Plugin)
 	req.Token = core.GetTokenFromCache(ctx, req.Token, req.Token, req.PluginId)
-	repoNm, err := plugin.GetPlugin(req.Plugin).Query(ctx, req, nmCopy)
+	parallel := config.GetConfig().Options.ParallelCompareQueries && p.IndependentQuery
+
+	//query dataverse (and the repository, when both queries run in parallel)
+	var nm, repoNm map[string]tree.Node
+	if parallel {
+		nm, repoNm, err = queryInParallel(ctx, destination, p, req, user)
+	} else {
+		nm, err = destination.Query(ctx, req.PersistentId, req.DataverseKey, user)
+	}
 	if err != nil {
-		cachedRes.ErrorMessage = err.Error()
+		core.ForgetDeletedDataset(ctx, req.PersistentId, err)
+		cachedRes.ErrorMessage = queryErrorMessage(req.Plugin, err)
+		common.CacheResponse(cachedRes)
+		return
+	}
+	core.CacheListing(ctx, key, req.Destination, req.PersistentId, nm)
+	core.RecordCompare(ctx, key, req.Destination, req.PersistentId)
+	hashType, mixedFixity := core.PreferredHashType(req.PersistentId, nm)
+	if config.GetConfig().Options.DetectDatasetHash {
+		// otherwise the sources calculating the hashes themselves keep using MD5
+		req.HashType = hashType
+	}
+	confirmationToken := core.ConfirmationToken(req.PersistentId, nm)
+	nm = core.FilterByDirectory(nm, req.Directory)
+
+	//query repository
+	if !parallel {
+		nmCopy := map[string]tree.Node{}
+		for k, v := range nm {
+			nmCopy[k] = v
+		}
+		repoNm, err = p.Query(ctx, req, nmCopy)
+		if err != nil {
+			cachedRes.ErrorMessage = queryErrorMessage(req.Plugin, err)
+			common.CacheResponse(cachedRes)
+			return
+		}
+	}
+	repoNm = core.FlattenDirectories(core.FilterByDirectory(repoNm, req.Directory))
+	if maxCount := config.GetConfig().Options.MaxFileCount[req.Plugin]; maxCount > 0 && len(repoNm) > maxCount {
+		cachedRes.ErrorMessage = fmt.Sprintf("the selection contains %d files, more than the maximum of %d files for %s: please narrow the selection, e.g., select a subfolder or a directory to compare", len(repoNm), maxCount, req.Plugin)
 		common.CacheResponse(cachedRes)
 		return
 	}
 	rejected := []string{}
+	rejectedByName := false // the name rules are only returned when they explain a rejection, not for files rejected by their size only
+	embargoed := []string{}
 	maxFileSize := config.GetMaxFileSize()
+	// the sizes are only known after the query when the plugin does not need to probe them
+	policy := zeroByteFilesPolicy()
+	zeroByte := policy != zeroByteCopy && p.FileSize == nil
+	zeroByteFiles := []string{}
+	hashPolicy := emptyHashPolicy(req.Plugin)
+	emptyHashFiles := []string{}
 	for k, v := range repoNm {
-		if maxFileSize > 0 && v.Attributes.RemoteFileSize > maxFileSize {
+		if hashPolicy != emptyHashKeep && v.Attributes.IsFile && v.Attributes.RemoteHash == "" {
+			emptyHashFiles = append(emptyHashFiles, v.Id)
+			if hashPolicy == emptyHashSkip {
+				delete(repoNm, k)
+				continue
+			}
+			if hashPolicy == emptyHashSize {
+				v.Attributes.RemoteHashType = types.FileSize
+				v.Attributes.RemoteHash = core.FileSizeHashValue(v.Attributes.RemoteFileSize)
+				repoNm[k] = v
+			}
+		}
+		if v.Attributes.EmbargoActive {
+			delete(repoNm, k)
+			embargoed = append(embargoed, v.Id)
+		} else if maxFileSize > 0 && v.Attributes.RemoteFileSize > maxFileSize {
 			delete(repoNm, k)
 			rejected = append(rejected, v.Id)
-		} else if !fileNameR.MatchString(v.Name) || !folderNameR.MatchString(v.Path) {
+		} else if !config.ValidName(v.Name, v.Path) {
 			delete(repoNm, k)
 			rejected = append(rejected, v.Id)
+			rejectedByName = true
 		} else if len(strings.TrimSpace(v.Name)) == 0 {
 			delete(repoNm, k)
+		} else if zeroByte && v.Attributes.RemoteFileSize == 0 {
+			zeroByteFiles = append(zeroByteFiles, v.Id)
+			if policy == zeroByteSkip {
+				delete(repoNm, k)
+			}
 		}
 	}
+	sort.Strings(embargoed)
+	sort.Strings(emptyHashFiles)
+	if hashPolicy == emptyHashError && len(emptyHashFiles) > 0 {
+		cachedRes.ErrorMessage = fmt.Sprintf("the source returned files without hash: %v", strings.Join(emptyHashFiles, ", "))
+		common.CacheResponse(cachedRes)
+		return
+	}
+	sort.Strings(zeroByteFiles)
+	if policy == zeroByteFail && len(zeroByteFiles) > 0 {
+		cachedRes.ErrorMessage = fmt.Sprintf("zero-byte files found at the source (possibly failed uploads): %v", strings.Join(zeroByteFiles, ", "))
+		common.CacheResponse(cachedRes)
+		return
+	}
+	repoNm, tooLong := core.LongPaths(repoNm)
+	collisions := core.DestinationCollisions(nm, repoNm)
+	for _, k := range collisions {
+		delete(repoNm, k)
+	}
+	repoNm, caseCollisions := core.CaseCollisions(nm, repoNm)
 	nm = core.MergeNodeMaps(nm, repoNm)
 
 	//compare and write response
-	res := core.Compare(ctx, nm, req.PersistentId, req.DataverseKey, user, true)
+	res := core.OmitEqualNodes(core.Compare(ctx, nm, req.Destination, req.PersistentId, req.DataverseKey, user, true), req.FullListing)
 
 	//copy metadata if the source is a Dataverse installation and destination is a newly created dataset
 	if req.Plugin == "dataverse" && req.NewlyCreated {
@@ -122,6 +300,22 @@ func doCompare(req types.CompareRequest, key, user string) {
 
 	cachedRes.Response = res
 	cachedRes.Response.MaxFileSize = maxFileSize
+	cachedRes.Response.MaxJobTotalBytes = config.GetConfig().Options.MaxJobTotalBytes
+	cachedRes.Response.ConfirmationToken = confirmationToken
 	cachedRes.Response.Rejected = rejected
+	if rejectedByName {
+		cachedRes.Response.NameRules = config.NameRules()
+	}
+	cachedRes.Response.Embargoed = embargoed
+	cachedRes.Response.Collisions = collisions
+	cachedRes.Response.CaseCollisions = caseCollisions
+	cachedRes.Response.TooLong = tooLong
+	cachedRes.Response.MixedFixity = mixedFixity
+	if policy == zeroByteSkip {
+		cachedRes.Response.SkippedZeroByte = zeroByteFiles
+	}
+	if hashPolicy == emptyHashSkip {
+		cachedRes.Response.SkippedEmptyHash = emptyHashFiles
+	}
 	common.CacheResponse(cachedRes)
 }
@@ -0,0 +1,107 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package compare
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"integration/app/common"
+	"integration/app/config"
+	"integration/app/core"
+	"integration/app/logging"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// a destination of which the functions that can change the dataset fail the test
+func readOnlyDestination(t *testing.T, listing map[string]tree.Node) core.DestinationPlugin {
+	written := func(operation string) error {
+		t.Errorf("%v called during compare", operation)
+		return fmt.Errorf("%v called", operation)
+	}
+	return core.DestinationPlugin{
+		IsDirectUpload:  func() bool { return false },
+		CheckPermission: func(ctx context.Context, token, user, persistentId string) error { return nil },
+		GetRepoUrl:      func(pid string, draft bool) string { return "https://repo.example.org/" + pid },
+		Query: func(ctx context.Context, persistentId, token, user string) (map[string]tree.Node, error) {
+			return listing, nil
+		},
+		CreateNewRepo: func(ctx context.Context, collection, token, userName string) (string, error) {
+			return "", written("CreateNewRepo")
+		},
+		WriteOverWire: func(ctx context.Context, dbId int64, nodeMapId string, categories []string, description string, token, user, persistentId string, wg *sync.WaitGroup, async_err *core.ErrorHolder) (io.WriteCloser, error) {
+			return nil, written("WriteOverWire")
+		},
+		SaveAfterDirectUpload: func(ctx context.Context, replace bool, token, user, persistentId string, storageIdentifiers []string, nodes []tree.Node) error {
+			return written("SaveAfterDirectUpload")
+		},
+		CleanupLeftOverFiles: func(ctx context.Context, persistentId, token, user string) error {
+			return written("CleanupLeftOverFiles")
+		},
+		DeleteFile: func(ctx context.Context, token, user string, id int64) error {
+			return written("DeleteFile")
+		},
+		UploadToPresignedUrl: func(ctx context.Context, token, user, persistentId string, reader io.Reader, size int64) (string, error) {
+			return "", written("UploadToPresignedUrl")
+		},
+		SetThumbnail: func(ctx context.Context, token, user, persistentId string, fileId int64) error {
+			return written("SetThumbnail")
+		},
+		UpdateFileMetadata: func(ctx context.Context, token, user, persistentId string, node tree.Node) error {
+			return written("UpdateFileMetadata")
+		},
+	}
+}
+
+func TestCompareDoesNotWrite(t *testing.T) {
+	useCache(t, config.OptionalConfig{})
+	var logged bytes.Buffer
+	logging.Logger.SetOutput(&logged)
+	t.Cleanup(func() { logging.Logger.SetOutput(os.Stderr) })
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "equal.txt"), []byte("equal"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644)
+	listing := map[string]tree.Node{
+		"equal.txt": {Id: "equal.txt", Name: "equal.txt", Attributes: tree.Attributes{IsFile: true, DestinationFile: tree.DestinationFile{
+			Id: 1, FileSize: 5, Hash: fmt.Sprintf("%x", md5.Sum([]byte("equal"))), HashType: types.Md5,
+		}}},
+		"deleted.txt": {Id: "deleted.txt", Name: "deleted.txt", Attributes: tree.Attributes{IsFile: true, DestinationFile: tree.DestinationFile{
+			Id: 2, FileSize: 7, Hash: fmt.Sprintf("%x", md5.Sum([]byte("deleted"))), HashType: types.Md5,
+		}}},
+	}
+	core.RegisterDestination("mock read-only", readOnlyDestination(t, listing))
+
+	req := types.CompareRequest{Plugin: "local", PluginId: "local", Url: dir, PersistentId: "doi:10.5072/FK2/RO", Destination: "mock read-only"}
+	doCompare(req, "compare key", "user")
+
+	if strings.Contains(logged.String(), "read-only operation") {
+		t.Errorf("a write was attempted during compare: %v", logged.String())
+	}
+	cached, ok, err := config.GetFromCache(context.Background(), "compare key")
+	if err != nil || !ok {
+		t.Fatalf("compare result not cached: %v", err)
+	}
+	res := common.CachedResponse{}
+	json.Unmarshal([]byte(cached), &res)
+	if res.ErrorMessage != "" {
+		t.Fatalf("compare failed: %v", res.ErrorMessage)
+	}
+	statuses := map[string]int{}
+	for _, n := range res.Response.Data {
+		statuses[n.Id] = n.Status
+	}
+	expected := map[string]int{"equal.txt": tree.Equal, "new.txt": tree.New, "deleted.txt": tree.Deleted}
+	if fmt.Sprint(statuses) != fmt.Sprint(expected) {
+		t.Errorf("got statuses %v, expected %v", statuses, expected)
+	}
+}
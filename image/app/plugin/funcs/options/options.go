@@ -4,7 +4,7 @@ package options
 
 import (
 	"encoding/json"
-	"fmt"
+	"integration/app/common"
 	"integration/app/core"
 	"integration/app/plugin"
 	"integration/app/plugin/types"
@@ -17,16 +17,14 @@ func Options(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeBadRequest, "bad request")
 		return
 	}
 
 	params := types.OptionsRequest{}
 	err = json.Unmarshal(b, &params)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("500 - bad request"))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeBadRequest, "bad request")
 		return
 	}
 
@@ -36,15 +34,13 @@ func Options(w http.ResponseWriter, r *http.Request) {
 	}
 	res, err := plugin.GetPlugin(params.Plugin).Options(r.Context(), params)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeInternal, err.Error())
 		return
 	}
 
 	b, err = json.Marshal(res)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("500 - %v", err)))
+		common.WriteError(w, http.StatusInternalServerError, common.ErrCodeInternal, err.Error())
 		return
 	}
 	w.Write(b)
@@ -12,3 +12,23 @@ import (
 func Streams(ctx context.Context, nodeMap map[string]tree.Node, pluginName string, streamParams types.StreamParams) (types.StreamsType, error) {
 	return plugin.GetPlugin(pluginName).Streams(ctx, nodeMap, streamParams)
 }
+
+// sets the remote file sizes that are not known after the query, only for the plugins supporting the size probe
+func ProbeFileSizes(ctx context.Context, nodeMap map[string]tree.Node, pluginName string, streamParams types.StreamParams) error {
+	probe := plugin.GetPlugin(pluginName).FileSize
+	if probe == nil {
+		return nil
+	}
+	for k, v := range nodeMap {
+		if v.Action == tree.Delete || v.Attributes.RemoteFileSize > 0 {
+			continue
+		}
+		size, err := probe(ctx, v, streamParams)
+		if err != nil {
+			return err
+		}
+		v.Attributes.RemoteFileSize = size
+		nodeMap[k] = v
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"integration/app/plugin/types"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultApiUrl = "https://api.bitbucket.org/2.0"
+
+// Bitbucket 2.0 API responses are paged: the next page is fetched from the URL in the "next" field (cursor-based)
+type page[T any] struct {
+	Values []T    `json:"values"`
+	Next   string `json:"next"`
+}
+
+type client struct {
+	api   string
+	repo  string // workspace/repo_slug
+	user  string
+	token string
+}
+
+// the token is an app password when the user name is given (basic authentication), an OAuth access token otherwise;
+// the url is the Bitbucket Cloud website or API url, the public API is used when not set
+func getClient(base, repo, user, token string) (*client, error) {
+	splitted := strings.Split(repo, "/")
+	if len(splitted) != 2 || splitted[0] == "" || splitted[1] == "" || token == "" {
+		return nil, fmt.Errorf("missing parameters: expected repository (workspace/repository) and token")
+	}
+	return &client{api: apiUrl(base), repo: url.PathEscape(splitted[0]) + "/" + url.PathEscape(splitted[1]), user: user, token: token}, nil
+}
+
+func apiUrl(base string) string {
+	base = strings.TrimSuffix(base, "/")
+	if base == "" || base == "https://bitbucket.org" || base == "https://www.bitbucket.org" {
+		return defaultApiUrl
+	}
+	return base
+}
+
+func (cl *client) repoUrl(path string) string {
+	return cl.api + "/repositories/" + cl.repo + path
+}
+
+// the url of a file or folder in the source tree at the given commit
+func (cl *client) srcUrl(commit, path string) string {
+	return cl.repoUrl("/src/" + url.PathEscape(commit) + "/" + escapePath(path))
+}
+
+// escapes the segments of a path (or a branch name containing slashes)
+func escapePath(path string) string {
+	escaped := []string{}
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			escaped = append(escaped, url.PathEscape(s))
+		}
+	}
+	return strings.Join(escaped, "/")
+}
+
+func (cl *client) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cl.user != "" {
+		request.SetBasicAuth(cl.user, cl.token)
+	} else {
+		request.Header.Add("Authorization", "Bearer "+cl.token)
+	}
+	return request, nil
+}
+
+func (cl *client) get(ctx context.Context, url string, res any) error {
+	request, err := cl.newRequest(ctx, url)
+	if err != nil {
+		return err
+	}
+	r, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	b, err := types.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if err := types.UnauthorizedError(r.StatusCode, b); err != nil {
+		return err
+	}
+	if r.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %d - %s", types.ErrRateLimited, r.StatusCode, string(b))
+	}
+	if r.StatusCode != 200 {
+		return fmt.Errorf("request to %v failed: %d - %s", url, r.StatusCode, string(b))
+	}
+	return json.Unmarshal(b, res)
+}
+
+// follows the "next" links until the last page
+func getAll[T any](ctx context.Context, cl *client, url string) ([]T, error) {
+	res := []T{}
+	for url != "" {
+		p := page[T]{}
+		if err := cl.get(ctx, url, &p); err != nil {
+			return nil, err
+		}
+		res = append(res, p.Values...)
+		url = p.Next
+	}
+	return res, nil
+}
@@ -0,0 +1,54 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"integration/app/plugin/types"
+	"sort"
+)
+
+type Branch struct {
+	Name   string `json:"name"`
+	Target Commit `json:"target"`
+}
+
+type Commit struct {
+	Hash string `json:"hash"`
+	Date string `json:"date"`
+}
+
+type Repository struct {
+	FullName   string `json:"full_name"`
+	MainBranch struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+}
+
+func Options(ctx context.Context, params types.OptionsRequest) ([]types.SelectItem, error) {
+	cl, err := getClient(params.Url, params.RepoName, params.User, params.Token)
+	if err != nil {
+		return nil, fmt.Errorf("branches: %v", err)
+	}
+	branches, err := getAll[Branch](ctx, cl, cl.repoUrl("/refs/branches?pagelen=100"))
+	if err != nil {
+		return nil, fmt.Errorf("getting branches failed: %w", err)
+	}
+	repo := Repository{}
+	if err := cl.get(ctx, cl.repoUrl(""), &repo); err != nil {
+		return nil, fmt.Errorf("getting repository failed: %w", err)
+	}
+	// the main branch first, then the most recently updated branches
+	sort.SliceStable(branches, func(i, j int) bool {
+		if (branches[i].Name == repo.MainBranch.Name) != (branches[j].Name == repo.MainBranch.Name) {
+			return branches[i].Name == repo.MainBranch.Name
+		}
+		return branches[i].Target.Date > branches[j].Target.Date
+	})
+	res := []types.SelectItem{}
+	for _, v := range branches {
+		res = append(res, types.SelectItem{Label: v.Name, Value: v.Name})
+	}
+	return res, nil
+}
@@ -0,0 +1,101 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"strings"
+)
+
+type SrcEntry struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"` // "commit_file" or "commit_directory"
+	Size   int64  `json:"size"`
+	Commit Commit `json:"commit"`
+}
+
+func Query(ctx context.Context, req types.CompareRequest, _ map[string]tree.Node) (map[string]tree.Node, error) {
+	cl, err := getClient(req.Url, req.RepoName, req.User, req.Token)
+	if err != nil {
+		return nil, fmt.Errorf("query: %v", err)
+	}
+	name := req.Option
+	if name == "" {
+		repo := Repository{}
+		if err := cl.get(ctx, cl.repoUrl(""), &repo); err != nil {
+			return nil, fmt.Errorf("getting repository failed: %w", err)
+		}
+		name = repo.MainBranch.Name
+	}
+	// the branch is resolved first, such that all folders are listed (and the files streamed) at the same commit
+	branch := Branch{}
+	if err := cl.get(ctx, cl.repoUrl("/refs/branches/"+escapePath(name)), &branch); err != nil {
+		return nil, fmt.Errorf("getting branch %v failed: %w", name, err)
+	}
+	commit := branch.Target.Hash
+	entries, err := listTree(ctx, cl, commit)
+	if err != nil {
+		return nil, err
+	}
+	sourceVersion := ""
+	if config.GetConfig().Options.RecordSourceVersion {
+		sourceVersion = commit
+	}
+	return toNodeMap(entries, sourceVersion), nil
+}
+
+// the src endpoint lists one folder at a time, the folders are walked breadth first
+func listTree(ctx context.Context, cl *client, commit string) ([]SrcEntry, error) {
+	res := []SrcEntry{}
+	folders := []string{""}
+	for len(folders) > 0 {
+		folder := folders[0]
+		folders = folders[1:]
+		entries, err := getAll[SrcEntry](ctx, cl, strings.TrimSuffix(cl.srcUrl(commit, folder), "/")+"/?pagelen=100")
+		if err != nil {
+			return nil, fmt.Errorf("listing folder %q failed: %w", folder, err)
+		}
+		for _, e := range entries {
+			switch e.Type {
+			case "commit_directory":
+				folders = append(folders, e.Path)
+			case "commit_file":
+				res = append(res, e)
+			}
+		}
+	}
+	return res, nil
+}
+
+// the src endpoint does not return the blob hashes: the commit hash is used as remote hash, a soft hash that is kept
+// after writing the file and changes with every new commit on the branch
+func toNodeMap(entries []SrcEntry, sourceVersion string) map[string]tree.Node {
+	res := map[string]tree.Node{}
+	for _, e := range entries {
+		id := strings.TrimPrefix(e.Path, "/")
+		parentId := ""
+		ancestors := strings.Split(id, "/")
+		fileName := id
+		if len(ancestors) > 1 {
+			parentId = strings.Join(ancestors[:len(ancestors)-1], "/")
+			fileName = ancestors[len(ancestors)-1]
+		}
+		res[id] = tree.Node{
+			Id:   id,
+			Name: fileName,
+			Path: parentId,
+			Attributes: tree.Attributes{
+				IsFile:         true,
+				RemoteHash:     e.Commit.Hash,
+				RemoteHashType: types.GitCommit,
+				RemoteFileSize: e.Size,
+				SourceVersion:  sourceVersion,
+			},
+		}
+	}
+	return res
+}
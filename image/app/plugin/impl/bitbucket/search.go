@@ -0,0 +1,30 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"integration/app/plugin/types"
+	"net/url"
+	"strings"
+)
+
+// searches the repositories the user is a member of, only the first page of the results is returned
+func Search(ctx context.Context, params types.OptionsRequest) ([]types.SelectItem, error) {
+	if params.Token == "" {
+		return nil, fmt.Errorf("not authorized")
+	}
+	cl := &client{api: apiUrl(params.Url), user: params.User, token: params.Token}
+	term := strings.ReplaceAll(params.RepoName, `"`, `\"`)
+	u := cl.api + "/repositories?role=member&pagelen=100&q=" + url.QueryEscape(fmt.Sprintf(`full_name ~ "%s"`, term))
+	p := page[Repository]{}
+	if err := cl.get(ctx, u, &p); err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	res := []types.SelectItem{}
+	for _, v := range p.Values {
+		res = append(res, types.SelectItem{Label: v.FullName, Value: v.FullName})
+	}
+	return res, nil
+}
@@ -0,0 +1,62 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"io"
+	"net/http"
+)
+
+// the raw content is streamed from the src endpoint at the commit of the query (the remote hash)
+func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.StreamParams) (types.StreamsType, error) {
+	cl, err := getClient(streamParams.Url, streamParams.RepoName, streamParams.User, streamParams.Token)
+	if err != nil {
+		return types.StreamsType{}, fmt.Errorf("streams: %v", err)
+	}
+	res := map[string]types.Stream{}
+
+	for k, v := range in {
+		commit := v.Attributes.RemoteHash
+		if !v.Attributes.IsFile || (v.Action != tree.Update && v.Action != tree.Copy) {
+			continue
+		}
+		if commit == "" {
+			return types.StreamsType{}, fmt.Errorf("streams: commit hash not found")
+		}
+		request, err := cl.newRequest(ctx, cl.srcUrl(commit, v.SourceId()))
+		if err != nil {
+			return types.StreamsType{}, err
+		}
+		var r *http.Response
+
+		res[k] = types.Stream{
+			Open: func() (io.Reader, error) {
+				r, err = http.DefaultClient.Do(request)
+				if err != nil {
+					return nil, err
+				}
+				if r.StatusCode == http.StatusNotFound {
+					r.Body.Close()
+					return nil, types.ErrNotFound
+				}
+				if r.StatusCode != 200 {
+					b, _ := types.ReadAll(r.Body)
+					r.Body.Close()
+					if err := types.UnauthorizedError(r.StatusCode, b); err != nil {
+						return nil, err
+					}
+					return nil, fmt.Errorf("getting file failed: %d - %s", r.StatusCode, string(b))
+				}
+				return r.Body, nil
+			},
+			Close: func() error {
+				return r.Body.Close()
+			},
+		}
+	}
+	return types.StreamsType{Streams: res, Cleanup: nil}, nil
+}
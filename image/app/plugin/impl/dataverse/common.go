@@ -12,6 +12,8 @@ type Configuration struct {
 }
 
 var Config = map[string]Configuration{}
+var RecordSourceVersion = false  // set from the backend config, this package cannot import the config package
+var SkipMalformedEntries = false // set from the backend config (malformedFileEntries)
 
 func NewClient(pluginId, server, user, token string) *api.Client {
 	res := api.NewClient(server)
@@ -0,0 +1,36 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package dataverse
+
+import (
+	"fmt"
+	"integration/app/logging"
+
+	"github.com/libis/rdm-dataverse-go-api/api"
+)
+
+// file entries without id, name or checksum can not be compared, e.g., when a proxy or an older Dataverse version returns partial data;
+// such an entry fails the listing, or is skipped when SkipMalformedEntries is set (see malformedFileEntries in the backend config);
+// used for the listings of the destination datasets and of the Dataverse sources alike, metaData returns the api.MetaData of an entry
+func ValidEntries[T any](persistentId string, data []T, metaData func(T) api.MetaData) ([]T, error) {
+	res := []T{}
+	for i, entry := range data {
+		d := metaData(entry)
+		problem := ""
+		switch {
+		case d.DataFile.Id == 0 || d.DataFile.FileName == "":
+			problem = "no file id or name"
+		case d.DataFile.Md5 == "" && (d.DataFile.Checksum == nil || d.DataFile.Checksum.Value == ""):
+			problem = "no checksum"
+		}
+		if problem == "" {
+			res = append(res, entry)
+			continue
+		}
+		if !SkipMalformedEntries {
+			return nil, fmt.Errorf("listing files for %s failed: malformed response, entry %d (%q) has %s", persistentId, i, d.DataFile.FileName, problem)
+		}
+		logging.Logger.Printf("WARNING: %s: entry %d (%q) of the file listing has %s and is skipped\n", persistentId, i, d.DataFile.FileName, problem)
+	}
+	return res, nil
+}
@@ -4,17 +4,70 @@ package dataverse
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/libis/rdm-dataverse-go-api/api"
 	"integration/app/plugin/types"
 	"integration/app/tree"
+	"time"
 )
 
+// the file categories and the embargo are not part of api.MetaData
+type metaData struct {
+	api.MetaData
+	Categories []string `json:"categories"`
+	Embargo    *embargo `json:"-"`
+}
+
+type embargo struct {
+	DateAvailable string `json:"dateAvailable"` // e.g., "2030-01-01"
+}
+
+func (m *metaData) UnmarshalJSON(b []byte) error {
+	type plain metaData
+	if err := json.Unmarshal(b, (*plain)(m)); err != nil {
+		return err
+	}
+	access := struct {
+		DataFile struct {
+			Embargo *embargo `json:"embargo"`
+		} `json:"dataFile"`
+	}{}
+	if err := json.Unmarshal(b, &access); err != nil {
+		return err
+	}
+	m.Embargo = access.DataFile.Embargo
+	return nil
+}
+
+// the embargo ends at the start of the date available
+func (m metaData) embargoActive(now time.Time) bool {
+	if m.Embargo == nil {
+		return false
+	}
+	available, err := time.Parse(time.DateOnly, m.Embargo.DateAvailable)
+	return err != nil || now.Before(available)
+}
+
+type listResponse struct {
+	api.DvResponse
+	Data []metaData `json:"data"`
+}
+
+type versionResponse struct {
+	api.DvResponse
+	Data struct {
+		VersionNumber      int    `json:"versionNumber"`
+		VersionMinorNumber int    `json:"versionMinorNumber"`
+		VersionState       string `json:"versionState"`
+	} `json:"data"`
+}
+
 func Query(ctx context.Context, req types.CompareRequest, _ map[string]tree.Node) (map[string]tree.Node, error) {
 	path := "/api/v1/datasets/:persistentId/versions/:latest/files?persistentId=" + req.RepoName
 	client := NewClient(req.PluginId, req.Url, req.User, req.Token)
 	request := client.NewRequest(path, "GET", nil, nil)
-	res := api.ListResponse{}
+	res := listResponse{}
 	err := api.Do(ctx, request, &res)
 	if err != nil {
 		return nil, err
@@ -22,10 +75,40 @@ func Query(ctx context.Context, req types.CompareRequest, _ map[string]tree.Node
 	if res.Status != "OK" {
 		return nil, fmt.Errorf("listing files for %s failed: %+v", req.RepoName, res)
 	}
-	return mapToNodes(res.Data), nil
+	// OK with no files (or null data) is a valid empty dataset, only the entries themselves can be malformed
+	data, err := ValidEntries(req.RepoName, res.Data, func(d metaData) api.MetaData { return d.MetaData })
+	if err != nil {
+		return nil, err
+	}
+	version := ""
+	if RecordSourceVersion {
+		version, err = getVersion(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mapToNodes(data, version, time.Now()), nil
+}
+
+// the persistent id with the version of the dataset, e.g., "doi:10.123/ABC v1.2" or "doi:10.123/ABC DRAFT"
+func getVersion(ctx context.Context, req types.CompareRequest) (string, error) {
+	path := "/api/v1/datasets/:persistentId/versions/:latest?excludeFiles=true&persistentId=" + req.RepoName
+	client := NewClient(req.PluginId, req.Url, req.User, req.Token)
+	res := versionResponse{}
+	err := api.Do(ctx, client.NewRequest(path, "GET", nil, nil), &res)
+	if err != nil {
+		return "", err
+	}
+	if res.Status != "OK" {
+		return "", fmt.Errorf("getting the version of %s failed: %s", req.RepoName, res.Message)
+	}
+	if res.Data.VersionState == "DRAFT" {
+		return req.RepoName + " DRAFT", nil
+	}
+	return fmt.Sprintf("%s v%d.%d", req.RepoName, res.Data.VersionNumber, res.Data.VersionMinorNumber), nil
 }
 
-func mapToNodes(data []api.MetaData) map[string]tree.Node {
+func mapToNodes(data []metaData, version string, now time.Time) map[string]tree.Node {
 	res := map[string]tree.Node{}
 	for _, d := range data {
 		dir := ""
@@ -49,6 +132,10 @@ func mapToNodes(data []api.MetaData) map[string]tree.Node {
 				RemoteHash:     hash,
 				RemoteHashType: hashType,
 				RemoteFileSize: d.DataFile.FileSize,
+				Categories:     d.Categories,
+				SourceVersion:  version,
+				Restricted:     d.Restricted,
+				EmbargoActive:  d.embargoActive(now),
 			},
 		}
 	}
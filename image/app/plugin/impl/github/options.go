@@ -69,5 +69,30 @@ func Options(ctx context.Context, params types.OptionsRequest) ([]types.SelectIt
 	for _, v := range branches {
 		res = append(res, types.SelectItem{Label: v.GetName(), Value: v.GetName()})
 	}
+
+	// the tags follow the branches, such that a tagged release can be selected
+	tags, err := listTags(ctx, client, user, repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range tags {
+		res = append(res, types.SelectItem{Label: v.GetName() + " (tag)", Value: tagRefPrefix + v.GetName()})
+	}
 	return res, nil
 }
+
+func listTags(ctx context.Context, client *github.Client, user, repo string) ([]*github.RepositoryTag, error) {
+	opt := &github.ListOptions{Page: 1, PerPage: 100}
+	tags := []*github.RepositoryTag{}
+	for {
+		t, resp, err := client.Repositories.ListTags(ctx, user, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t...)
+		if resp.NextPage == 0 {
+			return tags, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
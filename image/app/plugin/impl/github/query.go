@@ -4,6 +4,8 @@ package github
 
 import (
 	"context"
+	"errors"
+	"integration/app/config"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"strings"
@@ -26,16 +28,87 @@ func Query(ctx context.Context, req types.CompareRequest, _ map[string]tree.Node
 		user = splitted[0]
 		repo = strings.Join(splitted[1:], "/")
 	}
-	tr, _, err := client.Git.GetTree(ctx, user, repo, req.Option, true)
+	ref := resolveRef(req.Option)
+	var tr *github.Tree
+	err := withRateLimit(ctx, func() (err error) {
+		tr, _, err = client.Git.GetTree(ctx, user, repo, ref, true)
+		return
+	})
 	if err != nil {
 		return nil, err
 	}
-	return toNodeMap(tr), nil
+	entries := tr.Entries
+	if tr.GetTruncated() {
+		// the recursive tree is limited to 100,000 entries (7 MB): the tree is listed directory by directory
+		entries, err = listTree(ctx, client, user, repo, ref, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	commit := ""
+	if config.GetConfig().Options.RecordSourceVersion {
+		err = withRateLimit(ctx, func() (err error) {
+			commit, _, err = client.Repositories.GetCommitSHA1(ctx, user, repo, ref, "")
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return toNodeMap(entries, commit), nil
 }
 
-func toNodeMap(tr *github.Tree) map[string]tree.Node {
-	res := map[string]tree.Node{}
+// lists the tree with the given SHA (or ref) without recursion and then each of its subtrees, the paths are prefixed with the path of the tree
+func listTree(ctx context.Context, client *github.Client, user, repo, sha, path string) ([]github.TreeEntry, error) {
+	var tr *github.Tree
+	err := withRateLimit(ctx, func() (err error) {
+		tr, _, err = client.Git.GetTree(ctx, user, repo, sha, false)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := []github.TreeEntry{}
 	for _, e := range tr.Entries {
+		entryPath := e.GetPath()
+		if path != "" {
+			entryPath = path + "/" + entryPath
+		}
+		e.Path = &entryPath
+		if e.GetType() == "tree" {
+			subEntries, err := listTree(ctx, client, user, repo, e.GetSHA(), entryPath)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, subEntries...)
+			continue
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+const tagRefPrefix = "refs/tags/"
+
+// the option is a branch name, a tag (as listed in the options: "refs/tags/<name>") or a commit SHA;
+// the GitHub API resolves branch names, tag names and SHAs alike
+func resolveRef(option string) string {
+	return strings.TrimPrefix(option, tagRefPrefix)
+}
+
+func unauthorizedError(err error) error {
+	errorResponse := &github.ErrorResponse{}
+	if errors.As(err, &errorResponse) && errorResponse.Response != nil {
+		if authErr := types.UnauthorizedError(errorResponse.Response.StatusCode, []byte(errorResponse.Message)); authErr != nil {
+			return authErr
+		}
+	}
+	return err
+}
+
+func toNodeMap(entries []github.TreeEntry, commit string) map[string]tree.Node {
+	res := map[string]tree.Node{}
+	for _, e := range entries {
 		isFile := e.GetType() == "blob"
 		if !isFile {
 			continue
@@ -59,6 +132,7 @@ func toNodeMap(tr *github.Tree) map[string]tree.Node {
 				RemoteHash:     e.GetSHA(),
 				RemoteHashType: types.GitHash,
 				RemoteFileSize: int64(e.GetSize()),
+				SourceVersion:  commit,
 			},
 		}
 		res[id] = node
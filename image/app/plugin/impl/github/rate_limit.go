@@ -0,0 +1,65 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"integration/app/logging"
+	"integration/app/plugin/types"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	rateLimitAttempts = 5
+	maxRateLimitWait  = 5 * time.Minute // when the limit resets later, the query fails and the user is told when to try again
+	abuseBackoff      = 10 * time.Second
+)
+
+// calls the GitHub API and waits for the rate limit to reset (X-RateLimit-Remaining is 0) or for the Retry-After delay of
+// the secondary (abuse) rate limit before retrying, with an increasing delay when the delay is not given
+func withRateLimit(ctx context.Context, call func() error) error {
+	for i := 1; ; i++ {
+		err := call()
+		wait, limited := rateLimitWait(err, i)
+		if !limited {
+			return unauthorizedError(err)
+		}
+		if i >= rateLimitAttempts || wait > maxRateLimitWait {
+			return rateLimitedError(err)
+		}
+		logging.Logger.Printf("github: rate limited, waiting %v before retrying (attempt %v/%v): %v\n", wait, i, rateLimitAttempts, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (cancelled while waiting for the GitHub rate limit)", rateLimitedError(err))
+		case <-time.After(wait):
+		}
+	}
+}
+
+func rateLimitWait(err error, attempt int) (time.Duration, bool) {
+	rateLimitErr := &github.RateLimitError{}
+	if errors.As(err, &rateLimitErr) {
+		return max(time.Until(rateLimitErr.Rate.Reset.Time), time.Second), true
+	}
+	abuseErr := &github.AbuseRateLimitError{}
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return *abuseErr.RetryAfter, true
+		}
+		return time.Duration(attempt) * abuseBackoff, true
+	}
+	return 0, false
+}
+
+// distinguishes waiting on the rate limit from an authentication failure in the message shown to the user
+func rateLimitedError(err error) error {
+	rateLimitErr := &github.RateLimitError{}
+	if errors.As(err, &rateLimitErr) {
+		return fmt.Errorf("%w: the GitHub API rate limit of %d requests is exceeded and resets at %v, please try again later", types.ErrRateLimited, rateLimitErr.Rate.Limit, rateLimitErr.Rate.Reset.Format(time.RFC1123))
+	}
+	return fmt.Errorf("%w: GitHub is temporarily limiting the requests for this repository, please try again in a few minutes", types.ErrRateLimited)
+}
@@ -39,7 +39,8 @@ func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.St
 		if !v.Attributes.IsFile || (v.Action != tree.Update && v.Action != tree.Copy) {
 			continue
 		}
-		if sha == "" {
+		if sha == "" || v.Attributes.RemoteHashType != types.GitHash {
+			// the blob is downloaded by its sha, the file size hash of the emptyHashPolicy can not be used
 			return types.StreamsType{}, fmt.Errorf("streams: sha not found")
 		}
 		var gitErr error
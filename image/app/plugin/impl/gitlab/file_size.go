@@ -0,0 +1,38 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// the repository tree does not contain file sizes, the HEAD request on the file returns the size in the headers
+func FileSize(ctx context.Context, node tree.Node, streamParams types.StreamParams) (int64, error) {
+	base := streamParams.Url
+	project := streamParams.RepoName
+	token := streamParams.Token
+	if project == "" || token == "" || base == "" {
+		return 0, fmt.Errorf("file size: missing parameters: expected base, group (optional), project and token")
+	}
+	url := base + "/api/v4/projects/" + url.PathEscape(project) + "/repository/files/" + url.PathEscape(node.Id) + "?ref=" + url.QueryEscape(streamParams.Option)
+	request, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Add("Authorization", "Bearer "+token)
+	r, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	r.Body.Close()
+	if r.StatusCode != 200 {
+		return 0, fmt.Errorf("getting file size of %v failed: %d", node.Id, r.StatusCode)
+	}
+	return strconv.ParseInt(r.Header.Get("X-Gitlab-Size"), 10, 64)
+}
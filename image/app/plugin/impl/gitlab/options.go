@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"integration/app/plugin/types"
-	"io"
 	"net/http"
 	"net/url"
 	"sort"
@@ -31,7 +30,7 @@ func Options(ctx context.Context, params types.OptionsRequest) ([]types.SelectIt
 		return nil, err
 	}
 	defer r.Body.Close()
-	b, err := io.ReadAll(r.Body)
+	b, err := types.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -6,9 +6,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"integration/app/config"
 	"integration/app/plugin/types"
 	"integration/app/tree"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -42,7 +42,43 @@ func Query(ctx context.Context, req types.CompareRequest, _ map[string]tree.Node
 		}
 	}
 	tr := GitlabTree{entries}
-	return toNodeMap(tr), nil
+	commit := ""
+	if config.GetConfig().Options.RecordSourceVersion {
+		commit, err = getCommit(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return toNodeMap(tr, commit), nil
+}
+
+func getCommit(ctx context.Context, req types.CompareRequest) (string, error) {
+	res := struct {
+		Id string `json:"id"`
+	}{}
+	url := fmt.Sprintf("%s/api/v4/projects/%s/repository/commits/%s", req.Url, url.PathEscape(req.RepoName), url.PathEscape(req.Option))
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Add("Authorization", "Bearer "+req.Token)
+	r, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+	b, err := types.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	if err := types.UnauthorizedError(r.StatusCode, b); err != nil {
+		return "", err
+	}
+	if r.StatusCode != 200 {
+		return "", fmt.Errorf("getting commit of %s failed: %d - %s", req.Option, r.StatusCode, string(b))
+	}
+	err = json.Unmarshal(b, &res)
+	return res.Id, err
 }
 
 func getPageEntries(ctx context.Context, req types.CompareRequest, page int) ([]GitlabEntry, error) {
@@ -58,15 +94,18 @@ func getPageEntries(ctx context.Context, req types.CompareRequest, page int) ([]
 		return nil, err
 	}
 	defer r.Body.Close()
-	b, err := io.ReadAll(r.Body)
+	b, err := types.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
+	if err := types.UnauthorizedError(r.StatusCode, b); err != nil {
+		return nil, err
+	}
 	err = json.Unmarshal(b, &res)
 	return res, err
 }
 
-func toNodeMap(tr GitlabTree) map[string]tree.Node {
+func toNodeMap(tr GitlabTree, commit string) map[string]tree.Node {
 	res := map[string]tree.Node{}
 	for _, e := range tr.Entries {
 		isFile := e.Type == "blob"
@@ -90,6 +129,7 @@ func toNodeMap(tr GitlabTree) map[string]tree.Node {
 				IsFile:         isFile,
 				RemoteHash:     e.Id,
 				RemoteHashType: types.GitHash,
+				SourceVersion:  commit,
 			},
 		}
 		res[id] = node
@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"integration/app/plugin/types"
-	"io"
 	"net/http"
 )
 
@@ -29,10 +28,13 @@ func Search(ctx context.Context, params types.OptionsRequest) ([]types.SelectIte
 	}
 	defer r.Body.Close()
 	if r.StatusCode != 200 && r.StatusCode != 404 {
-		b, _ := io.ReadAll(r.Body)
+		b, _ := types.ReadAll(r.Body)
 		return nil, fmt.Errorf("search failed: %d - %s", r.StatusCode, string(b))
 	}
-	b, _ := io.ReadAll(r.Body)
+	b, err := types.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %v", err)
+	}
 	results := []Item{}
 	json.Unmarshal(b, &results)
 
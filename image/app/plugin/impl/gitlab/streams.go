@@ -26,7 +26,8 @@ func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.St
 		if !v.Attributes.IsFile || (v.Action != tree.Update && v.Action != tree.Copy) {
 			continue
 		}
-		if sha == "" {
+		if sha == "" || v.Attributes.RemoteHashType != types.GitHash {
+			// the blob is downloaded by its sha, the file size hash of the emptyHashPolicy can not be used
 			return types.StreamsType{}, fmt.Errorf("streams: sha not found")
 		}
 		url := base + "/api/v4/projects/" + url.PathEscape(project) + "/repository/blobs/" + sha + "/raw"
@@ -43,8 +44,12 @@ func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.St
 				if err != nil {
 					return nil, err
 				}
+				if r.StatusCode == http.StatusNotFound {
+					r.Body.Close()
+					return nil, types.ErrNotFound
+				}
 				if r.StatusCode != 200 {
-					b, _ := io.ReadAll(r.Body)
+					b, _ := types.ReadAll(r.Body)
 					r.Body.Close()
 					return nil, fmt.Errorf("getting file failed: %s", string(b))
 				}
@@ -0,0 +1,57 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package globus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"integration/app/plugin/types"
+)
+
+// the task completed before it could be cancelled: the status is checked before cancelling, but the transfer can still complete
+// between that check and the cancel request; the files of a completed task are registered in the dataset as usual
+var ErrTaskComplete = errors.New("the Globus transfer has already completed and can no longer be cancelled")
+
+var errTransferCancelled = errors.New("the Globus transfer was cancelled, the files are not added to the dataset")
+
+type cancelResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// cancels the transfer task, the files of a cancelled task are not added to the dataset
+func CancelGlobusTransfer(ctx context.Context, token, taskId string) error {
+	// marked before the request: the task can be cancelled before its files are added to the dataset (see doTransfer)
+	markCancelled(ctx, taskId)
+	b, statusCode, err := doGlobusRequest(ctx, "https://transfer.api.globusonline.org/v0.10/task/"+taskId+"/cancel", "POST", token, nil)
+	if err != nil {
+		return err
+	}
+	if err := types.UnauthorizedError(statusCode, b); err != nil {
+		return fmt.Errorf("%w: %w", errLoginExpired, err)
+	}
+	response := cancelResponse{}
+	if err := json.Unmarshal(b, &response); err != nil && statusCode == 200 {
+		return fmt.Errorf("globus error: cancel response could not be unmarshalled from %v", string(b))
+	}
+	switch response.Code {
+	case "Canceled", "CancelAccepted":
+		return nil
+	case "TaskComplete":
+		config.GetRedis().Del(ctx, "globus cancelled: "+taskId)
+		return fmt.Errorf("%w: %v", ErrTaskComplete, taskId)
+	}
+	return fmt.Errorf("globus error: cancelling task %v failed: %d - %s", taskId, statusCode, string(b))
+}
+
+func markCancelled(ctx context.Context, taskId string) {
+	config.GetRedis().Set(ctx, "globus cancelled: "+taskId, true, taskRecordRetention)
+}
+
+func isCancelled(ctx context.Context, taskId string) bool {
+	_, ok, _ := config.GetFromCache(ctx, "globus cancelled: "+taskId)
+	return ok
+}
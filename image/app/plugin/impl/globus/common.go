@@ -88,10 +88,13 @@ func getResponse(ctx context.Context, url string, token string) ([]Data, error)
 
 func getPartialResponse(ctx context.Context, url string, token string, limit, offset int) (Response, error) {
 	fullUrl := fmt.Sprintf("%v&limit=%v&offset=%v", url, limit, offset)
-	b, err := DoGlobusRequest(ctx, fullUrl, "GET", token, nil)
+	b, statusCode, err := doGlobusRequest(ctx, fullUrl, "GET", token, nil)
 	if err != nil {
 		return Response{}, err
 	}
+	if err := types.UnauthorizedError(statusCode, b); err != nil {
+		return Response{}, err
+	}
 	response := Response{}
 	err = json.Unmarshal(b, &response)
 	if err != nil {
@@ -101,16 +104,23 @@ func getPartialResponse(ctx context.Context, url string, token string, limit, of
 }
 
 func DoGlobusRequest(ctx context.Context, url, method, token string, body io.Reader) ([]byte, error) {
+	b, _, err := doGlobusRequest(ctx, url, method, token, body)
+	return b, err
+}
+
+// also returns the status code, so that the cause of a failure can be reported
+func doGlobusRequest(ctx context.Context, url, method, token string, body io.Reader) ([]byte, int, error) {
 	request, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	request.Header.Add("Accept", "application/json")
 	request.Header.Add("Authorization", "Bearer "+token)
 	r, err := http.DefaultClient.Do(request)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer r.Body.Close()
-	return io.ReadAll(r.Body)
+	b, err := types.ReadAll(r.Body)
+	return b, r.StatusCode, err
 }
@@ -0,0 +1,71 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package globus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"integration/app/config"
+	"integration/app/logging"
+	"net/http"
+	"time"
+)
+
+// the causes of a failing transfer setup, the messages end up in the email sent to the user
+var (
+	errLoginExpired          = errors.New("globus login expired, please log in with Globus again")
+	errAccountNotLinked      = errors.New("globus account not linked, please log in with Globus and allow access to your identity")
+	errAuthUnavailable       = errors.New("globus authentication service unavailable, please try again later")
+	errEndpointNotConfigured = errors.New("no Globus endpoint is configured for the storage of the dataset, please contact the Dataverse administrator")
+	errDataverseUnavailable  = errors.New("dataverse unavailable, please try again later")
+	errUploadPathsRefused    = errors.New("dataverse refused to reserve Globus upload paths, check your permissions on the dataset")
+)
+
+const (
+	defaultGlobusRequestAttempts = 3
+	globusRetryDelay             = 2 * time.Second
+)
+
+func globusRequestAttempts() int {
+	if n := config.GetConfig().Options.GlobusRequestAttempts; n > 0 {
+		return n
+	}
+	return defaultGlobusRequestAttempts
+}
+
+func isTransient(err error) bool {
+	return errors.Is(err, errAuthUnavailable) || errors.Is(err, errDataverseUnavailable)
+}
+
+// maps the status code of a failed request to the Globus Auth API to the cause of the failure
+func authError(statusCode int, body []byte) error {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%w: %d - %s", errLoginExpired, statusCode, string(body))
+	case statusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %d - %s", errAccountNotLinked, statusCode, string(body))
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		return fmt.Errorf("%w: %d - %s", errAuthUnavailable, statusCode, string(body))
+	}
+	return fmt.Errorf("globus error: %d - %s", statusCode, string(body))
+}
+
+// transient failures are retried with an increasing delay, other failures are returned immediately
+func retryTransient(ctx context.Context, step string, f func() error) error {
+	attempts := globusRequestAttempts()
+	var err error
+	for i := 1; i <= attempts; i++ {
+		err = f()
+		if err == nil || !isTransient(err) || i == attempts {
+			return err
+		}
+		logging.Logger.Printf("globus: %v failed (attempt %d of %d), retrying: %v\n", step, i, attempts, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(i) * globusRetryDelay):
+		}
+	}
+	return err
+}
@@ -15,6 +15,7 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"time"
 
 	"github.com/libis/rdm-dataverse-go-api/api"
 )
@@ -107,15 +108,26 @@ func Streams(ctx context.Context, in map[string]tree.Node, p types.StreamParams)
 }
 
 func doTransfer(ctx context.Context, sessionId, token, repoName, option, pId, dvToken, user string, in map[string]tree.Node) error {
-	destinationEndpoint, err := getDestinationEndpoint(ctx, pId, dvToken, user)
+	var destinationEndpoint, prinicpal string
+	var paths []Path
+	err := retryTransient(ctx, "getting the destination endpoint", func() (err error) {
+		destinationEndpoint, err = getDestinationEndpoint(ctx, pId, dvToken, user)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	prinicpal, err := getPrincipal(ctx, sessionId)
+	err = retryTransient(ctx, "getting the principal", func() (err error) {
+		prinicpal, err = getPrincipal(ctx, sessionId)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	paths, err := RequestGlobusUploadPaths(ctx, pId, dvToken, user, prinicpal, len(in))
+	err = retryTransient(ctx, "requesting the upload paths", func() (err error) {
+		paths, err = RequestGlobusUploadPaths(ctx, pId, dvToken, user, prinicpal, len(in))
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -133,10 +145,10 @@ func doTransfer(ctx context.Context, sessionId, token, repoName, option, pId, dv
 	}
 	addGlobusFilesRequest := AddGlobusFilesRequest{}
 	index := 0
-	for k, v := range in {
+	for _, v := range in {
 		transferRequest.Data = append(transferRequest.Data, TransferRequestData{
 			DataType:        "transfer_item",
-			SourcePath:      option + "/" + k,
+			SourcePath:      option + "/" + v.SourceId(),
 			DestinationPath: paths[index].Path,
 			Recursive:       false,
 		})
@@ -159,18 +171,36 @@ func doTransfer(ctx context.Context, sessionId, token, repoName, option, pId, dv
 	if err != nil {
 		return err
 	}
+	if ctx.Err() != nil {
+		// the job was cancelled while the transfer was submitted: the task is cancelled too
+		shortContext, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := CancelGlobusTransfer(shortContext, token, taskId); err != nil {
+			logging.Logger.Printf("%v: cancelling globus transfer %v failed: %v\n", pId, taskId, err)
+		}
+		return ctx.Err()
+	}
 	addGlobusFilesRequest.TaskIdentifier = taskId
+	recordTask(ctx, pId, user, taskId)
+	// the task can be cancelled by the user as soon as it is recorded; when it is cancelled after this check,
+	// Dataverse does not add the files either, as it only adds them when the task succeeds
+	if isCancelled(ctx, taskId) {
+		return fmt.Errorf("%w: %v", errTransferCancelled, taskId)
+	}
 	return addGlobusFiles(ctx, pId, dvToken, user, addGlobusFilesRequest)
 }
 
 func getPrincipal(ctx context.Context, sessionId string) (string, error) {
-	token, ok := getTokenFromCache(ctx, sessionId)
-	if !ok {
-		return "", fmt.Errorf("globus error: token not in cache")
+	token, err := getTokenFromCache(ctx, sessionId)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errLoginExpired, err)
 	}
-	b, err := DoGlobusRequest(ctx, "https://auth.globus.org/v2/oauth2/userinfo", "GET", token.AccessToken, nil)
+	b, statusCode, err := doGlobusRequest(ctx, "https://auth.globus.org/v2/oauth2/userinfo", "GET", token.AccessToken, nil)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %v", errAuthUnavailable, err)
+	}
+	if statusCode != http.StatusOK {
+		return "", authError(statusCode, b)
 	}
 	response := UserInfo{}
 	err = json.Unmarshal(b, &response)
@@ -178,7 +208,7 @@ func getPrincipal(ctx context.Context, sessionId string) (string, error) {
 		return "", fmt.Errorf("globus error: UserInfo could not be unmarshalled from %v", string(b))
 	}
 	if response.Principal == "" {
-		return "", fmt.Errorf("globus error: principal not found in %v", string(b))
+		return "", fmt.Errorf("%w: principal not found in %v", errAccountNotLinked, string(b))
 	}
 	return response.Principal, nil
 }
@@ -224,22 +254,22 @@ func getDestinationEndpoint(ctx context.Context, persistentId, token, user strin
 	res := map[string]interface{}{}
 	err := api.Do(ctx, req, &res)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %v", errDataverseUnavailable, err)
 	}
 	if res["status"] != "OK" {
-		return "", fmt.Errorf("requesting Globus upload parameters failed: %s", res["message"])
+		return "", fmt.Errorf("%w: requesting Globus upload parameters failed: %s", errEndpointNotConfigured, res["message"])
 	}
 	data, ok := res["data"].(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("globus error: destination endpoint id not found in %+v", res)
+		return "", fmt.Errorf("%w: destination endpoint id not found in %+v", errEndpointNotConfigured, res)
 	}
 	queryParameters, ok := data["queryParameters"].(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("globus error: destination endpoint id not found in %+v", res)
+		return "", fmt.Errorf("%w: destination endpoint id not found in %+v", errEndpointNotConfigured, res)
 	}
 	destinationEndpoint, ok := queryParameters["endpoint"].(string)
 	if !ok || destinationEndpoint == "" {
-		return "", fmt.Errorf("globus error: destination endpoint id not found in %+v", res)
+		return "", fmt.Errorf("%w: destination endpoint id not found in %+v", errEndpointNotConfigured, res)
 	}
 	return destinationEndpoint, nil
 }
@@ -257,10 +287,10 @@ func RequestGlobusUploadPaths(ctx context.Context, persistentId, token, user, pr
 	res := Response{}
 	err := api.Do(ctx, req, &res)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", errDataverseUnavailable, err)
 	}
 	if res.Status != "OK" {
-		return nil, fmt.Errorf("requesting Globus upload paths failed: %+v", res)
+		return nil, fmt.Errorf("%w: %s", errUploadPathsRefused, res.Message)
 	}
 	pathsWithIds := []Path{}
 	for k, v := range res.Data {
@@ -298,13 +328,18 @@ func requestBody(data []byte) (io.Reader, string) {
 	return body, writer.FormDataContentType()
 }
 
-func getTokenFromCache(ctx context.Context, sessionId string) (coreTypes.OauthTokenResponse, bool) {
-	cached := config.GetRedis().Get(ctx, fmt.Sprintf("%v-%v", "globus", sessionId))
-	jsonString := cached.Val()
-	if jsonString == "" {
-		return coreTypes.OauthTokenResponse{}, false
-	}
+func getTokenFromCache(ctx context.Context, sessionId string) (coreTypes.OauthTokenResponse, error) {
 	res := coreTypes.OauthTokenResponse{}
-	json.Unmarshal([]byte(jsonString), &res)
-	return res, true
+	jsonString, ok, err := config.GetFromCache(ctx, fmt.Sprintf("%v-%v", "globus", sessionId))
+	if err != nil {
+		return res, fmt.Errorf("globus error: %v", err)
+	}
+	if !ok {
+		return res, fmt.Errorf("globus error: token not in cache, the login has expired or was evicted from the cache, please log in again")
+	}
+	err = json.Unmarshal([]byte(jsonString), &res)
+	if err != nil {
+		return res, fmt.Errorf("globus error: cached token could not be read: %v", err)
+	}
+	return res, nil
 }
@@ -0,0 +1,130 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package globus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"integration/app/config"
+	"integration/app/plugin/types"
+	"time"
+)
+
+const (
+	TaskActive    = "ACTIVE"
+	TaskInactive  = "INACTIVE" // paused, e.g., the credentials of an endpoint expired: the task resumes when the problem is resolved
+	TaskSucceeded = "SUCCEEDED"
+	TaskFailed    = "FAILED"
+)
+
+const taskRecordRetention = 7 * 24 * time.Hour
+
+// the problems reported by Globus in the nice_status of a task, as explained to the user
+var niceStatusMessages = map[string]string{
+	"PERMISSION_DENIED":      "permission denied on the source or the destination endpoint, check your permissions on the collection",
+	"NO_CREDENTIALS":         "the endpoint has no valid credentials, please (re)activate the endpoint in Globus",
+	"GC_NOT_CONNECTED":       "the Globus Connect Personal endpoint is not connected, please start Globus Connect Personal",
+	"GC_PAUSED":              "the Globus Connect Personal endpoint is paused, please resume it",
+	"CONNECT_FAILED":         "the endpoint could not be reached, the transfer is retried by Globus",
+	"CONNECTION_RESET":       "the connection to the endpoint was reset, the transfer is retried by Globus",
+	"ENDPOINT_TOO_BUSY":      "the endpoint is too busy, the transfer is retried by Globus",
+	"ENDPOINT_ERROR":         "the endpoint reported an error, the transfer is retried by Globus",
+	"FILE_NOT_FOUND":         "a file was not found at the source",
+	"PATH_NOT_FOUND":         "a path was not found at the source or the destination",
+	"QUOTA_EXCEEDED":         "the quota at the destination is exceeded, please contact the Dataverse administrator",
+	"FILE_SIZE_CHANGED":      "a file changed at the source during the transfer",
+	"VERIFY_CHECKSUM_FAILED": "the checksum of a transferred file did not match, the file is transferred again by Globus",
+}
+
+// the state of a Globus transfer task, the message explains the nice_status when it reports a problem
+type TaskStatus struct {
+	TaskId           string `json:"taskId"`
+	Status           string `json:"status"` // ACTIVE, INACTIVE, SUCCEEDED or FAILED
+	NiceStatus       string `json:"niceStatus,omitempty"`
+	Message          string `json:"message,omitempty"`
+	Files            int64  `json:"files"`
+	FilesTransferred int64  `json:"filesTransferred"`
+	BytesTransferred int64  `json:"bytesTransferred"`
+}
+
+type taskResponse struct {
+	TaskId                     string `json:"task_id"`
+	Status                     string `json:"status"`
+	NiceStatus                 string `json:"nice_status"`
+	NiceStatusShortDescription string `json:"nice_status_short_description"`
+	Files                      int64  `json:"files"`
+	FilesTransferred           int64  `json:"files_transferred"`
+	BytesTransferred           int64  `json:"bytes_transferred"`
+}
+
+func GlobusTaskStatus(ctx context.Context, token, taskId string) (TaskStatus, error) {
+	b, statusCode, err := doGlobusRequest(ctx, "https://transfer.api.globusonline.org/v0.10/task/"+taskId, "GET", token, nil)
+	if err != nil {
+		return TaskStatus{}, err
+	}
+	if err := types.UnauthorizedError(statusCode, b); err != nil {
+		return TaskStatus{}, fmt.Errorf("%w: %w", errLoginExpired, err)
+	}
+	if statusCode != 200 {
+		return TaskStatus{}, fmt.Errorf("globus error: getting the status of task %v failed: %d - %s", taskId, statusCode, string(b))
+	}
+	response := taskResponse{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return TaskStatus{}, fmt.Errorf("globus error: task status could not be unmarshalled from %v", string(b))
+	}
+	res := TaskStatus{
+		TaskId:           taskId,
+		Status:           response.Status,
+		NiceStatus:       response.NiceStatus,
+		Files:            response.Files,
+		FilesTransferred: response.FilesTransferred,
+		BytesTransferred: response.BytesTransferred,
+	}
+	if msg, ok := niceStatusMessages[response.NiceStatus]; ok {
+		res.Message = msg
+	} else if response.NiceStatus != "" && response.NiceStatus != "OK" && response.NiceStatus != "Queued" {
+		res.Message = response.NiceStatusShortDescription
+	}
+	return res, nil
+}
+
+// polls the status of the task until it succeeded or failed, or until the context is done (returning the last known status)
+func PollTaskStatus(ctx context.Context, token, taskId string, interval time.Duration) (TaskStatus, error) {
+	for {
+		res, err := GlobusTaskStatus(ctx, token, taskId)
+		if err != nil || res.Status == TaskSucceeded || res.Status == TaskFailed {
+			return res, err
+		}
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// the last transfer task of the dataset, so that its status can be requested by the user that started it
+type taskRecord struct {
+	TaskId string `json:"taskId"`
+	User   string `json:"user"`
+}
+
+func recordTask(ctx context.Context, persistentId, user, taskId string) {
+	b, _ := json.Marshal(taskRecord{TaskId: taskId, User: user})
+	config.GetRedis().Set(ctx, "globus task: "+persistentId, string(b), taskRecordRetention)
+}
+
+// returns the id of the last transfer task of the dataset started by the user, false when there is none
+func LastTaskId(ctx context.Context, persistentId, user string) (string, bool, error) {
+	cached, ok, err := config.GetFromCache(ctx, "globus task: "+persistentId)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	res := taskRecord{}
+	if json.Unmarshal([]byte(cached), &res) != nil || res.User != user {
+		return "", false, nil
+	}
+	return res.TaskId, true, nil
+}
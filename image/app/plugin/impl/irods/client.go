@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	pluginTypes "integration/app/plugin/types"
 	"io"
 	"net/http"
 	"strconv"
@@ -207,7 +208,10 @@ func getConnectionInfo(zone, token string) (ConnectionInfo, error) {
 		return ConnectionInfo{}, err
 	}
 	defer response.Body.Close()
-	responseData, _ := io.ReadAll(response.Body)
+	responseData, err := pluginTypes.ReadAll(response.Body)
+	if err != nil {
+		return ConnectionInfo{}, err
+	}
 	err = json.Unmarshal(responseData, &res)
 	return res, err
 }
@@ -238,7 +242,10 @@ func getZones(token string) ([]Zone, error) {
 		return nil, err
 	}
 	defer response.Body.Close()
-	responseData, _ := io.ReadAll(response.Body)
+	responseData, err := pluginTypes.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
 	err = json.Unmarshal(responseData, &res)
 	return res, err
 }
@@ -4,6 +4,7 @@ package irods
 
 import (
 	"context"
+	"integration/app/logging"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"strings"
@@ -33,6 +34,8 @@ func toNodeMap(cl *IrodsClient, folder string, entries []*fs.Entry, nm map[strin
 		if !isFile {
 			if e.Type == "directory" {
 				dirs = append(dirs, e.Path)
+			} else {
+				logging.Logger.Printf("irods plugin: skipping %v entry %v\n", e.Type, e.Path)
 			}
 			continue
 		}
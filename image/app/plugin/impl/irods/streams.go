@@ -25,7 +25,7 @@ func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.St
 	}
 	res := map[string]types.Stream{}
 	for k, v := range in {
-		path := v.Id
+		path := v.SourceId()
 		if !v.Attributes.IsFile || (v.Action != tree.Update && v.Action != tree.Copy) {
 			continue
 		}
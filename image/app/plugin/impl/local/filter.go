@@ -0,0 +1,91 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package local
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// include and exclude glob patterns matched against the path relative to the selected folder (with "/" as separator):
+// "*" matches any characters except "/", "?" one character except "/" and "**" any number of directories (e.g., "**/.git/**", "**/*.tmp");
+// a file is skipped when it matches an exclude pattern, also when it matches an include pattern (exclude takes precedence),
+// when include patterns are given, only the files matching at least one of them are kept;
+// folders matching an exclude pattern (e.g., "node_modules", or ".git" for "**/.git/**") are not walked
+type filter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// the patterns are comma separated
+func newFilter(include, exclude string) (filter, error) {
+	in, err := compileGlobs(include)
+	if err != nil {
+		return filter{}, err
+	}
+	ex, err := compileGlobs(exclude)
+	if err != nil {
+		return filter{}, err
+	}
+	return filter{include: in, exclude: ex}, nil
+}
+
+func (f filter) keepFile(path string) bool {
+	if matchesAny(f.exclude, path) {
+		return false
+	}
+	return len(f.include) == 0 || matchesAny(f.include, path)
+}
+
+func (f filter) keepDir(path string) bool {
+	return !matchesAny(f.exclude, path) && !matchesAny(f.exclude, path+"/")
+}
+
+func matchesAny(patterns []*regexp.Regexp, path string) bool {
+	for _, p := range patterns {
+		if p.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileGlobs(patterns string) ([]*regexp.Regexp, error) {
+	res := []*regexp.Regexp{}
+	for _, p := range strings.Split(patterns, ",") {
+		if p = strings.Trim(strings.TrimSpace(p), "/"); p == "" {
+			continue
+		}
+		r, err := regexp.Compile(globToRegexp(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %v: %v", p, err)
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
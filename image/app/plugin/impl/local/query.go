@@ -4,8 +4,9 @@ package local
 
 import (
 	"context"
-	"crypto/md5"
 	"fmt"
+	"integration/app/config"
+	"integration/app/logging"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"io"
@@ -24,25 +25,30 @@ type Entry struct {
 }
 
 func Query(_ context.Context, req types.CompareRequest, dvNodes map[string]tree.Node) (map[string]tree.Node, error) {
+	_, hashType := types.NewSourceHasher(req.HashType)
 	path := strings.TrimSuffix(req.Url, string(os.PathSeparator))
-	entries, err := list(path, path, dvNodes)
+	f, err := newFilter(req.Include, req.Exclude)
 	if err != nil {
 		return nil, err
 	}
-	dirs, nodes, err := toNodeMap(entries)
+	entries, err := list(path, path, dvNodes, hashType, f)
+	if err != nil {
+		return nil, err
+	}
+	dirs, nodes, err := toNodeMap(entries, hashType)
 	if err != nil {
 		return nil, err
 	}
 	for len(dirs) != 0 {
 		moreDirs := []string{}
 		for _, d := range dirs {
-			subEntries, err := list(path, d, dvNodes)
+			subEntries, err := list(path, d, dvNodes, hashType, f)
 			if err != nil {
 				return nil, err
 			}
 			var nm map[string]tree.Node
 			var subDirs []string
-			subDirs, nm, err = toNodeMap(subEntries)
+			subDirs, nm, err = toNodeMap(subEntries, hashType)
 			if err != nil {
 				return nil, err
 			}
@@ -56,7 +62,7 @@ func Query(_ context.Context, req types.CompareRequest, dvNodes map[string]tree.
 	return nodes, nil
 }
 
-func toNodeMap(entries []Entry) ([]string, map[string]tree.Node, error) {
+func toNodeMap(entries []Entry, hashType string) ([]string, map[string]tree.Node, error) {
 	res := map[string]tree.Node{}
 	dirs := []string{}
 	for _, e := range entries {
@@ -73,7 +79,7 @@ func toNodeMap(entries []Entry) ([]string, map[string]tree.Node, error) {
 			Attributes: tree.Attributes{
 				IsFile:         isFile,
 				RemoteHash:     e.CheckSum,
-				RemoteHashType: types.Md5,
+				RemoteHashType: hashType,
 				RemoteFileSize: e.Size,
 			},
 		}
@@ -82,13 +88,20 @@ func toNodeMap(entries []Entry) ([]string, map[string]tree.Node, error) {
 	return dirs, res, nil
 }
 
-func list(root, folder string, dvNodes map[string]tree.Node) ([]Entry, error) {
+func list(root, folder string, dvNodes map[string]tree.Node, hashType string, f filter) ([]Entry, error) {
 	files, err := os.ReadDir(folder)
 	if err != nil {
 		return nil, err
 	}
+	relativeFolder := ""
+	if len(folder) > len(root) {
+		relativeFolder = strings.Join(strings.Split(folder[len(root)+1:], string(os.PathSeparator)), "/") + "/"
+	}
 	res := []Entry{}
 	for _, v := range files {
+		if v.IsDir() && !f.keepDir(relativeFolder+v.Name()) || !v.IsDir() && !f.keepFile(relativeFolder+v.Name()) {
+			continue
+		}
 		path := folder + string(os.PathSeparator) + v.Name()
 		checkSum := types.NotNeeded
 		parentId := ""
@@ -96,8 +109,15 @@ func list(root, folder string, dvNodes map[string]tree.Node) ([]Entry, error) {
 		fileName := v.Name()
 		idDir := v.IsDir()
 		var size int64
+		info, err := v.Info()
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			info, err = resolveSymlink(path)
+			if err != nil {
+				logging.Logger.Printf("local plugin: skipping symlink %v: %v\n", path, err)
+				continue
+			}
+		}
 		if !idDir {
-			info, err := v.Info()
 			if err == nil {
 				size = info.Size()
 			}
@@ -108,7 +128,7 @@ func list(root, folder string, dvNodes map[string]tree.Node) ([]Entry, error) {
 				id = parentId + "/" + fileName
 			}
 			if _, ok := dvNodes[id]; ok {
-				checkSum, err = hash(path)
+				checkSum, err = hash(path, hashType)
 				if err != nil {
 					return nil, err
 				}
@@ -127,13 +147,27 @@ func list(root, folder string, dvNodes map[string]tree.Node) ([]Entry, error) {
 	return res, nil
 }
 
-func hash(path string) (string, error) {
+func resolveSymlink(path string) (os.FileInfo, error) {
+	if !config.GetConfig().Options.FollowSymlinks {
+		return nil, fmt.Errorf("following symlinks is disabled")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("symlinks to folders are not followed")
+	}
+	return info, nil
+}
+
+func hash(path, hashType string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
-	hasher := md5.New()
+	hasher, _ := types.NewSourceHasher(hashType)
 	io.Copy(hasher, f)
 	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
@@ -4,6 +4,7 @@ package local
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"integration/app/plugin/types"
 	"integration/app/tree"
@@ -21,11 +22,14 @@ func Streams(_ context.Context, in map[string]tree.Node, streamParams types.Stre
 	for k, v := range in {
 		var err error
 		var reader io.ReadCloser
-		id := v.Id
+		id := v.SourceId()
 
 		res[k] = types.Stream{
 			Open: func() (io.Reader, error) {
 				reader, err = os.Open(url + string(os.PathSeparator) + id)
+				if errors.Is(err, os.ErrNotExist) {
+					return nil, fmt.Errorf("%w: %v", types.ErrNotFound, err)
+				}
 				return reader, err
 			},
 			Close: func() error {
@@ -8,7 +8,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"integration/app/plugin/types"
-	"io"
 	"net/http"
 )
 
@@ -135,10 +134,13 @@ func getPartialResponse(ctx context.Context, url string, token string) (Response
 		return Response{}, err
 	}
 	defer r.Body.Close()
-	b, err := io.ReadAll(r.Body)
+	b, err := types.ReadAll(r.Body)
 	if err != nil {
 		return Response{}, err
 	}
+	if err := types.UnauthorizedError(r.StatusCode, b); err != nil {
+		return Response{}, err
+	}
 	response := Response{}
 	err = json.Unmarshal(b, &response)
 	if err != nil {
@@ -33,8 +33,12 @@ func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.St
 				if err != nil {
 					return nil, err
 				}
+				if r.StatusCode == http.StatusNotFound {
+					r.Body.Close()
+					return nil, types.ErrNotFound
+				}
 				if r.StatusCode != 200 {
-					b, _ := io.ReadAll(r.Body)
+					b, _ := types.ReadAll(r.Body)
 					r.Body.Close()
 					return nil, fmt.Errorf("getting file failed: %s", string(b))
 				}
@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"integration/app/plugin/types"
-	"io"
 	"net/http"
 	"strings"
 )
@@ -119,7 +118,11 @@ func query(ctx context.Context, url, token string) ([]byte, error) {
 		return nil, err
 	}
 	defer r.Body.Close()
-	return io.ReadAll(r.Body)
+	b, err := types.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return b, types.UnauthorizedError(r.StatusCode, b)
 }
 
 func getFiles(ctx context.Context, server, repoName, token string) ([]File, error) {
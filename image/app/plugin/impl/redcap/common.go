@@ -7,7 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"integration/app/plugin/types"
 	"net/http"
 	"net/url"
 )
@@ -56,10 +56,13 @@ func listEntries(ctx context.Context, folderId int64, path, url, token string, r
 		return nil, err
 	}
 	defer r.Body.Close()
-	b, err := io.ReadAll(r.Body)
+	b, err := types.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
+	if err := types.UnauthorizedError(r.StatusCode, b); err != nil {
+		return nil, err
+	}
 	response := []RedCapResponseEntry{}
 	err = json.Unmarshal(b, &response)
 	if err != nil {
@@ -2,6 +2,9 @@ package sftp_plugin
 
 import (
 	"fmt"
+	"integration/app/plugin/types"
+	"strings"
+
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
@@ -20,6 +23,9 @@ func getClient(sftpUrl, user, pass string) (*client, error) {
 	}
 
 	conn, err := ssh.Dial("tcp", sftpUrl, &config)
+	if err != nil && strings.Contains(err.Error(), "unable to authenticate") {
+		return nil, fmt.Errorf("%w: failed to connecto to [%s]: %v", types.ErrUnauthorized, sftpUrl, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to connecto to [%s]: %v", sftpUrl, err)
 	}
@@ -4,11 +4,13 @@ package sftp_plugin
 
 import (
 	"context"
-	"crypto/md5"
 	"fmt"
+	"integration/app/config"
+	"integration/app/logging"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -23,6 +25,7 @@ type Entry struct {
 }
 
 func Query(_ context.Context, req types.CompareRequest, dvNodes map[string]tree.Node) (map[string]tree.Node, error) {
+	_, hashType := types.NewSourceHasher(req.HashType)
 	path := req.Option
 
 	cl, err := getClient(req.Url, req.User, req.Token)
@@ -31,24 +34,24 @@ func Query(_ context.Context, req types.CompareRequest, dvNodes map[string]tree.
 	}
 	defer cl.Close()
 
-	entries, err := list(cl, path, path, dvNodes)
+	entries, err := list(cl, path, path, dvNodes, hashType)
 	if err != nil {
 		return nil, err
 	}
-	dirs, nodes, err := toNodeMap(entries)
+	dirs, nodes, err := toNodeMap(entries, hashType)
 	if err != nil {
 		return nil, err
 	}
 	for len(dirs) != 0 {
 		moreDirs := []string{}
 		for _, d := range dirs {
-			subEntries, err := list(cl, path, d, dvNodes)
+			subEntries, err := list(cl, path, d, dvNodes, hashType)
 			if err != nil {
 				return nil, err
 			}
 			var irodsNm map[string]tree.Node
 			var subDirs []string
-			subDirs, irodsNm, err = toNodeMap(subEntries)
+			subDirs, irodsNm, err = toNodeMap(subEntries, hashType)
 			if err != nil {
 				return nil, err
 			}
@@ -62,7 +65,7 @@ func Query(_ context.Context, req types.CompareRequest, dvNodes map[string]tree.
 	return nodes, nil
 }
 
-func toNodeMap(entries []Entry) ([]string, map[string]tree.Node, error) {
+func toNodeMap(entries []Entry, hashType string) ([]string, map[string]tree.Node, error) {
 	res := map[string]tree.Node{}
 	dirs := []string{}
 	for _, e := range entries {
@@ -79,7 +82,7 @@ func toNodeMap(entries []Entry) ([]string, map[string]tree.Node, error) {
 			Attributes: tree.Attributes{
 				IsFile:         isFile,
 				RemoteHash:     e.CheckSum,
-				RemoteHashType: types.Md5,
+				RemoteHashType: hashType,
 				RemoteFileSize: e.Size,
 			},
 		}
@@ -88,7 +91,7 @@ func toNodeMap(entries []Entry) ([]string, map[string]tree.Node, error) {
 	return dirs, res, nil
 }
 
-func list(cl *client, root, folder string, dvNodes map[string]tree.Node) ([]Entry, error) {
+func list(cl *client, root, folder string, dvNodes map[string]tree.Node, hashType string) ([]Entry, error) {
 	files, err := cl.SftpClient.ReadDir(folder)
 	if err != nil {
 		return nil, err
@@ -96,6 +99,13 @@ func list(cl *client, root, folder string, dvNodes map[string]tree.Node) ([]Entr
 	res := []Entry{}
 	for _, v := range files {
 		path := folder + "/" + v.Name()
+		if v.Mode()&os.ModeSymlink != 0 {
+			v, err = resolveSymlink(cl, path)
+			if err != nil {
+				logging.Logger.Printf("sftp plugin: skipping symlink %v: %v\n", path, err)
+				continue
+			}
+		}
 		checkSum := types.NotNeeded
 		parentId := ""
 		id := ""
@@ -111,7 +121,7 @@ func list(cl *client, root, folder string, dvNodes map[string]tree.Node) ([]Entr
 				id = parentId + "/" + fileName
 			}
 			if _, ok := dvNodes[id]; ok {
-				checkSum, err = hash(cl, path)
+				checkSum, err = hash(cl, path, hashType)
 				if err != nil {
 					return nil, err
 				}
@@ -132,13 +142,27 @@ func list(cl *client, root, folder string, dvNodes map[string]tree.Node) ([]Entr
 	return res, nil
 }
 
-func hash(cl *client, path string) (string, error) {
+func resolveSymlink(cl *client, path string) (os.FileInfo, error) {
+	if !config.GetConfig().Options.FollowSymlinks {
+		return nil, fmt.Errorf("following symlinks is disabled")
+	}
+	info, err := cl.SftpClient.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("symlinks to folders are not followed")
+	}
+	return info, nil
+}
+
+func hash(cl *client, path, hashType string) (string, error) {
 	f, err := cl.SftpClient.Open(path)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
-	hasher := md5.New()
+	hasher, _ := types.NewSourceHasher(hashType)
 	io.Copy(hasher, f)
 	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
@@ -4,10 +4,12 @@ package sftp_plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 	"io"
+	"os"
 )
 
 func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.StreamParams) (types.StreamsType, error) {
@@ -26,12 +28,14 @@ func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.St
 	for k, v := range in {
 		var err error
 		var reader io.ReadCloser
-		id := v.Id
+		id := v.SourceId()
 
 		res[k] = types.Stream{
 			Open: func() (io.Reader, error) {
 				reader, err = c.SftpClient.Open(streamParams.Option + id)
-
+				if errors.Is(err, os.ErrNotExist) {
+					return nil, fmt.Errorf("%w: %v", types.ErrNotFound, err)
+				}
 				return reader, err
 			},
 			Close: func() error {
@@ -0,0 +1,202 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"integration/app/plugin/types"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+	<d:prop>
+		<d:resourcetype/>
+		<d:getcontentlength/>
+		<d:getetag/>
+	</d:prop>
+</d:propfind>`
+
+type Multistatus struct {
+	Responses []Response `xml:"DAV: response"`
+}
+
+type Response struct {
+	Href     string     `xml:"DAV: href"`
+	Propstat []Propstat `xml:"DAV: propstat"`
+}
+
+type Propstat struct {
+	Prop   Prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type Prop struct {
+	ResourceType  ResourceType `xml:"DAV: resourcetype"`
+	ContentLength int64        `xml:"DAV: getcontentlength"`
+	ETag          string       `xml:"DAV: getetag"`
+}
+
+type ResourceType struct {
+	Collection *struct{} `xml:"DAV: collection"`
+}
+
+type Entry struct {
+	Path  string
+	Id    string
+	Name  string
+	IsDir bool
+	ETag  string
+	Size  int64
+}
+
+type client struct {
+	root     *url.URL
+	user     string
+	password string
+}
+
+// the url is the WebDAV root of the user, e.g., https://cloud.example.org/remote.php/dav/files/username for Nextcloud;
+// the password can also be an app password
+func getClient(server, user, password string) (*client, error) {
+	if server == "" || user == "" || password == "" {
+		return nil, fmt.Errorf("missing parameters: expected url, user and password")
+	}
+	root, err := url.Parse(strings.TrimSuffix(server, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebDAV url %v: %v", server, err)
+	}
+	return &client{root: root, user: user, password: password}, nil
+}
+
+// the url of a path relative to the WebDAV root, the path segments are escaped
+func (cl *client) url(path string) string {
+	return cl.root.JoinPath(path).String()
+}
+
+// path relative to the WebDAV root of an href returned by PROPFIND (absolute path or full url)
+func (cl *client) relativePath(href string) (string, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", fmt.Errorf("invalid href %v: %v", href, err)
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(u.Path, cl.root.Path), "/"), nil
+}
+
+func (cl *client) newRequest(ctx context.Context, method, url string, body string) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.SetBasicAuth(cl.user, cl.password)
+	return request, nil
+}
+
+// lists the folder (relative to the WebDAV root, "" for the root itself, other folders end with "/");
+// Depth: infinity is disabled on most servers (e.g., Nextcloud), recursive listing goes folder by folder
+func listItems(ctx context.Context, cl *client, folder string, recursive bool) ([]Entry, error) {
+	responses, err := propfind(ctx, cl, folder)
+	if err != nil {
+		return nil, err
+	}
+	res := []Entry{}
+	for _, v := range responses {
+		path, err := cl.relativePath(v.Href)
+		if err != nil {
+			return nil, err
+		}
+		prop := v.prop()
+		isDir := prop.ResourceType.Collection != nil
+		id := strings.TrimSuffix(path, "/")
+		name := id[strings.LastIndex(id, "/")+1:]
+		if isDir {
+			id = id + "/"
+		}
+		// the folder itself is part of the response, as the "." entry
+		if id == folder || id == "/" || name == "." || name == ".." {
+			continue
+		}
+		if recursive && isDir {
+			folderEntries, err := listItems(ctx, cl, id, true)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, folderEntries...)
+		}
+		res = append(res, Entry{
+			Path:  folder,
+			Id:    id,
+			Name:  name,
+			IsDir: isDir,
+			ETag:  strings.Trim(strings.TrimPrefix(prop.ETag, "W/"), `"`),
+			Size:  prop.ContentLength,
+		})
+	}
+	return res, nil
+}
+
+func propfind(ctx context.Context, cl *client, folder string) ([]Response, error) {
+	request, err := cl.newRequest(ctx, "PROPFIND", cl.url(folder), propfindBody)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Add("Depth", "1")
+	request.Header.Add("Content-Type", "application/xml; charset=utf-8")
+	r, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	b, err := types.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := types.UnauthorizedError(r.StatusCode, b); err != nil {
+		return nil, err
+	}
+	if r.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: folder %v", types.ErrNotFound, folder)
+	}
+	if r.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("listing folder %v failed: %d - %s", folder, r.StatusCode, string(b))
+	}
+	res := Multistatus{}
+	err = xml.Unmarshal(b, &res)
+	if err != nil {
+		return nil, fmt.Errorf("listing folder %v failed: %v", folder, err)
+	}
+	return res.Responses, nil
+}
+
+// properties that were found (the properties not set on the resource, e.g., the size of a folder, are in a "404 Not Found" propstat)
+func (r Response) prop() Prop {
+	res := Prop{}
+	for _, p := range r.Propstat {
+		if !strings.Contains(p.Status, " 200 ") {
+			continue
+		}
+		if p.Prop.ResourceType.Collection != nil {
+			res.ResourceType = p.Prop.ResourceType
+		}
+		if p.Prop.ContentLength != 0 {
+			res.ContentLength = p.Prop.ContentLength
+		}
+		if p.Prop.ETag != "" {
+			res.ETag = p.Prop.ETag
+		}
+	}
+	return res
+}
+
+// the folder as passed in the option: relative to the WebDAV root, "" for the root and ending with "/" otherwise
+func normalizeFolder(folder string) string {
+	folder = strings.Trim(folder, "/")
+	if folder == "" {
+		return ""
+	}
+	return folder + "/"
+}
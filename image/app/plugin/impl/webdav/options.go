@@ -0,0 +1,28 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"integration/app/plugin/types"
+)
+
+func Options(ctx context.Context, params types.OptionsRequest) ([]types.SelectItem, error) {
+	cl, err := getClient(params.Url, params.User, params.Token)
+	if err != nil {
+		return nil, fmt.Errorf("folders: %v", err)
+	}
+	folder := normalizeFolder(params.Option)
+	entries, err := listItems(ctx, cl, folder, false)
+	if err != nil {
+		return nil, err
+	}
+	res := []types.SelectItem{}
+	for _, e := range entries {
+		if e.IsDir {
+			res = append(res, types.SelectItem{Label: e.Name, Value: e.Id})
+		}
+	}
+	return res, nil
+}
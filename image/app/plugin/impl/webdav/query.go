@@ -0,0 +1,48 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package webdav
+
+import (
+	"context"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"strings"
+)
+
+func Query(ctx context.Context, req types.CompareRequest, _ map[string]tree.Node) (map[string]tree.Node, error) {
+	cl, err := getClient(req.Url, req.User, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	folder := normalizeFolder(req.Option)
+	entries, err := listItems(ctx, cl, folder, true)
+	if err != nil {
+		return nil, err
+	}
+	return toNodeMap(folder, entries), nil
+}
+
+// the ETag changes when the content changes, it can not be calculated from the content at the destination
+func toNodeMap(folder string, entries []Entry) map[string]tree.Node {
+	res := map[string]tree.Node{}
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		id := strings.TrimPrefix(e.Id, folder)
+		path := strings.TrimSuffix(strings.TrimPrefix(e.Path, folder), "/")
+		node := tree.Node{
+			Id:   id,
+			Name: e.Name,
+			Path: path,
+			Attributes: tree.Attributes{
+				IsFile:         true,
+				RemoteHash:     e.ETag,
+				RemoteHashType: types.ETag,
+				RemoteFileSize: e.Size,
+			},
+		}
+		res[id] = node
+	}
+	return res
+}
@@ -0,0 +1,117 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"integration/app/plugin/types"
+	"integration/app/tree"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// number of times a download is resumed with a range request after the connection was lost
+const maxResumeAttempts = 5
+
+func Streams(ctx context.Context, in map[string]tree.Node, streamParams types.StreamParams) (types.StreamsType, error) {
+	cl, err := getClient(streamParams.Url, streamParams.User, streamParams.Token)
+	if err != nil {
+		return types.StreamsType{}, fmt.Errorf("streams: %v", err)
+	}
+	folder := normalizeFolder(streamParams.Option)
+	res := map[string]types.Stream{}
+
+	for k, v := range in {
+		url := cl.url(folder + v.SourceId())
+		var reader *rangeReader
+		res[k] = types.Stream{
+			// each open (a retried write opens the stream again) downloads the file from the start
+			Open: func() (io.Reader, error) {
+				reader = &rangeReader{
+					ctx:  ctx,
+					cl:   cl,
+					url:  url,
+					etag: v.Attributes.RemoteHash,
+				}
+				body, err := reader.get()
+				if err != nil {
+					return nil, err
+				}
+				reader.body = body
+				return reader, nil
+			},
+			Close: func() error {
+				if reader == nil || reader.body == nil {
+					return nil
+				}
+				return reader.body.Close()
+			},
+		}
+	}
+	return types.StreamsType{Streams: res, Cleanup: nil}, nil
+}
+
+// reads the file and resumes the download from the last read byte with a range request when the connection breaks;
+// the If-Range header with the ETag makes sure that the rest of the same version of the file is downloaded
+type rangeReader struct {
+	ctx      context.Context
+	cl       *client
+	url      string
+	etag     string
+	body     io.ReadCloser
+	offset   int64
+	attempts int
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == nil || err == io.EOF || r.attempts >= maxResumeAttempts || r.ctx.Err() != nil {
+		return n, err
+	}
+	r.attempts++
+	r.body.Close()
+	body, resumeErr := r.get()
+	if resumeErr != nil {
+		r.body = io.NopCloser(strings.NewReader(""))
+		return n, fmt.Errorf("%w (resuming the download failed: %v)", err, resumeErr)
+	}
+	r.body = body
+	return n, nil
+}
+
+func (r *rangeReader) get() (io.ReadCloser, error) {
+	request, err := r.cl.newRequest(r.ctx, "GET", r.url, "")
+	if err != nil {
+		return nil, err
+	}
+	if r.offset > 0 {
+		request.Header.Add("Range", fmt.Sprintf("bytes=%d-", r.offset))
+		if r.etag != "" {
+			request.Header.Add("If-Range", `"`+r.etag+`"`)
+		}
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, types.ErrNotFound
+	case r.offset > 0 && resp.StatusCode == http.StatusOK:
+		// the range was ignored: the server does not support ranges or the file has changed
+		resp.Body.Close()
+		return nil, fmt.Errorf("the download of %v can not be resumed: the file has changed or the server does not support range requests", r.url)
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent:
+		b, _ := types.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err := types.UnauthorizedError(resp.StatusCode, b); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("getting file failed: %d - %s", resp.StatusCode, string(b))
+	}
+	return resp.Body, nil
+}
@@ -4,6 +4,7 @@ package plugin
 
 import (
 	"context"
+	"integration/app/plugin/impl/bitbucket"
 	"integration/app/plugin/impl/dataverse"
 	"integration/app/plugin/impl/github"
 	"integration/app/plugin/impl/gitlab"
@@ -14,29 +15,43 @@ import (
 	"integration/app/plugin/impl/osf"
 	"integration/app/plugin/impl/redcap"
 	"integration/app/plugin/impl/sftp_plugin"
+	"integration/app/plugin/impl/webdav"
 	"integration/app/plugin/types"
 	"integration/app/tree"
 )
 
 type Plugin struct {
-	Query   func(ctx context.Context, req types.CompareRequest, dvNodes map[string]tree.Node) (map[string]tree.Node, error)
-	Options func(ctx context.Context, params types.OptionsRequest) ([]types.SelectItem, error)
-	Search  func(ctx context.Context, params types.OptionsRequest) ([]types.SelectItem, error)
-	Streams func(ctx context.Context, in map[string]tree.Node, streamParams types.StreamParams) (types.StreamsType, error)
+	Query    func(ctx context.Context, req types.CompareRequest, dvNodes map[string]tree.Node) (map[string]tree.Node, error)
+	Options  func(ctx context.Context, params types.OptionsRequest) ([]types.SelectItem, error)
+	Search   func(ctx context.Context, params types.OptionsRequest) ([]types.SelectItem, error)
+	Streams  func(ctx context.Context, in map[string]tree.Node, streamParams types.StreamParams) (types.StreamsType, error)
+	FileSize func(ctx context.Context, node tree.Node, streamParams types.StreamParams) (int64, error) // optional: probe the file size before streaming when the source does not provide it in the query
+	// the query does not use the nodes of the destination (e.g., to reuse known hashes) and can run in parallel with the query of the destination
+	IndependentQuery bool
 }
 
 var pluginMap map[string]Plugin = map[string]Plugin{
 	"github": {
-		Query:   github.Query,
-		Options: github.Options,
-		Search:  github.Search,
-		Streams: github.Streams,
+		Query:            github.Query,
+		Options:          github.Options,
+		Search:           github.Search,
+		Streams:          github.Streams,
+		IndependentQuery: true,
 	},
 	"gitlab": {
-		Query:   gitlab.Query,
-		Options: gitlab.Options,
-		Search:  gitlab.Search,
-		Streams: gitlab.Streams,
+		Query:            gitlab.Query,
+		Options:          gitlab.Options,
+		Search:           gitlab.Search,
+		Streams:          gitlab.Streams,
+		FileSize:         gitlab.FileSize,
+		IndependentQuery: true,
+	},
+	"bitbucket": {
+		Query:            bitbucket.Query,
+		Options:          bitbucket.Options,
+		Search:           bitbucket.Search,
+		Streams:          bitbucket.Streams,
+		IndependentQuery: true,
 	},
 	"irods": {
 		Query:   irods.Query,
@@ -51,10 +66,11 @@ var pluginMap map[string]Plugin = map[string]Plugin{
 		Streams: redcap.Streams,
 	},
 	"osf": {
-		Query:   osf.Query,
-		Options: nil,
-		Search:  osf.Search,
-		Streams: osf.Streams,
+		Query:            osf.Query,
+		Options:          nil,
+		Search:           osf.Search,
+		Streams:          osf.Streams,
+		IndependentQuery: true,
 	},
 	"onedrive": {
 		Query:   onedrive.Query,
@@ -63,10 +79,11 @@ var pluginMap map[string]Plugin = map[string]Plugin{
 		Streams: onedrive.Streams,
 	},
 	"dataverse": {
-		Query:   dataverse.Query,
-		Options: nil,
-		Search:  dataverse.Search,
-		Streams: dataverse.Streams,
+		Query:            dataverse.Query,
+		Options:          nil,
+		Search:           dataverse.Search,
+		Streams:          dataverse.Streams,
+		IndependentQuery: true,
 	},
 	"local": {
 		Query:   local.Query,
@@ -81,10 +98,18 @@ var pluginMap map[string]Plugin = map[string]Plugin{
 		Streams: sftp_plugin.Streams,
 	},
 	"globus": {
-		Query:   globus.Query,
-		Options: globus.Options,
-		Search:  globus.Search,
-		Streams: globus.Streams,
+		Query:            globus.Query,
+		Options:          globus.Options,
+		Search:           globus.Search,
+		Streams:          globus.Streams,
+		IndependentQuery: true,
+	},
+	"webdav": {
+		Query:            webdav.Query,
+		Options:          webdav.Options,
+		Search:           nil,
+		Streams:          webdav.Streams,
+		IndependentQuery: true,
 	},
 }
 
@@ -13,4 +13,10 @@ type CompareRequest struct {
 	PersistentId string `json:"persistentId"`
 	NewlyCreated bool   `json:"newlyCreated"`
 	DataverseKey string `json:"dataverseKey"`
+	Destination  string `json:"destination,omitempty"`
+	Directory    string `json:"directory,omitempty"`   // when set, only the files in this directory (and its subdirectories) are compared
+	FullListing  bool   `json:"fullListing,omitempty"` // include the equal files in the response, also when omitEqualNodes is set
+	Include      string `json:"include,omitempty"`     // local file system plugin: comma separated glob patterns of the files to compare (e.g., "**/*.csv"), all files by default
+	Exclude      string `json:"exclude,omitempty"`     // local file system plugin: comma separated glob patterns of the files and folders to skip (e.g., "**/.git/**,**/*.tmp"), takes precedence over include
+	HashType     string `json:"-"`                     // set by the backend when detectDatasetHash is set: hash type of the dataset, used by the sources calculating the hashes themselves
 }
@@ -2,16 +2,51 @@
 
 package types
 
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
 const (
 	SHA1         = "SHA-1"
 	GitHash      = "git-hash"
 	Md5          = "MD5"
 	SHA256       = "SHA256"
 	SHA512       = "SHA512"
+	Blake2b      = "BLAKE2b-512"
+	SHA3_256     = "SHA3-256"
 	QuickXorHash = "quickXorHash"
 	FileSize     = "FileSize"
 	NotNeeded    = "not needed"
 	Written      = "written"
 	Deleted      = "deleted"
 	LastModified = "last_modified"
+	ETag         = "ETag"
+	GitCommit    = "git-commit"
 )
+
+// hash function for the sources that calculate the hashes themselves (local, sftp), returns the hash type actually used:
+// MD5 when the hash type is not set or not supported
+func NewSourceHasher(hashType string) (hash.Hash, string) {
+	switch strings.ToLower(hashType) {
+	case strings.ToLower(SHA1):
+		return sha1.New(), SHA1
+	case strings.ToLower(SHA256):
+		return sha256.New(), SHA256
+	case strings.ToLower(SHA512):
+		return sha512.New(), SHA512
+	case strings.ToLower(Blake2b):
+		h, _ := blake2b.New512(nil) // only fails for keys longer than 64 bytes
+		return h, Blake2b
+	case strings.ToLower(SHA3_256):
+		return sha3.New256(), SHA3_256
+	}
+	return md5.New(), Md5
+}
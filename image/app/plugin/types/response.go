@@ -0,0 +1,42 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// limit of ReadAll, 100 MiB unless maxResponseSize is configured
+var MaxResponseSize int64 = 100 << 20
+
+var ErrResponseTooLarge = errors.New("response too large")
+
+// returned (wrapped) by Query when the credentials for the source are invalid or lack permissions
+var ErrUnauthorized = errors.New("unauthorized")
+
+// returned (wrapped) by Query when the source keeps limiting the requests, the message tells the user when to try again
+var ErrRateLimited = errors.New("rate limited")
+
+// wraps ErrUnauthorized for the 401 and 403 status codes, nil for other status codes
+func UnauthorizedError(statusCode int, body []byte) error {
+	if statusCode != http.StatusUnauthorized && statusCode != http.StatusForbidden {
+		return nil
+	}
+	return fmt.Errorf("%w: %d - %s", ErrUnauthorized, statusCode, string(body))
+}
+
+// reads a (metadata or API) response body, at most MaxResponseSize bytes are kept in memory;
+// when the response is larger, the bytes read so far are returned together with ErrResponseTooLarge
+func ReadAll(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, MaxResponseSize+1))
+	if err != nil {
+		return b, err
+	}
+	if int64(len(b)) > MaxResponseSize {
+		return b[:MaxResponseSize], fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, MaxResponseSize)
+	}
+	return b, nil
+}
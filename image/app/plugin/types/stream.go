@@ -2,7 +2,13 @@
 
 package types
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+// returned (wrapped) by Open when the file is no longer at the source, e.g., deleted after the compare
+var ErrNotFound = errors.New("file not found at the source")
 
 type Stream struct {
 	Open  func() (io.Reader, error)
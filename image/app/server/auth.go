@@ -0,0 +1,61 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package server
+
+import (
+	"integration/app/common"
+	"integration/app/config"
+	"integration/app/core"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// paths that are served without login, e.g., the frontend needs its config before the user logs in
+var unprotected = map[string]bool{
+	"/api/frontend/config": true,
+	"/quit":                true,
+	"/healthz":             true,
+	"/readyz":              true,
+}
+
+// when the login redirect URL is configured, requests without user are redirected to the login (browser) or refused (API)
+func requireLogin(next http.Handler) http.Handler {
+	loginUrl := config.GetConfig().Options.LoginRedirectUrl
+	if loginUrl == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unprotected[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !core.ValidSessionId(r.Header) {
+			common.WriteError(w, http.StatusBadRequest, common.ErrCodeBadRequest, "invalid session id")
+			return
+		}
+		if core.HasUserInHeader(r.Header) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isBrowserRequest(r) {
+			http.Redirect(w, r, loginRedirect(loginUrl, r), http.StatusSeeOther)
+			return
+		}
+		w.Header().Set("Location", loginUrl)
+		common.WriteError(w, http.StatusUnauthorized, common.ErrCodeUnauthorized, "login required")
+	})
+}
+
+// API calls are made by the frontend (or scripts) and do not accept HTML, navigating browsers do
+func isBrowserRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func loginRedirect(loginUrl string, r *http.Request) string {
+	separator := "?"
+	if strings.Contains(loginUrl, "?") {
+		separator = "&"
+	}
+	return loginUrl + separator + "target=" + url.QueryEscape(r.URL.RequestURI())
+}
@@ -0,0 +1,95 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package server
+
+import (
+	"encoding/json"
+	"integration/app/common"
+	"integration/app/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const loginUrl = "https://login.example.org/login"
+
+var served = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("served"))
+})
+
+func useLoginRedirect(t *testing.T, url string) {
+	t.Helper()
+	previous := config.GetConfig().Options
+	config.SetOptions(config.OptionalConfig{LoginRedirectUrl: url})
+	t.Cleanup(func() { config.SetOptions(previous) })
+}
+
+func TestRequireLogin(t *testing.T) {
+	useLoginRedirect(t, loginUrl)
+	handler := requireLogin(served)
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		header   map[string]string
+		status   int
+		location string
+		code     string // error code of the response, empty when served or redirected
+	}{
+		{"browser without user", http.MethodGet, "/connect?code=abc", map[string]string{"Accept": "text/html,application/xhtml+xml"}, http.StatusSeeOther, loginUrl + "?target=%2Fconnect%3Fcode%3Dabc", ""},
+		{"api call without user", http.MethodPost, "/api/common/compare", map[string]string{"Accept": "application/json"}, http.StatusUnauthorized, loginUrl, common.ErrCodeUnauthorized},
+		{"api get without user", http.MethodGet, "/api/common/cached", map[string]string{"Accept": "text/html"}, http.StatusUnauthorized, loginUrl, common.ErrCodeUnauthorized},
+		{"malformed session id", http.MethodGet, "/api/common/cached", map[string]string{"Ajp_uid": "user", "Ajp_shib-Session-Id": "../../etc"}, http.StatusBadRequest, "", common.ErrCodeBadRequest},
+		{"with user", http.MethodPost, "/api/common/compare", map[string]string{"Ajp_uid": "user"}, http.StatusOK, "", ""},
+		{"with access token", http.MethodPost, "/api/common/compare", map[string]string{"X-Forwarded-Access-Token": "token"}, http.StatusOK, "", ""},
+		{"frontend config", http.MethodGet, "/api/frontend/config", nil, http.StatusOK, "", ""},
+		{"quit", http.MethodGet, "/quit", nil, http.StatusOK, "", ""},
+		{"liveness probe", http.MethodGet, "/healthz", nil, http.StatusOK, "", ""},
+		{"readiness probe", http.MethodGet, "/readyz", map[string]string{"Ajp_shib-Session-Id": "../../etc"}, http.StatusOK, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("got status %v, expected %v", w.Code, tt.status)
+			}
+			if location := w.Header().Get("Location"); location != tt.location {
+				t.Errorf("got location %q, expected %q", location, tt.location)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != "served" {
+				t.Errorf("request not served: %v", w.Body.String())
+			}
+			if tt.code != "" {
+				res := common.ErrorResponse{}
+				if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.ErrorCode != tt.code {
+					t.Errorf("got %v (%v), expected error code %v", w.Body.String(), err, tt.code)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireLoginRedirectWithQuery(t *testing.T) {
+	useLoginRedirect(t, loginUrl+"?provider=shibboleth")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	requireLogin(served).ServeHTTP(w, r)
+	if expected := loginUrl + "?provider=shibboleth&target=%2F"; w.Code != http.StatusSeeOther || w.Header().Get("Location") != expected {
+		t.Errorf("got %v %q, expected %v %q", w.Code, w.Header().Get("Location"), http.StatusSeeOther, expected)
+	}
+}
+
+func TestRequireLoginNotConfigured(t *testing.T) {
+	useLoginRedirect(t, "")
+	w := httptest.NewRecorder()
+	requireLogin(served).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/common/compare", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "served" {
+		t.Errorf("requests without user must be served when no login redirect URL is configured, got %v", w.Code)
+	}
+}
@@ -0,0 +1,81 @@
+// Author: Eryk Kulikowski @ KU Leuven (2024). Apache 2.0 License
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"integration/app/config"
+	"net/http"
+	"time"
+)
+
+const defaultReadinessTimeout = 2 * time.Second
+
+type HealthResponse struct {
+	Status    string `json:"status"`
+	Redis     string `json:"redis,omitempty"`
+	Dataverse string `json:"dataverse,omitempty"`
+}
+
+// liveness probe: the process is up and serving requests
+func healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, HealthResponse{Status: "ok"}, true)
+}
+
+// readiness probe: the cache (Redis) and, when readinessCheckDataverse is set, Dataverse are reachable;
+// the checks share a short timeout such that a slow dependency does not make the probe hang
+func readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout())
+	defer cancel()
+	res := HealthResponse{Status: "ok", Redis: "ok"}
+	ready := true
+	if !config.RedisReady(ctx) {
+		res.Redis = "unavailable"
+		ready = false
+	}
+	if config.GetConfig().Options.ReadinessCheckDataverse {
+		res.Dataverse = "ok"
+		if err := dataverseReachable(ctx); err != nil {
+			res.Dataverse = err.Error()
+			ready = false
+		}
+	}
+	if !ready {
+		res.Status = "unavailable"
+	}
+	writeHealth(w, res, ready)
+}
+
+func dataverseReachable(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", config.GetConfig().DataverseServer+"/api/v1/info/version", nil)
+	if err != nil {
+		return err
+	}
+	r, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %v", r.Status)
+	}
+	return nil
+}
+
+func writeHealth(w http.ResponseWriter, res HealthResponse, ok bool) {
+	b, _ := json.Marshal(res)
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
+
+func readinessTimeout() time.Duration {
+	if s := config.GetConfig().Options.ReadinessTimeout; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultReadinessTimeout
+}
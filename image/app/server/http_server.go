@@ -30,6 +30,7 @@ func Start() {
 	// common
 	srvMux.HandleFunc("/api/common/oauthtoken", common.GetOauthToken)
 	srvMux.HandleFunc("/api/common/newdataset", common.NewDataset)
+	srvMux.HandleFunc("/api/common/missingmetadata", common.MissingMetadata)
 	srvMux.HandleFunc("/api/common/compare", common.Compare)
 	srvMux.HandleFunc("/api/common/cached", common.GetCachedResponse)
 	srvMux.HandleFunc("/api/common/store", common.Store)
@@ -38,6 +39,17 @@ func Start() {
 	srvMux.HandleFunc("/api/common/checkaccess", common.GetAccessToQueue)
 	srvMux.HandleFunc("/api/common/compute", common.Compute)
 	srvMux.HandleFunc("/api/common/cachedcompute", common.GetCachedComputeResponse)
+	srvMux.HandleFunc("/api/common/queues", common.GetQueueStatus)
+	srvMux.HandleFunc("/api/common/joblog", common.GetJobLog)
+	srvMux.HandleFunc("/api/common/jobstatus", common.JobStatus)
+	srvMux.HandleFunc("/api/common/canceljob", common.CancelJob)
+	srvMux.HandleFunc("/api/common/jobs", common.UserJobs)
+	srvMux.HandleFunc("/api/common/globusstatus", common.GlobusTransferStatus)
+	srvMux.HandleFunc("/api/common/globuscancel", common.CancelGlobusTransfer)
+
+	// liveness and readiness probes
+	srvMux.HandleFunc("/healthz", healthz)
+	srvMux.HandleFunc("/readyz", readyz)
 
 	// frontend config
 	srvMux.HandleFunc("/api/frontend/config", frontend.GetConfig)
@@ -66,7 +78,7 @@ func Start() {
 		IdleTimeout:       timeout,
 		ReadHeaderTimeout: timeout,
 		TLSConfig:         tlsConfig,
-		Handler:           http.TimeoutHandler(srvMux, timeout, fmt.Sprintf("processing the request took longer than %v: cancelled", timeout)),
+		Handler:           http.TimeoutHandler(requireLogin(srvMux), timeout, fmt.Sprintf("processing the request took longer than %v: cancelled", timeout)),
 	}
 	srv.ListenAndServe()
 }
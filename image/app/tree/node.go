@@ -17,13 +17,29 @@ const (
 	Delete = 3
 )
 
+// why a file is not shown as equal, the hashes can only be compared when they are of the same type
+const (
+	PendingRehash   = "pendingRehash"   // the hash types differ and the destination file is being rehashed with the hash type of the source
+	ContentDiffers  = "contentDiffers"  // hashes of the same type differ
+	MetadataDiffers = "metadataDiffers" // the content is equal, but the directory label, the file name or the description differs (only when metadataOnlyUpdates is set)
+)
+
 type Node struct {
-	Id         string     `json:"id"`
-	Attributes Attributes `json:"attributes"`
-	Path       string     `json:"path"`
-	Name       string     `json:"name"`
-	Status     int        `json:"status"`
-	Action     int        `json:"action"`
+	Id           string     `json:"id"`
+	Attributes   Attributes `json:"attributes"`
+	Path         string     `json:"path"`
+	Name         string     `json:"name"`
+	Status       int        `json:"status"`
+	StatusReason string     `json:"statusReason,omitempty"`
+	Action       int        `json:"action"`
+}
+
+// the id of the file at the source, used for streaming the file
+func (n Node) SourceId() string {
+	if n.Attributes.SourcePath != "" {
+		return n.Attributes.SourcePath
+	}
+	return n.Id
 }
 
 type Attributes struct {
@@ -33,6 +49,11 @@ type Attributes struct {
 	RemoteFileSize  int64           `json:"remoteFileSize"`
 	IsFile          bool            `json:"isFile"`
 	DestinationFile DestinationFile `json:"destinationFile"`
+	Categories      []string        `json:"categories,omitempty"`    // file tags at the source (e.g., "Documentation"), copied to the destination when writing the file
+	SourceVersion   string          `json:"sourceVersion,omitempty"` // version of the source at the time of the query (e.g., git commit), only set when recordSourceVersion is configured
+	SourcePath      string          `json:"sourcePath,omitempty"`    // id of the file at the source when it differs from the id at the destination (flattened directories)
+	Restricted      bool            `json:"restricted,omitempty"`    // access to the file at the source is restricted, it can only be downloaded with access granted (Dataverse source)
+	EmbargoActive   bool            `json:"embargoActive,omitempty"` // the file at the source is under embargo and can not be downloaded (Dataverse source)
 }
 
 type DestinationFile struct {
@@ -41,4 +62,6 @@ type DestinationFile struct {
 	Hash              string `json:"hash"`
 	HashType          string `json:"hashType"`
 	StorageIdentifier string `json:"storageIdentifier"`
+	Description       string `json:"description,omitempty"`
+	MovedFrom         string `json:"movedFrom,omitempty"` // id of the destination file with equal content stored under another directory label or file name, see metadataOnlyUpdates
 }
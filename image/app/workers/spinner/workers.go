@@ -32,6 +32,9 @@ func SpinWorkers(numberWorkers int, queue string) {
 		}
 	}
 
+	// put the jobs of interrupted workers back in their queue
+	go core.RecoverInterruptedJobs()
+
 	// wait for termination
 	signalChannel := make(chan os.Signal, 2)
 	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
@@ -3,6 +3,7 @@ module integration
 go 1.23
 
 require (
+	github.com/alicebob/miniredis/v2 v2.39.0
 	github.com/aws/aws-sdk-go-v2 v1.32.6
 	github.com/aws/aws-sdk-go-v2/config v1.28.6
 	github.com/aws/aws-sdk-go-v2/feature/s3/manager v1.17.43
@@ -19,6 +20,7 @@ require (
 require (
 	github.com/kr/fs v0.1.0 // indirect
 	github.com/stretchr/testify v1.8.3 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 )
 
 require (